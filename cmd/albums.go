@@ -11,6 +11,8 @@ var albumsCmd = &cobra.Command{
 }
 
 func init() {
+	albumsCmd.PersistentFlags().IntVar(&albums.BatchSize, "batch-size", 0,
+		"Largest number of assets an operation adds to or removes from an album (0 disables batching)")
 	planCmd.AddCommand(albumsCmd)
 	albumsCmd.AddCommand(albums.ReplaceCmd)
 	albumsCmd.AddCommand(albums.AddUserCmd)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"immich-manager/cmd/options"
 	adduser "immich-manager/pkg/immich/albums/add-user"
 )
 
@@ -12,7 +13,7 @@ var AddUserCmd = &cobra.Command{
 	Use:   "add-user [search-term] [email]",
 	Short: "Generate a plan to add a user to albums matching a search term",
 	Args:  cobra.ExactArgs(2),
-	RunE: func(_ *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		searchTerm := args[0]
 		email := args[1]
 
@@ -23,12 +24,15 @@ var AddUserCmd = &cobra.Command{
 
 		generator := adduser.NewGenerator(client, searchTerm, email)
 
-		plan, err := generator.Generate()
+		ctx, cancel := options.WithTimeout(cmd.Context())
+		defer cancel()
+
+		plan, err := generator.GenerateContext(ctx)
 		if err != nil {
 			return fmt.Errorf("generating plan: %w", err)
 		}
 
-		return outputPlan(plan)
+		return outputPlan(ctx, cmd, args, client, plan)
 	},
 }
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"immich-manager/cmd/options"
 	"immich-manager/pkg/immich/albums/clearshared"
 )
 
@@ -11,7 +12,7 @@ var ClearSharedCmd = &cobra.Command{
 	Use:   "clear-shared [email]",
 	Short: "Generate a plan to remove a user from all shared albums",
 	Args:  cobra.ExactArgs(1),
-	RunE: func(_ *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		email := args[0]
 
 		client, err := getClient()
@@ -21,11 +22,14 @@ var ClearSharedCmd = &cobra.Command{
 
 		generator := clearshared.NewGenerator(client, email)
 
-		plan, err := generator.Generate()
+		ctx, cancel := options.WithTimeout(cmd.Context())
+		defer cancel()
+
+		plan, err := generator.GenerateContext(ctx)
 		if err != nil {
 			return fmt.Errorf("generating plan: %w", err)
 		}
 
-		return outputPlan(plan)
+		return outputPlan(ctx, cmd, args, client, plan)
 	},
 }
@@ -1,37 +1,73 @@
 package albums
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/spf13/cobra"
+	"immich-manager/cmd/options"
 	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
 )
 
+// BatchSize is the largest number of assets per operation in generated
+// plans, set by --batch-size. Zero, the default, leaves operations as
+// generated.
+var BatchSize int
+
 // getClient returns a configured Immich client.
 func getClient() (*immich.Client, error) {
-	token := os.Getenv("IMMICH_TOKEN")
-	if token == "" {
-		return nil, errors.New("IMMICH_TOKEN environment variable is required")
+	client, err := options.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("creating client: %w", err)
+	}
+
+	return client, nil
+}
+
+// outputPlan batches the asset operations of a plan when --batch-size is
+// set, stamps it with its provenance and encodes it to stdout.
+func outputPlan(ctx context.Context, cmd *cobra.Command, args []string, client *immich.Client, p *plan.Plan) error {
+	if BatchSize > 0 {
+		if _, _, err := p.Batch(BatchSize, immich.ParseRequest, immich.BuildRequest); err != nil {
+			return fmt.Errorf("batching plan: %w", err)
+		}
+	}
+
+	if err := p.Stamp(provenance(ctx, cmd, args, client)); err != nil {
+		return fmt.Errorf("stamping plan: %w", err)
 	}
 
-	server := os.Getenv("IMMICH_SERVER")
-	if server == "" {
-		return nil, errors.New("IMMICH_SERVER environment variable is required")
+	if err := p.Write(os.Stdout); err != nil {
+		return fmt.Errorf("writing plan: %w", err)
 	}
 
-	return immich.NewClient(server, token), nil
+	return nil
 }
 
-// outputPlan encodes and outputs a plan to stdout.
-func outputPlan(plan any) error {
-	encoder := json.NewEncoder(os.Stdout)
-	encoder.SetIndent("", "  ")
+// provenance describes the command that generated a plan and the server it
+// was generated against. Server details that cannot be fetched are left
+// out rather than failing an otherwise complete plan.
+func provenance(ctx context.Context, cmd *cobra.Command, args []string, client *immich.Client) plan.Metadata {
+	meta := plan.Metadata{
+		Generator: strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" plan "),
+		Args:      args,
+		ServerURL: client.ServerURL(),
+	}
+
+	if version, err := client.Server.Version(ctx); err == nil {
+		meta.ServerVersion = version.String()
+	} else {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
 
-	if err := encoder.Encode(plan); err != nil {
-		return fmt.Errorf("encoding plan: %w", err)
+	if user, err := client.Users.Me(ctx); err == nil {
+		meta.User = user.Email
+	} else {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 	}
 
-	return nil
+	return meta
 }
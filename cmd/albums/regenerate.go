@@ -0,0 +1,61 @@
+package albums
+
+import (
+	"context"
+	"fmt"
+
+	"immich-manager/pkg/immich"
+	addperson "immich-manager/pkg/immich/albums/add-person"
+	adduser "immich-manager/pkg/immich/albums/add-user"
+	"immich-manager/pkg/immich/albums/clearshared"
+	"immich-manager/pkg/immich/albums/replace"
+	"immich-manager/pkg/immich/albums/smart"
+	"immich-manager/pkg/plan"
+)
+
+// generator creates a plan generator from the arguments of its command.
+type generator struct {
+	args int
+	new  func(client *immich.Client, args []string) plan.ContextGenerator
+}
+
+// generators holds the plan commands by the name recorded in plan
+// metadata.
+var generators = map[string]generator{
+	"albums replace": {args: 2, new: func(client *immich.Client, args []string) plan.ContextGenerator {
+		return replace.NewGenerator(client, args[0], args[1])
+	}},
+	"albums add-user": {args: 2, new: func(client *immich.Client, args []string) plan.ContextGenerator {
+		return adduser.NewGenerator(client, args[0], args[1])
+	}},
+	"albums add-person": {args: 2, new: func(client *immich.Client, args []string) plan.ContextGenerator {
+		return addperson.NewGenerator(client, args[0], args[1])
+	}},
+	"albums clear-shared": {args: 1, new: func(client *immich.Client, args []string) plan.ContextGenerator {
+		return clearshared.NewGenerator(client, args[0])
+	}},
+	"albums smart": {args: 1, new: func(client *immich.Client, args []string) plan.ContextGenerator {
+		return smart.NewGenerator(client, args[0])
+	}},
+}
+
+// Regenerate runs the generator recorded in the metadata of a plan again
+// with the same arguments. It reports false, without contacting the
+// server, when the plan was not made by a known generator.
+func Regenerate(ctx context.Context, client *immich.Client, meta *plan.Metadata) (*plan.Plan, bool, error) {
+	if meta == nil {
+		return nil, false, nil
+	}
+
+	g, ok := generators[meta.Generator]
+	if !ok || len(meta.Args) != g.args {
+		return nil, false, nil
+	}
+
+	p, err := g.new(client, meta.Args).GenerateContext(ctx)
+	if err != nil {
+		return nil, true, fmt.Errorf("regenerating plan with '%s': %w", meta.Generator, err)
+	}
+
+	return p, true, nil
+}
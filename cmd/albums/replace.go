@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"immich-manager/cmd/options"
 	"immich-manager/pkg/immich/albums/replace"
 )
 
@@ -11,7 +12,7 @@ var ReplaceCmd = &cobra.Command{
 	Use:   "replace [before] [after]",
 	Short: "Generate a plan to replace text in album names",
 	Args:  cobra.ExactArgs(2),
-	RunE: func(_ *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		before := args[0]
 		after := args[1]
 
@@ -22,11 +23,14 @@ var ReplaceCmd = &cobra.Command{
 
 		generator := replace.NewGenerator(client, before, after)
 
-		plan, err := generator.Generate()
+		ctx, cancel := options.WithTimeout(cmd.Context())
+		defer cancel()
+
+		plan, err := generator.GenerateContext(ctx)
 		if err != nil {
 			return fmt.Errorf("generating plan: %w", err)
 		}
 
-		return outputPlan(plan)
+		return outputPlan(ctx, cmd, args, client, plan)
 	},
 }
@@ -1,13 +1,10 @@
 package albums
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
-	"immich-manager/pkg/immich"
+	"immich-manager/cmd/options"
 	"immich-manager/pkg/immich/albums/smart"
 )
 
@@ -15,34 +12,25 @@ var SmartCmd = &cobra.Command{
 	Use:   "smart [email]",
 	Short: "Generate a plan to create/maintain a smart album with all assets from albums shared with a user",
 	Args:  cobra.ExactArgs(1),
-	RunE: func(_ *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		email := args[0]
 
-		token := os.Getenv("IMMICH_TOKEN")
-		if token == "" {
-			return errors.New("IMMICH_TOKEN environment variable is required")
-		}
-
-		server := os.Getenv("IMMICH_SERVER")
-		if server == "" {
-			return errors.New("IMMICH_SERVER environment variable is required")
+		client, err := getClient()
+		if err != nil {
+			return err
 		}
 
-		client := immich.NewClient(server, token)
 		generator := smart.NewGenerator(client, email)
 
-		p, err := generator.Generate()
+		ctx, cancel := options.WithTimeout(cmd.Context())
+		defer cancel()
+
+		p, err := generator.GenerateContext(ctx)
 		if err != nil {
 			return fmt.Errorf("generating plan: %w", err)
 		}
 
-		encoder := json.NewEncoder(os.Stdout)
-		encoder.SetIndent("", "  ")
-		if err := encoder.Encode(p); err != nil {
-			return fmt.Errorf("encoding plan: %w", err)
-		}
-
-		return nil
+		return outputPlan(ctx, cmd, args, client, p)
 	},
 }
 
@@ -4,33 +4,75 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"immich-manager/cmd/options"
 	"immich-manager/pkg/immich"
 	"immich-manager/pkg/immich/applier"
+	"immich-manager/pkg/immich/describe"
 	"immich-manager/pkg/plan"
 )
 
-var dryRun bool
+var (
+	dryRun      bool
+	journalPath string
+	resume      bool
+	atomic      bool
+	parallelism int
+
+	continueOnError bool
+	failedPlanPath  string
+	verify          bool
+
+	maxAge              time.Duration
+	allowServerMismatch bool
+	onDrift             string
+	skipValidation      bool
+	requireSignature    bool
+	recordState         bool
+
+	applySelection selection
+	applyOutput    eventOutput
+)
 
 var applyCmd = &cobra.Command{
 	Use:   "apply [plan-file]",
 	Short: "Apply a plan to the Immich API (use '-' or omit to read from stdin)",
 	Args:  cobra.MaximumNArgs(1),
-	RunE: func(_ *cobra.Command, args []string) error {
-		token := os.Getenv("IMMICH_TOKEN")
-		if token == "" {
-			return errors.New("IMMICH_TOKEN environment variable is required")
+	RunE: func(cmd *cobra.Command, args []string) error {
+		driftPolicy, err := applier.ParseDriftPolicy(onDrift)
+		if err != nil {
+			return err
+		}
+
+		if parallelism < 1 {
+			return errors.New("--parallelism must be at least 1")
 		}
 
-		server := os.Getenv("IMMICH_SERVER")
-		if server == "" {
-			return errors.New("IMMICH_SERVER environment variable is required")
+		if continueOnError && atomic {
+			return errors.New("--continue-on-error cannot be combined with --atomic")
+		}
+
+		if verify && dryRun {
+			return errors.New("--verify cannot be combined with --dry-run")
+		}
+
+		if recordState && skipHistory && journalPath == "" && (len(args) == 0 || args[0] == "-") {
+			return errors.New("--record-state needs a journal or the history to keep the state: " +
+				"pass --journal or drop --no-history")
+		}
+
+		client, err := options.NewClient()
+		if err != nil {
+			return fmt.Errorf("creating client: %w", err)
 		}
 
 		var p *plan.Plan
-		var err error
 
+		planFile := ""
 		if len(args) == 0 || args[0] == "-" {
 			// Read from stdin
 			p, err = plan.LoadFromReader(os.Stdin)
@@ -39,34 +81,281 @@ var applyCmd = &cobra.Command{
 			}
 		} else {
 			// Read from file
-			planFile := args[0]
+			planFile = args[0]
 			p, err = plan.Load(planFile)
 			if err != nil {
 				return fmt.Errorf("loading plan: %w", err)
 			}
 		}
 
-		client := immich.NewClient(server, token)
+		if requireSignature {
+			if err := checkSignature(p); err != nil {
+				return err
+			}
+		}
+
+		if !skipValidation {
+			if err := validatePlan(p, os.Stderr); err != nil {
+				return fmt.Errorf("%w: fix the plan or pass --skip-validation", err)
+			}
+		}
+
+		if err := checkProvenance(p, client.ServerURL(), allowServerMismatch); err != nil {
+			return err
+		}
+
+		if err := p.CheckAge(maxAge, time.Now()); err != nil {
+			return fmt.Errorf("%w: regenerate the plan or raise --max-age", err)
+		}
+
+		names := describe.NewNames(client, p.Names)
+
+		if applySelection.interactive && planFile == "" {
+			return errors.New("--interactive cannot be used with a plan read from stdin")
+		}
+
+		indices, err := applySelection.resolve(cmd.Context(), p, names, "apply", false)
+		if err != nil {
+			return err
+		}
+
+		if err := applySelection.write(p, indices); err != nil {
+			return err
+		}
+
+		selected := selectedFunc(indices)
+
+		journal, err := openJournal(p, planFile, journalPath)
+		if err != nil {
+			return err
+		}
+
+		if journal == nil && resume {
+			return errors.New("--resume requires a journal: pass --journal when reading the plan from stdin")
+		}
+
+		if journal != nil {
+			defer func() { _ = journal.Close() }()
+
+			all := len(pendingOperations(len(p.Operations), selected, nil, plan.ActionApply))
+			remaining := len(pendingOperations(len(p.Operations), selected, journal, plan.ActionApply))
+
+			if done := all - remaining; done > 0 && !resume {
+				return fmt.Errorf("journal %s records %d of these operations as applied: "+
+					"use --resume to continue or revert first", journal.Path(), done)
+			}
+		}
+
+		count := len(pendingOperations(len(p.Operations), selected, journal, plan.ActionApply))
+
+		observer, closeOutput, err := applyOutput.observer()
+		if err != nil {
+			return err
+		}
+		defer closeOutput()
+
+		applied := newSucceeded()
+
 		a := applier.NewApplier(client)
 
 		opts := &applier.ApplyOptions{
-			DryRun: dryRun,
-			Writer: os.Stdout,
+			DryRun:          dryRun,
+			Writer:          applyOutput.writer(os.Stdout),
+			Names:           names,
+			Journal:         journal,
+			Atomic:          atomic,
+			OnDrift:         driftPolicy,
+			Selected:        selected,
+			Observer:        withSucceeded(observer, applied),
+			Parallelism:     parallelism,
+			ContinueOnError: continueOnError,
+			RecordState:     recordState,
 		}
 
-		if err := a.Apply(p, opts); err != nil {
+		ctx, cancel := options.WithTimeout(cmd.Context())
+		defer cancel()
+
+		if err := a.ApplyContext(ctx, p, opts); err != nil {
+			reportInterruption(err)
+
+			var rollbackErr *applier.RollbackError
+			if errors.As(err, &rollbackErr) && rollbackErr.Complete() {
+				fmt.Fprintf(os.Stderr, "Rolled back %d operations, the server is back to its previous state\n",
+					len(rollbackErr.RolledBack))
+			} else if journal != nil && !dryRun {
+				fmt.Fprintf(os.Stderr, "Progress recorded in %s, re-run with --resume to continue\n", journal.Path())
+			}
+
+			// Operations applied before the failure can be reverted by ID
+			if !dryRun && !skipHistory {
+				archivePlan(p, planFile, journal, applied, resume)
+			}
+
+			var opsErr *applier.OperationsError
+			if continueOnError && errors.As(err, &opsErr) {
+				if err := reportFailures(p, opsErr, failedPlanPath); err != nil {
+					return err
+				}
+
+				return fmt.Errorf("applying plan: %d operations failed", len(opsErr.Errors))
+			}
+
 			return fmt.Errorf("applying plan: %w", err)
 		}
 
 		if !dryRun {
-			fmt.Fprintf(os.Stderr, "Successfully applied plan with %d operations\n", len(p.Operations))
+			fmt.Fprintf(os.Stderr, "Successfully applied plan with %d operations\n", count)
+
+			if !skipHistory {
+				archivePlan(p, planFile, journal, applied, resume)
+			}
+		}
+
+		if verify {
+			return verifyApplied(ctx, client, a, p, selected)
 		}
 
 		return nil
 	},
 }
 
+// checkProvenance refuses plans generated for another server unless
+// allowMismatch is set, and warns about plans edited after generation.
+func checkProvenance(p *plan.Plan, serverURL string, allowMismatch bool) error {
+	if err := p.CheckServer(serverURL); err != nil {
+		if !allowMismatch {
+			return fmt.Errorf("%w: pass --allow-server-mismatch to use it anyway", err)
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	modified, err := p.Modified()
+	if err != nil {
+		return fmt.Errorf("checking plan hash: %w", err)
+	}
+
+	if modified {
+		fmt.Fprintln(os.Stderr, "Warning: plan operations were modified after the plan was generated")
+	}
+
+	return nil
+}
+
+// openJournal opens the journal for a plan. An explicit path always wins,
+// otherwise the journal lives next to the plan file. Plans read from stdin
+// have no journal unless one is given explicitly. A journal written for a
+// plan with different operations is refused, since its operation numbers
+// do not refer to the operations of p.
+func openJournal(p *plan.Plan, planFile, explicitPath string) (*plan.Journal, error) {
+	path := explicitPath
+	if path == "" && planFile != "" {
+		path = plan.JournalPath(planFile)
+	}
+
+	if path == "" {
+		return nil, nil //nolint: nilnil
+	}
+
+	journal, err := plan.OpenJournal(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading journal: %w", err)
+	}
+
+	if err := journal.Bind(p); err != nil {
+		return nil, fmt.Errorf("%w: remove the journal if the plan was regenerated", err)
+	}
+
+	return journal, nil
+}
+
+// reportFailures lists the operations that failed in a run that continued
+// on error and, unless path is empty, writes them to path as a plan of
+// their own so that they can be applied again once the cause is fixed.
+// Values the failed operations need from operations that were applied are
+// filled in, so the failed plan does not depend on them.
+func reportFailures(p *plan.Plan, opsErr *applier.OperationsError, path string) error {
+	for _, failure := range opsErr.Errors {
+		detail := failure.Error()
+
+		var apiErr *immich.APIError
+		if errors.As(failure, &apiErr) {
+			detail = apiErr.Summary()
+		}
+
+		fmt.Fprintf(os.Stderr, "Operation %d failed: %s\n", failure.Operation+1, detail)
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	failedPlan, err := p.Retry(opsErr.Operations(), opsErr.Captured)
+	if err != nil {
+		return fmt.Errorf("selecting failed operations: %w", err)
+	}
+
+	if err := failedPlan.Save(path); err != nil {
+		return fmt.Errorf("writing failed operations: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d of %d operations failed and were written to %s, "+
+		"fix the cause and apply it to retry them\n", len(opsErr.Errors), len(p.Operations), path)
+
+	return nil
+}
+
+// reportInterruption prints exactly which operations completed when a
+// command was interrupted before finishing the plan.
+func reportInterruption(err error) {
+	var interrupted *applier.InterruptedError
+	if !errors.As(err, &interrupted) {
+		return
+	}
+
+	completed := make([]string, len(interrupted.Completed))
+	for k, i := range interrupted.Completed {
+		completed[k] = strconv.Itoa(i + 1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Interrupted: %d operations completed [%s], %d not started\n",
+		len(completed), strings.Join(completed, ", "), interrupted.Remaining)
+}
+
 func init() {
 	applyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print operations that would be performed without executing them")
+	applyCmd.Flags().StringVar(&journalPath, "journal", "",
+		"Path of the execution journal (defaults to <plan-file>.journal)")
+	applyCmd.Flags().BoolVar(&resume, "resume", false,
+		"Continue a previously interrupted apply, skipping operations recorded in the journal")
+	applyCmd.Flags().BoolVar(&atomic, "atomic", false,
+		"Revert all operations applied in this run if any operation fails")
+	applyCmd.Flags().BoolVar(&continueOnError, "continue-on-error", false,
+		"Keep applying the remaining operations when one fails and report every failure at the end")
+	applyCmd.Flags().StringVar(&failedPlanPath, "failed-plan", "failed.json",
+		"Where --continue-on-error writes a plan holding only the failed operations (empty to disable)")
+	applyCmd.Flags().BoolVar(&verify, "verify", false,
+		"After applying, check that the server reached the state the plan intended")
+	applyCmd.Flags().IntVar(&parallelism, "parallelism", 1,
+		"Number of operations to apply concurrently (operations on the same album still run in order)")
+	applyCmd.Flags().DurationVar(&maxAge, "max-age", 0,
+		"Refuse plans generated longer ago than this (0 disables the check)")
+	applyCmd.Flags().BoolVar(&allowServerMismatch, "allow-server-mismatch", false,
+		"Apply a plan that was generated against a different server")
+	applyCmd.Flags().StringVar(&onDrift, "on-drift", string(applier.DriftFail),
+		"What to do when an operation's preconditions no longer hold: fail, skip or force")
+	applyCmd.Flags().BoolVar(&skipValidation, "skip-validation", false,
+		"Apply the plan even if validation finds errors")
+	applyCmd.Flags().BoolVar(&requireSignature, "require-signature", false,
+		"Refuse plans that are unsigned, signed by a key that is not trusted, or modified after signing")
+	addTrustedKeysFlag(applyCmd)
+	applyCmd.Flags().BoolVar(&recordState, "record-state", false,
+		"Read what each operation changes right before applying it and journal a revert restoring exactly that state")
+	applyCmd.Flags().BoolVar(&skipHistory, "no-history", false,
+		"Do not record the applied plan in the history")
+	addHistoryFlag(applyCmd)
+	applySelection.register(applyCmd, "apply")
+	applyOutput.register(applyCmd)
+	options.AddClientFlags(applyCmd)
 	rootCmd.AddCommand(applyCmd)
 }
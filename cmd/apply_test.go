@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"immich-manager/pkg/plan"
+)
+
+func TestOpenJournal_RegeneratedPlan(t *testing.T) {
+	t.Parallel()
+
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	original := &plan.Plan{Operations: []plan.Operation{{
+		Intents: []plan.Intent{{Kind: plan.IntentAlbumRename, AlbumID: "a1", Name: "New", From: "Old"}},
+	}}}
+	regenerated := &plan.Plan{Operations: []plan.Operation{{
+		Intents: []plan.Intent{{Kind: plan.IntentAlbumRename, AlbumID: "a2", Name: "New", From: "Old"}},
+	}}}
+
+	journal, err := openJournal(original, planFile, "")
+	if err != nil {
+		t.Fatalf("openJournal() error = %v", err)
+	}
+
+	if err := journal.Record(plan.JournalEntry{Operation: 0, Action: plan.ActionApply}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	if err := journal.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if _, err := openJournal(regenerated, planFile, ""); !errors.Is(err, plan.ErrJournalMismatch) {
+		t.Errorf("openJournal() for a regenerated plan error = %v, want %v", err, plan.ErrJournalMismatch)
+	}
+
+	journal, err = openJournal(original, planFile, "")
+	if err != nil {
+		t.Fatalf("openJournal() for the same plan error = %v", err)
+	}
+
+	if !journal.Applied(0) {
+		t.Error("Expected operation 0 to be applied")
+	}
+}
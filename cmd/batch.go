@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+// defaultBatchSize is the largest number of assets per operation that
+// plan batch leaves when --size is not given.
+const defaultBatchSize = 1000
+
+var batchSize int
+
+var planBatchCmd = &cobra.Command{
+	Use:   "batch [plan-file]",
+	Short: "Merge small and split large asset operations of a plan (use '-' or omit to read from stdin)",
+	Long: `Output the plan with operations adding or removing assets of an album
+regrouped into operations of at most --size assets. Small operations on the
+same album are merged first, as long as no operation in between touches the
+album, then operations with more assets are split. Every resulting operation
+is reverted by the inverse of its own change.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		p, err := loadPlanArg(args)
+		if err != nil {
+			return err
+		}
+
+		total := len(p.Operations)
+
+		merged, split, err := p.Batch(batchSize, immich.ParseRequest, immich.BuildRequest)
+		if err != nil {
+			return fmt.Errorf("batching plan: %w", err)
+		}
+
+		if merged+split > 0 {
+			if err := restamp(p); err != nil {
+				return err
+			}
+		}
+
+		if err := p.Write(os.Stdout); err != nil {
+			return fmt.Errorf("writing plan: %w", err)
+		}
+
+		fmt.Fprintf(os.Stderr, "Merged %d and split %d of %d operations into %d operations of at most %d assets\n",
+			merged, split, total, len(p.Operations), batchSize)
+
+		return nil
+	},
+}
+
+func init() {
+	planBatchCmd.Flags().IntVar(&batchSize, "size", defaultBatchSize,
+		"Largest number of assets an operation adds to or removes from an album")
+	planCmd.AddCommand(planBatchCmd)
+}
+
+// restamp records rewritten operations in the metadata of p, keeping its
+// generator, so that the plan is not reported as modified. Signatures do
+// not cover the rewritten operations, so they are dropped with a warning
+// and the plan has to be signed again.
+func restamp(p *plan.Plan) error {
+	if len(p.Signatures) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: dropping %d signatures, which do not cover the rewritten plan: sign it again\n",
+			len(p.Signatures))
+
+		p.Signatures = nil
+	}
+
+	if p.Metadata == nil {
+		return nil
+	}
+
+	if err := p.Stamp(*p.Metadata); err != nil {
+		return fmt.Errorf("stamping plan: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+func TestRestamp_SignedPlan(t *testing.T) {
+	t.Parallel()
+
+	add := plan.Intent{
+		Kind: plan.IntentAlbumAddAssets, AlbumID: "0b6e1a52-1f9c-4c53-9f0a-6f1f7f0d2c11",
+		AssetIDs: []string{"a1", "a2", "a3"},
+	}
+
+	apply, err := immich.BuildRequest(add)
+	if err != nil {
+		t.Fatalf("BuildRequest() error = %v", err)
+	}
+
+	inverse, _ := add.Inverse()
+
+	revert, err := immich.BuildRequest(inverse)
+	if err != nil {
+		t.Fatalf("BuildRequest() error = %v", err)
+	}
+
+	p := &plan.Plan{Operations: []plan.Operation{{Apply: []plan.Request{apply}, Revert: []plan.Request{revert}}}}
+	if err := p.Stamp(plan.Metadata{Generator: "albums smart"}); err != nil {
+		t.Fatalf("Stamp() error = %v", err)
+	}
+
+	key, err := plan.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	if err := p.Sign(key, time.Now()); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	if _, split, err := p.Batch(2, immich.ParseRequest, immich.BuildRequest); err != nil || split != 1 {
+		t.Fatalf("Batch() = %d, %v; want 1 operation split", split, err)
+	}
+
+	// Batching alone leaves the plan reported as modified since it was
+	// generated and signed
+	if modified, err := p.Modified(); err != nil || !modified {
+		t.Errorf("Modified() after Batch() = %v, %v; want true", modified, err)
+	}
+
+	if err := restamp(p); err != nil {
+		t.Fatalf("restamp() error = %v", err)
+	}
+
+	if len(p.Signatures) != 0 {
+		t.Errorf("Expected the signatures to be dropped, got %d", len(p.Signatures))
+	}
+
+	if modified, err := p.Modified(); err != nil || modified {
+		t.Errorf("Modified() after restamp() = %v, %v; want false", modified, err)
+	}
+
+	if p.Metadata.Generator != "albums smart" {
+		t.Errorf("Generator = %q, want it kept", p.Metadata.Generator)
+	}
+}
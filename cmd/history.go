@@ -0,0 +1,392 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"immich-manager/cmd/options"
+	"immich-manager/pkg/history"
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/immich/applier"
+	"immich-manager/pkg/immich/describe"
+	"immich-manager/pkg/plan"
+)
+
+// generatorWidth is the widest generator description shown by history list.
+const generatorWidth = 60
+
+var (
+	historyDir      string
+	historyOffline  bool
+	skipHistory     bool
+	revertHistoryID string
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List and inspect applied plans",
+}
+
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List applied plans, oldest first",
+	Args:  cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+
+		entries, err := store.List()
+		if err != nil {
+			return err
+		}
+
+		if len(entries) == 0 {
+			fmt.Fprintf(os.Stderr, "No plans recorded in %s\n", store.Dir())
+
+			return nil
+		}
+
+		for _, entry := range entries {
+			applied, err := store.Applied(entry.ID)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("%s  %s  %d/%d applied  %s\n", entry.ID, entry.AppliedAt.Local().Format(time.DateTime),
+				len(applied), entry.Operations, truncate(entry.Describe(), generatorWidth))
+		}
+
+		return nil
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Describe an applied plan and which of its operations are still applied",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+
+		entry, err := store.Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		p, err := store.Load(entry.ID)
+		if err != nil {
+			return err
+		}
+
+		applied, err := store.Applied(entry.ID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("ID:         %s\n", entry.ID)
+		fmt.Printf("Applied at: %s\n", entry.AppliedAt.Local().Format(time.DateTime))
+		fmt.Printf("Generator:  %s\n", entry.Describe())
+
+		if entry.PlanFile != "" {
+			fmt.Printf("Plan file:  %s\n", entry.PlanFile)
+		}
+
+		fmt.Printf("Archived:   %s\n", store.PlanPath(entry.ID))
+		fmt.Printf("Applied:    %d of %d operations\n\n", len(applied), entry.Operations)
+
+		var client *immich.Client
+
+		if !historyOffline {
+			client, err = options.NewClient()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Using names embedded in the plan only: %v\n", err)
+
+				client = nil
+			}
+		}
+
+		ctx, cancel := options.WithTimeout(cmd.Context())
+		defer cancel()
+
+		names := describe.NewNames(client, p.Names)
+
+		return describe.Plan(ctx, names, p).Render(os.Stdout, describe.FormatText)
+	},
+}
+
+// historyStore returns the store selected by --history-dir.
+func historyStore() (*history.Store, error) {
+	if historyDir != "" {
+		return history.NewStore(historyDir), nil
+	}
+
+	dir, err := history.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return history.NewStore(dir), nil
+}
+
+// addHistoryFlag registers --history-dir on cmd.
+func addHistoryFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&historyDir, "history-dir", "",
+		"Directory of the plan history (defaults to $XDG_STATE_HOME/immich-manager or ~/.local/state/immich-manager)")
+}
+
+// succeeded records the operations that completed in a run, the values
+// captured from their responses and the reverts recorded from the server
+// state. Events are delivered one at a time, so it needs no locking.
+type succeeded struct {
+	operations map[int]bool
+	captures   map[int]map[string]string
+	reverts    map[int][]plan.Intent
+}
+
+// newSucceeded returns an empty record.
+func newSucceeded() *succeeded {
+	return &succeeded{
+		operations: make(map[int]bool),
+		captures:   make(map[int]map[string]string),
+		reverts:    make(map[int][]plan.Intent),
+	}
+}
+
+// Observe implements applier.Observer.
+func (s *succeeded) Observe(event applier.Event) {
+	switch event.Type {
+	case applier.EventOperationSucceeded:
+		s.operations[event.Operation] = true
+		s.captures[event.Operation] = event.Captures
+
+		if event.Revert != nil {
+			s.reverts[event.Operation] = event.Revert
+		}
+	case applier.EventOperationRolledBack:
+		delete(s.operations, event.Operation)
+		delete(s.captures, event.Operation)
+		delete(s.reverts, event.Operation)
+	}
+}
+
+// entries returns a journal entry with the given action for each
+// operation that succeeded, in plan order.
+func (s *succeeded) entries(action plan.Action, total int) []plan.JournalEntry {
+	var entries []plan.JournalEntry
+
+	for i := range total {
+		if s.operations[i] {
+			revert, exact := s.reverts[i]
+			entries = append(entries, plan.JournalEntry{
+				Operation: i, Action: action, Captures: s.captures[i], Revert: revert, Exact: exact,
+			})
+		}
+	}
+
+	return entries
+}
+
+// withSucceeded adds s to the observer selected by the output flags.
+func withSucceeded(observer applier.Observer, s *succeeded) applier.Observer {
+	if observer == nil {
+		return s
+	}
+
+	return applier.Observers{observer, s}
+}
+
+// archivePlan records a plan that was applied, in full or in part, in the
+// history. The journal, when there is one, is archived with it, otherwise
+// the operations that succeeded in this run are recorded as applied.
+// Nothing is recorded when no operation is applied. A resumed run adds
+// the operations it applied to the entry of the run it continues, so the
+// plan keeps a single entry. Failures are warnings: the operations have
+// been applied either way.
+func archivePlan(p *plan.Plan, planFile string, journal *plan.Journal, applied *succeeded, resume bool) {
+	entries := applied.entries(plan.ActionApply, len(p.Operations))
+	if len(entries) == 0 && (journal == nil || len(journal.AppliedOperations()) == 0) {
+		return
+	}
+
+	store, err := historyStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: recording plan in history: %v\n", err)
+
+		return
+	}
+
+	if resume {
+		if entry, err := findEntry(store, p); err == nil && entry != nil && entry.PlanFile != "" &&
+			samePath(entry.PlanFile, planFile) {
+			if err := recordEntries(store.JournalPath(entry.ID), "", entries); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: updating history entry %s: %v\n", entry.ID, err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Recorded in history entry %s (revert with: immich-manager revert --id %s)\n",
+					entry.ID, entry.ID)
+			}
+
+			return
+		}
+	}
+
+	if journal != nil {
+		entries = journal.Entries()
+	}
+
+	entry, err := store.Add(p, planFile, planAlbums(p), entries, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: recording plan in history: %v\n", err)
+
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Recorded in history as %s (revert with: immich-manager revert --id %s)\n",
+		entry.ID, entry.ID)
+}
+
+// planAlbums returns the IDs of the albums touched by a plan.
+func planAlbums(p *plan.Plan) []string {
+	seen := make(map[string]bool)
+
+	var albums []string
+
+	for _, op := range p.Operations {
+		for _, id := range immich.OperationAlbums(op) {
+			if !seen[id] {
+				seen[id] = true
+
+				albums = append(albums, id)
+			}
+		}
+	}
+
+	return albums
+}
+
+// findEntry returns the latest history entry of a plan with the same
+// operations as p, or nil when p is not in the history.
+func findEntry(store *history.Store, p *plan.Plan) (*history.Entry, error) {
+	hash, err := p.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := store.FindHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, nil //nolint: nilnil
+	}
+
+	return entries[len(entries)-1], nil
+}
+
+// warnOverlapping warns when plans applied after entry touched the same
+// albums, since reverting entry may then undo or conflict with their
+// changes.
+func warnOverlapping(store *history.Store, entry *history.Entry) {
+	later, err := store.LaterOverlapping(entry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: checking later plans: %v\n", err)
+
+		return
+	}
+
+	for _, other := range later {
+		fmt.Fprintf(os.Stderr, "Warning: plan %s (%s) was applied later and touches the same albums, "+
+			"consider reverting it first\n", other.ID, other.Describe())
+	}
+}
+
+// recordReverted records the operations reverted in a run that used the
+// journal at used in the other journals of the same plan: that of its
+// history entry, and that next to the file it was applied from, unless
+// the file has changed since.
+func recordReverted(store *history.Store, entry *history.Entry, used string, reverted *succeeded) {
+	entries := reverted.entries(plan.ActionRevert, entry.Operations)
+	if len(entries) == 0 {
+		return
+	}
+
+	if err := recordEntries(store.JournalPath(entry.ID), used, entries); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: updating history entry %s: %v\n", entry.ID, err)
+	}
+
+	if entry.PlanFile == "" {
+		return
+	}
+
+	if p, err := plan.Load(entry.PlanFile); err != nil || !sameHash(p, entry.Hash) {
+		return
+	}
+
+	if err := recordEntries(plan.JournalPath(entry.PlanFile), used, entries); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: updating journal of %s: %v\n", entry.PlanFile, err)
+	}
+}
+
+// recordEntries appends entries to the journal at path, unless it is the
+// journal at used, which the run already updated.
+func recordEntries(path, used string, entries []plan.JournalEntry) error {
+	if samePath(path, used) {
+		return nil
+	}
+
+	journal, err := plan.OpenJournal(path)
+	if err != nil {
+		return err
+	}
+
+	for _, journalEntry := range entries {
+		if err = journal.Record(journalEntry); err != nil {
+			break
+		}
+	}
+
+	return errors.Join(err, journal.Close())
+}
+
+// sameHash reports whether the operations of p have the given hash.
+func sameHash(p *plan.Plan, hash string) bool {
+	got, err := p.Hash()
+
+	return err == nil && got == hash
+}
+
+// samePath reports whether a and b refer to the same file.
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+
+	return errA == nil && errB == nil && absA == absB
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+
+	return string(runes[:n-1]) + "…"
+}
+
+func init() {
+	historyShowCmd.Flags().BoolVar(&historyOffline, "offline", false,
+		"Only use names embedded in the plan instead of looking them up on the server")
+	addHistoryFlag(historyListCmd)
+	addHistoryFlag(historyShowCmd)
+	historyCmd.AddCommand(historyListCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"immich-manager/pkg/history"
+	"immich-manager/pkg/immich/applier"
+	"immich-manager/pkg/plan"
+)
+
+func TestArchivePlan_PartialAndResumed(t *testing.T) {
+	t.Parallel()
+
+	// archivePlan uses --history-dir, which no other test sets
+	historyDir = t.TempDir()
+
+	albumID := "0b6e1a52-1f9c-4c53-9f0a-6f1f7f0d2c11"
+	rename := func(name string) plan.Operation {
+		return plan.Operation{
+			Apply: []plan.Request{
+				{Path: "/api/albums/" + albumID, Method: http.MethodPatch, Body: []byte(`{"albumName":"` + name + `"}`)},
+			},
+			Revert: []plan.Request{
+				{Path: "/api/albums/" + albumID, Method: http.MethodPatch, Body: []byte(`{"albumName":"old"}`)},
+			},
+		}
+	}
+
+	p := &plan.Plan{Operations: []plan.Operation{rename("first"), rename("second")}}
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+
+	if err := p.Save(planFile); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	journal, err := plan.OpenJournal(plan.JournalPath(planFile))
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+	defer func() { _ = journal.Close() }()
+
+	// run records operation i as applied in the journal, as the applier
+	// does, and archives the run
+	run := func(i int, resume bool) {
+		t.Helper()
+
+		if err := journal.Record(plan.JournalEntry{Operation: i, Action: plan.ActionApply}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+
+		applied := newSucceeded()
+		applied.Observe(applier.Event{Type: applier.EventOperationSucceeded, Operation: i})
+
+		archivePlan(p, planFile, journal, applied, resume)
+	}
+
+	// A run that failed after applying the first operation is archived
+	run(0, false)
+
+	store := history.NewStore(historyDir)
+
+	entries, err := store.List()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("List() after a failed run = %v, %v; want one entry", entries, err)
+	}
+
+	// Resuming it records the rest in the same entry
+	run(1, true)
+
+	if entries, err = store.List(); err != nil || len(entries) != 1 {
+		t.Fatalf("List() after resuming = %v, %v; want one entry", entries, err)
+	}
+
+	if got, err := store.Applied(entries[0].ID); err != nil || !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Errorf("Applied() = %v, %v; want both operations", got, err)
+	}
+}
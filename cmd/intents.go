@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"immich-manager/pkg/immich"
+)
+
+var planIntentsCmd = &cobra.Command{
+	Use:   "intents [plan-file]",
+	Short: "Rewrite the requests of a plan as intents (use '-' or omit to read from stdin)",
+	Long: `Output the plan with every operation whose requests are understood rewritten
+as intents, such as album.rename or album.addUser. Intents are compiled to
+requests for the version of the server when the plan is applied. Operations
+that cannot be expressed as intents keep their requests.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		p, err := loadPlanArg(args)
+		if err != nil {
+			return err
+		}
+
+		lifted := p.Lift(immich.ParseRequest)
+		if lifted > 0 {
+			if err := restamp(p); err != nil {
+				return err
+			}
+		}
+
+		if err := p.Write(os.Stdout); err != nil {
+			return fmt.Errorf("writing plan: %w", err)
+		}
+
+		fmt.Fprintf(os.Stderr, "Rewrote %d of %d operations as intents\n", lifted, len(p.Operations))
+
+		return nil
+	},
+}
+
+func init() {
+	planCmd.AddCommand(planIntentsCmd)
+}
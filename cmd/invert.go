@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+var invertJournalPath string
+
+var planInvertCmd = &cobra.Command{
+	Use:   "invert [plan-file]",
+	Short: "Output a plan that undoes a plan (use '-' or omit to read from stdin)",
+	Long: `Output a plan whose operations are those of the given plan in reverse order,
+with their apply and revert requests swapped. Applying the inverted plan
+reverts the original one, so it can be reviewed, validated and merged like
+any other plan.
+
+Operations that depended on others are undone first, and values the plan
+captured when it was applied, such as the IDs of albums it created, are
+filled in from its journal.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		p, err := loadPlanArg(args)
+		if err != nil {
+			return err
+		}
+
+		captured := make(plan.Captures)
+
+		if len(p.CapturedFields()) > 0 {
+			planFile := ""
+			if len(args) > 0 && args[0] != "-" {
+				planFile = args[0]
+			}
+
+			journal, err := openJournal(p, planFile, invertJournalPath)
+			if err != nil {
+				return err
+			}
+
+			if journal == nil {
+				return errors.New("the plan uses values captured when it was applied: " +
+					"pass --journal when reading the plan from stdin")
+			}
+
+			captured = journal.Captures(p)
+		}
+
+		inverted, err := plan.Undo(p, captured, immich.ParseRequest)
+		if errors.Is(err, plan.ErrNotCaptured) {
+			return fmt.Errorf("inverting plan: %w (only applied plans whose journal records the values can be inverted)", err)
+		}
+
+		if err != nil {
+			return fmt.Errorf("inverting plan: %w", err)
+		}
+
+		if err := inverted.Write(os.Stdout); err != nil {
+			return fmt.Errorf("writing plan: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	planInvertCmd.Flags().StringVar(&invertJournalPath, "journal", "",
+		"Journal of the applied plan holding the values it captured (defaults to <plan-file>.journal)")
+	planCmd.AddCommand(planInvertCmd)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/immich/describe"
+	"immich-manager/pkg/plan"
+)
+
+var mergeAllowConflicts bool
+
+var planMergeCmd = &cobra.Command{
+	Use:   "merge [plan-file]...",
+	Short: "Combine several plans into one, dropping duplicate operations and reporting conflicts",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plans := make([]*plan.Plan, 0, len(args))
+
+		for _, path := range args {
+			p, err := plan.Load(path)
+			if err != nil {
+				return fmt.Errorf("loading plan %s: %w", path, err)
+			}
+
+			plans = append(plans, p)
+		}
+
+		merged, conflicts, err := plan.Merge(plans, immich.ParseRequest)
+		if err != nil {
+			return fmt.Errorf("merging plans: %w", err)
+		}
+
+		if merged.Metadata != nil {
+			merged.Metadata.Args = args
+		}
+
+		reportConflicts(cmd.Context(), args, plans, merged.Names, conflicts)
+
+		if len(conflicts) > 0 && !mergeAllowConflicts {
+			return fmt.Errorf("found %d conflicts: resolve them or pass --allow-conflicts", len(conflicts))
+		}
+
+		total := 0
+		for _, p := range plans {
+			total += len(p.Operations)
+		}
+
+		fmt.Fprintf(os.Stderr, "Merged %d plans: %d operations, %d duplicates removed\n",
+			len(plans), len(merged.Operations), total-len(merged.Operations))
+
+		if err := merged.Write(os.Stdout); err != nil {
+			return fmt.Errorf("writing plan: %w", err)
+		}
+
+		return nil
+	},
+}
+
+// reportConflicts prints each conflict with a description of both
+// operations involved, using the names from all of the plans.
+func reportConflicts(
+	ctx context.Context, files []string, plans []*plan.Plan, names map[string]string, conflicts []plan.Conflict,
+) {
+	resolver := describe.NewNames(nil, names)
+
+	describeRef := func(ref plan.OperationRef) string {
+		op := plans[ref.Plan].Operations[ref.Operation]
+		sentences := describe.Apply(ctx, resolver, op)
+
+		return fmt.Sprintf("%s operation %d (%s)", files[ref.Plan], ref.Operation+1, strings.Join(sentences, "; "))
+	}
+
+	for _, conflict := range conflicts {
+		fmt.Fprintf(os.Stderr, "Conflict: %s\n  %s\n  %s\n",
+			conflict.Reason, describeRef(conflict.First), describeRef(conflict.Second))
+	}
+}
+
+func init() {
+	planMergeCmd.Flags().BoolVar(&mergeAllowConflicts, "allow-conflicts", false,
+		"Output the merged plan even if operations conflict")
+	planCmd.AddCommand(planMergeCmd)
+}
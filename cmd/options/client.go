@@ -0,0 +1,65 @@
+// Package options provides configuration shared by several commands.
+package options
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"immich-manager/pkg/immich"
+)
+
+var (
+	retryPolicy    = immich.DefaultRetryPolicy()
+	requestTimeout time.Duration
+	timeout        time.Duration
+)
+
+// AddClientFlags registers the flags that configure the Immich client on
+// cmd and, through persistent flags, on all of its subcommands.
+func AddClientFlags(cmd *cobra.Command) {
+	flags := cmd.PersistentFlags()
+	flags.IntVar(&retryPolicy.MaxRetries, "retries", retryPolicy.MaxRetries,
+		"Number of times to retry a request that failed with a transient error (0 disables retries)")
+	flags.DurationVar(&retryPolicy.InitialBackoff, "retry-backoff", retryPolicy.InitialBackoff,
+		"Wait before the first retry, doubled on each further retry")
+	flags.DurationVar(&retryPolicy.MaxBackoff, "retry-max-backoff", retryPolicy.MaxBackoff,
+		"Maximum wait between retries")
+	flags.DurationVar(&retryPolicy.MaxRetryAfter, "retry-max-after", retryPolicy.MaxRetryAfter,
+		"Maximum wait honoured from a Retry-After header (0 uses --retry-max-backoff)")
+	flags.DurationVar(&requestTimeout, "request-timeout", 0,
+		"Maximum duration of a single request attempt (0 means no limit)")
+	flags.DurationVar(&timeout, "timeout", 0,
+		"Maximum duration of the whole command (0 means no limit)")
+}
+
+// WithTimeout derives a context from ctx that honours the --timeout flag.
+func WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// NewClient returns an Immich client configured from the IMMICH_SERVER and
+// IMMICH_TOKEN environment variables and the client flags.
+func NewClient() (*immich.Client, error) {
+	token := os.Getenv("IMMICH_TOKEN")
+	if token == "" {
+		return nil, errors.New("IMMICH_TOKEN environment variable is required")
+	}
+
+	server := os.Getenv("IMMICH_SERVER")
+	if server == "" {
+		return nil, errors.New("IMMICH_SERVER environment variable is required")
+	}
+
+	client := immich.NewClient(server, token)
+	client.SetRetryPolicy(retryPolicy)
+	client.SetTimeout(requestTimeout)
+
+	return client, nil
+}
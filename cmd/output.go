@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"immich-manager/pkg/immich/applier"
+	"immich-manager/pkg/immich/report"
+)
+
+// Progress output formats accepted by --output.
+const (
+	outputText  = "text"
+	outputJSONL = "jsonl"
+)
+
+// eventOutput holds the flags that control how progress is reported while
+// a plan is applied or reverted, and where the report of the run goes.
+type eventOutput struct {
+	format string
+	file   string
+
+	report       string
+	reportFormat string
+
+	// progress is the progress bar drawn by the observer, if any.
+	progress *applier.Progress
+}
+
+// register adds the progress output flags to cmd.
+func (o *eventOutput) register(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVar(&o.format, "output", outputText,
+		"Progress output: text (a progress bar when stderr is a terminal) or jsonl (JSON Lines events)")
+	flags.StringVar(&o.file, "output-file", "",
+		"Write --output=jsonl events to this file instead of stderr")
+	flags.StringVar(&o.report, "report", "",
+		"Write a report of every request sent to this file")
+	flags.StringVar(&o.reportFormat, "report-format", "",
+		"Report format: json, junit or markdown (defaults to junit for .xml, markdown for .md and json otherwise)")
+}
+
+// observer returns the observer selected by the flags, which may be nil,
+// and a function that releases it once execution has finished. Failures
+// to write events are reported as warnings rather than failing the command.
+func (o *eventOutput) observer() (applier.Observer, func(), error) {
+	format := report.FormatFor(o.report)
+
+	if o.reportFormat != "" {
+		var err error
+		if format, err = report.ParseFormat(o.reportFormat); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	observer, closeOutput, err := o.open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	warn := func(err error) {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	if o.report == "" {
+		return observer, func() { warn(closeOutput()) }, nil
+	}
+
+	record := report.New()
+
+	observers := applier.Observers{record}
+	if observer != nil {
+		observers = append(observers, observer)
+	}
+
+	return observers, func() {
+		warn(closeOutput())
+		warn(writeReport(record, o.report, format))
+	}, nil
+}
+
+// writer returns the writer for warnings and dry run output to w, which
+// keeps them apart from the progress bar when one is drawn.
+func (o *eventOutput) writer(w io.Writer) io.Writer {
+	if o.progress == nil {
+		return w
+	}
+
+	return o.progress.Above(w)
+}
+
+// writeReport saves the report of a run to path.
+func writeReport(record *report.Report, path string, format report.Format) error {
+	//nolint: gosec
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating report: %w", err)
+	}
+
+	if err := record.Render(f, format); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing report: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %s report to %s\n", format, path)
+
+	return nil
+}
+
+// open creates the observer selected by the flags.
+func (o *eventOutput) open() (applier.Observer, func() error, error) {
+	noop := func() error { return nil }
+
+	switch o.format {
+	case outputText:
+		if o.file != "" {
+			return nil, nil, fmt.Errorf("--output-file requires --output=%s", outputJSONL)
+		}
+
+		if !isTerminal(os.Stderr) {
+			return nil, noop, nil
+		}
+
+		o.progress = applier.NewProgress(os.Stderr)
+
+		return o.progress, noop, nil
+	case outputJSONL:
+		if o.file == "" {
+			events := applier.NewJSONLines(os.Stderr)
+
+			return events, events.Err, nil
+		}
+
+		//nolint: gosec
+		f, err := os.Create(o.file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating output file: %w", err)
+		}
+
+		events := applier.NewJSONLines(f)
+
+		return events, func() error {
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("closing output file: %w", err)
+			}
+
+			return events.Err()
+		}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown output format %q: must be %s or %s", o.format, outputText, outputJSONL)
+	}
+}
+
+// isTerminal reports whether f is a character device such as a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
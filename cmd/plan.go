@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"immich-manager/cmd/options"
 )
 
 var planCmd = &cobra.Command{
@@ -10,5 +11,6 @@ var planCmd = &cobra.Command{
 }
 
 func init() {
+	options.AddClientFlags(planCmd)
 	rootCmd.AddCommand(planCmd)
 }
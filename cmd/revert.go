@@ -6,28 +6,67 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
-	"immich-manager/pkg/immich"
+	"immich-manager/cmd/options"
+	"immich-manager/pkg/history"
 	"immich-manager/pkg/immich/applier"
+	"immich-manager/pkg/immich/describe"
 	"immich-manager/pkg/plan"
 )
 
-var revertDryRun bool
+var (
+	revertDryRun      bool
+	revertJournalPath string
+	revertAll         bool
+	revertParallelism int
+
+	revertAllowServerMismatch bool
+	revertAtomic              bool
+	revertOnDrift             string
+	revertSkipValidation      bool
+
+	revertSelection selection
+	revertOutput    eventOutput
+)
 
 var revertCmd = &cobra.Command{
 	Use:   "revert [plan-file]",
-	Short: "Revert changes from a plan",
-	Args:  cobra.ExactArgs(1),
-	RunE: func(_ *cobra.Command, args []string) error {
-		planFile := args[0]
+	Short: "Revert changes from a plan, or from a plan in the history with --id",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (len(args) == 1) == (revertHistoryID != "") {
+			return errors.New("pass either a plan file or --id")
+		}
+
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+
+		var entry *history.Entry
+
+		var planFile string
+		if revertHistoryID != "" {
+			if entry, err = store.Get(revertHistoryID); err != nil {
+				return err
+			}
+
+			planFile = store.PlanPath(entry.ID)
+		} else {
+			planFile = args[0]
+		}
+
+		driftPolicy, err := applier.ParseDriftPolicy(revertOnDrift)
+		if err != nil {
+			return err
+		}
 
-		token := os.Getenv("IMMICH_TOKEN")
-		if token == "" {
-			return errors.New("IMMICH_TOKEN environment variable is required")
+		if revertParallelism < 1 {
+			return errors.New("--parallelism must be at least 1")
 		}
 
-		server := os.Getenv("IMMICH_SERVER")
-		if server == "" {
-			return errors.New("IMMICH_SERVER environment variable is required")
+		client, err := options.NewClient()
+		if err != nil {
+			return fmt.Errorf("creating client: %w", err)
 		}
 
 		p, err := plan.Load(planFile)
@@ -35,20 +74,106 @@ var revertCmd = &cobra.Command{
 			return fmt.Errorf("loading plan: %w", err)
 		}
 
-		client := immich.NewClient(server, token)
-		a := applier.NewApplier(client)
+		if entry == nil {
+			if entry, err = findEntry(store, p); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: looking up plan in history: %v\n", err)
+			}
+		}
+
+		if entry != nil {
+			warnOverlapping(store, entry)
+		}
+
+		if !revertSkipValidation {
+			if err := validatePlan(p, os.Stderr); err != nil {
+				return fmt.Errorf("%w: fix the plan or pass --skip-validation", err)
+			}
+		}
+
+		if err := checkProvenance(p, client.ServerURL(), revertAllowServerMismatch); err != nil {
+			return err
+		}
+
+		names := describe.NewNames(client, p.Names)
+
+		indices, err := revertSelection.resolve(cmd.Context(), p, names, "revert", true)
+		if err != nil {
+			return err
+		}
+
+		if err := revertSelection.write(p, indices); err != nil {
+			return err
+		}
+
+		selected := selectedFunc(indices)
+
+		journal, err := openJournal(p, planFile, revertJournalPath)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = journal.Close() }()
 
+		observer, closeOutput, err := revertOutput.observer()
+		if err != nil {
+			return err
+		}
+		defer closeOutput()
+
+		reverted := newSucceeded()
+
+		// The journal provides captured values and recorded reverts, and
+		// records each operation as it is reverted, even with --all
 		opts := &applier.ApplyOptions{
-			DryRun: revertDryRun,
-			Writer: os.Stdout,
+			DryRun:      revertDryRun,
+			Writer:      revertOutput.writer(os.Stdout),
+			Journal:     journal,
+			All:         revertAll,
+			Atomic:      revertAtomic,
+			OnDrift:     driftPolicy,
+			Names:       names,
+			Selected:    selected,
+			Observer:    withSucceeded(observer, reverted),
+			Parallelism: revertParallelism,
+		}
+
+		if !revertAll && len(journal.Entries()) == 0 {
+			fmt.Fprintf(os.Stderr, "No journal found at %s, reverting all operations\n", journal.Path())
+
+			opts.All = true
+		}
+
+		pendingJournal := journal
+		if opts.All {
+			pendingJournal = nil
 		}
 
-		if err := a.Revert(p, opts); err != nil {
+		count := len(pendingOperations(len(p.Operations), selected, pendingJournal, plan.ActionRevert))
+
+		a := applier.NewApplier(client)
+
+		ctx, cancel := options.WithTimeout(cmd.Context())
+		defer cancel()
+
+		err = a.RevertContext(ctx, p, opts)
+
+		if entry != nil && !revertDryRun {
+			recordReverted(store, entry, journal.Path(), reverted)
+		}
+
+		if err != nil {
+			reportInterruption(err)
+
+			var rollbackErr *applier.RollbackError
+			if errors.As(err, &rollbackErr) && rollbackErr.Complete() {
+				fmt.Fprintf(os.Stderr, "Re-applied %d operations, the server is back to its previous state\n",
+					len(rollbackErr.RolledBack))
+			}
+
 			return fmt.Errorf("reverting plan: %w", err)
 		}
 
 		if !revertDryRun {
-			fmt.Fprintf(os.Stderr, "Successfully reverted plan with %d operations\n", len(p.Operations))
+			fmt.Fprintf(os.Stderr, "Successfully reverted plan with %d operations\n", count)
 		}
 
 		return nil
@@ -58,5 +183,25 @@ var revertCmd = &cobra.Command{
 func init() {
 	revertCmd.Flags().BoolVar(&revertDryRun, "dry-run", false,
 		"Print operations that would be performed without executing them")
+	revertCmd.Flags().StringVar(&revertJournalPath, "journal", "",
+		"Path of the execution journal (defaults to <plan-file>.journal)")
+	revertCmd.Flags().BoolVar(&revertAll, "all", false,
+		"Revert every operation in the plan, not only those the journal records as applied")
+	revertCmd.Flags().BoolVar(&revertAllowServerMismatch, "allow-server-mismatch", false,
+		"Revert a plan that was generated against a different server")
+	revertCmd.Flags().IntVar(&revertParallelism, "parallelism", 1,
+		"Number of operations to revert concurrently (operations on the same album still run in order)")
+	revertCmd.Flags().BoolVar(&revertAtomic, "atomic", false,
+		"Re-apply all operations reverted in this run if any operation fails")
+	revertCmd.Flags().StringVar(&revertOnDrift, "on-drift", string(applier.DriftFail),
+		"What to do when an operation's preconditions no longer hold: fail, skip or force")
+	revertCmd.Flags().BoolVar(&revertSkipValidation, "skip-validation", false,
+		"Revert the plan even if validation finds errors")
+	revertCmd.Flags().StringVar(&revertHistoryID, "id", "",
+		"Revert the plan recorded in the history under this ID (or a unique prefix of it)")
+	addHistoryFlag(revertCmd)
+	revertSelection.register(revertCmd, "revert")
+	revertOutput.register(revertCmd)
+	options.AddClientFlags(revertCmd)
 	rootCmd.AddCommand(revertCmd)
 }
@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/immich/describe"
+	"immich-manager/pkg/plan"
+)
+
+// selection holds the flags that pick which operations of a plan to run.
+type selection struct {
+	only        string
+	skip        string
+	match       []string
+	interactive bool
+	output      string
+}
+
+// register adds the selection flags to cmd. verb names the action in help
+// text, such as "apply".
+func (s *selection) register(cmd *cobra.Command, verb string) {
+	flags := cmd.Flags()
+	flags.StringVar(&s.only, "only", "",
+		"Only "+verb+" these operations, e.g. 3,5-9")
+	flags.StringVar(&s.skip, "skip", "",
+		"Do not "+verb+" these operations, e.g. 2,4-6")
+	flags.StringSliceVar(&s.match, "match", nil,
+		"Only "+verb+" operations touching an album or user with this ID, or whose name contains this text")
+	flags.BoolVar(&s.interactive, "interactive", false,
+		"Show each operation and ask whether to "+verb+" it")
+	flags.StringVar(&s.output, "selected-plan", "",
+		"Write the selected operations to this file as a plan of their own")
+}
+
+// active reports whether any selection flag was given.
+func (s *selection) active() bool {
+	return s.only != "" || s.skip != "" || len(s.match) > 0 || s.interactive
+}
+
+// resolve returns the indices of the selected operations of p in plan
+// order, or nil when no selection flags were given. When reverse is set,
+// interactive prompts follow the reverse order in which a revert runs.
+func (s *selection) resolve(
+	ctx context.Context, p *plan.Plan, names *describe.Names, verb string, reverse bool,
+) ([]int, error) {
+	if !s.active() {
+		return nil, nil
+	}
+
+	selected := make(map[int]bool, len(p.Operations))
+	for i := range p.Operations {
+		selected[i] = true
+	}
+
+	if s.only != "" {
+		only, err := plan.ParseSelection(s.only, len(p.Operations))
+		if err != nil {
+			return nil, fmt.Errorf("parsing --only: %w", err)
+		}
+
+		keep := make(map[int]bool, len(only))
+		for _, i := range only {
+			keep[i] = true
+		}
+
+		for i := range selected {
+			selected[i] = keep[i]
+		}
+	}
+
+	if s.skip != "" {
+		skip, err := plan.ParseSelection(s.skip, len(p.Operations))
+		if err != nil {
+			return nil, fmt.Errorf("parsing --skip: %w", err)
+		}
+
+		for _, i := range skip {
+			selected[i] = false
+		}
+	}
+
+	for i, op := range p.Operations {
+		for _, term := range s.match {
+			if !op.Matches(term, p.Names, immich.ParseRequest) {
+				selected[i] = false
+			}
+		}
+	}
+
+	order := make([]int, 0, len(p.Operations))
+
+	for i := range p.Operations {
+		if selected[i] {
+			order = append(order, i)
+		}
+	}
+
+	if reverse {
+		for l, r := 0, len(order)-1; l < r; l, r = l+1, r-1 {
+			order[l], order[r] = order[r], order[l]
+		}
+	}
+
+	if s.interactive {
+		confirmed, err := confirmOperations(ctx, os.Stdin, os.Stderr, p, names, order, verb)
+		if err != nil {
+			return nil, err
+		}
+
+		order = confirmed
+	}
+
+	indices := make([]int, len(order))
+	copy(indices, order)
+	sort.Ints(indices)
+
+	return indices, nil
+}
+
+// write saves the selected operations of p to the --selected-plan file.
+func (s *selection) write(p *plan.Plan, indices []int) error {
+	if s.output == "" {
+		return nil
+	}
+
+	subset, err := p.Subset(indices)
+	if err != nil {
+		return fmt.Errorf("selecting operations: %w", err)
+	}
+
+	if err := subset.Save(s.output); err != nil {
+		return fmt.Errorf("writing selected plan: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %d selected operations to %s\n", len(indices), s.output)
+
+	return nil
+}
+
+// confirmOperations shows each operation in order and asks whether to run
+// it, returning the confirmed ones.
+func confirmOperations(
+	ctx context.Context, in io.Reader, out io.Writer, p *plan.Plan, names *describe.Names, order []int, verb string,
+) ([]int, error) {
+	reader := bufio.NewReader(in)
+	confirmed := make([]int, 0, len(order))
+
+	for k, i := range order {
+		op := p.Operations[i]
+		sentences := describe.Apply(ctx, names, op)
+
+		if verb == "revert" {
+			sentences = describe.Revert(ctx, names, op)
+		}
+
+		fmt.Fprintf(out, "\nOperation %d (%d of %d):\n", i+1, k+1, len(order))
+
+		for _, sentence := range sentences {
+			fmt.Fprintf(out, "  %s\n", sentence)
+		}
+
+		for {
+			fmt.Fprintf(out, "%s this operation? [y]es, [n]o, [a]ll remaining, [q]uit: ",
+				strings.ToUpper(verb[:1])+verb[1:])
+
+			answer, err := reader.ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("reading answer: %w", err)
+			}
+
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "y", "yes":
+				confirmed = append(confirmed, i)
+			case "n", "no":
+			case "a", "all":
+				return append(confirmed, order[k:]...), nil
+			case "q", "quit":
+				return confirmed, nil
+			default:
+				if errors.Is(err, io.EOF) {
+					return confirmed, nil
+				}
+
+				continue
+			}
+
+			break
+		}
+	}
+
+	return confirmed, nil
+}
+
+// selectedFunc returns a lookup for the selected indices, or nil when no
+// selection was made.
+func selectedFunc(indices []int) func(int) bool {
+	if indices == nil {
+		return nil
+	}
+
+	set := make(map[int]bool, len(indices))
+	for _, i := range indices {
+		set[i] = true
+	}
+
+	return func(i int) bool { return set[i] }
+}
+
+// pendingOperations returns the operations of a plan with total operations
+// that are selected and, according to the journal if any, still need the
+// given action.
+func pendingOperations(total int, selected func(int) bool, journal *plan.Journal, action plan.Action) []int {
+	pending := make([]int, 0, total)
+
+	for i := range total {
+		if selected != nil && !selected(i) {
+			continue
+		}
+
+		if journal != nil && journal.Applied(i) == (action == plan.ActionApply) {
+			continue
+		}
+
+		pending = append(pending, i)
+	}
+
+	return pending
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"immich-manager/cmd/options"
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/immich/describe"
+	"immich-manager/pkg/plan"
+)
+
+var (
+	showFormat  string
+	showOffline bool
+)
+
+var planShowCmd = &cobra.Command{
+	Use:   "show [plan-file]",
+	Short: "Describe the operations of a plan in plain language (use '-' or omit to read from stdin)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := describe.ParseFormat(showFormat)
+		if err != nil {
+			return err
+		}
+
+		p, err := loadPlanArg(args)
+		if err != nil {
+			return err
+		}
+
+		var client *immich.Client
+
+		if !showOffline {
+			client, err = options.NewClient()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Using names embedded in the plan only: %v\n", err)
+
+				client = nil
+			}
+		}
+
+		ctx, cancel := options.WithTimeout(cmd.Context())
+		defer cancel()
+
+		names := describe.NewNames(client, p.Names)
+
+		return describe.Plan(ctx, names, p).Render(os.Stdout, format)
+	},
+}
+
+// loadPlanArg loads the plan named by the optional file argument, reading
+// from stdin when it is missing or '-'.
+func loadPlanArg(args []string) (*plan.Plan, error) {
+	if len(args) == 0 || args[0] == "-" {
+		p, err := plan.LoadFromReader(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("loading plan from stdin: %w", err)
+		}
+
+		return p, nil
+	}
+
+	p, err := plan.Load(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("loading plan: %w", err)
+	}
+
+	return p, nil
+}
+
+func init() {
+	planShowCmd.Flags().StringVar(&showFormat, "format", string(describe.FormatText),
+		"Output format: text, markdown or json")
+	planShowCmd.Flags().BoolVar(&showOffline, "offline", false,
+		"Only use names embedded in the plan instead of looking them up on the server")
+	planCmd.AddCommand(planShowCmd)
+}
@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"immich-manager/pkg/plan"
+)
+
+// Default file names of the signing keys in the configuration directory.
+const (
+	signingKeyFile  = "signing_key"
+	trustedKeysFile = "trusted_keys"
+)
+
+var (
+	signingKeyPath  string
+	trustedKeysPath string
+	keygenName      string
+	keygenTrust     bool
+
+	signSkipValidation bool
+)
+
+var planKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Create an ed25519 key for signing plans and print its trusted keys line",
+	Args:  cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		if keygenName == "" {
+			return errors.New("--name is required to identify the key in trusted keys files")
+		}
+
+		path, err := configPath(signingKeyPath, signingKeyFile)
+		if err != nil {
+			return err
+		}
+
+		key, err := plan.GenerateKey()
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return fmt.Errorf("creating key directory: %w", err)
+		}
+
+		if err := plan.SavePrivateKey(path, key); err != nil {
+			return err
+		}
+
+		public, _ := key.Public().(ed25519.PublicKey)
+		trusted := plan.TrustedKey{Name: keygenName, Key: public}
+
+		fmt.Fprintf(os.Stderr, "Wrote signing key %s to %s\n", plan.KeyID(public), path)
+
+		if keygenTrust {
+			if err := trustKey(trusted); err != nil {
+				return err
+			}
+		}
+
+		fmt.Println(trusted)
+
+		return nil
+	},
+}
+
+var planSignCmd = &cobra.Command{
+	Use:   "sign [plan-file]",
+	Short: "Sign a reviewed plan (signs the file in place, or writes stdin to stdout)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		path, err := configPath(signingKeyPath, signingKeyFile)
+		if err != nil {
+			return err
+		}
+
+		key, err := plan.LoadPrivateKey(path)
+		if err != nil {
+			return err
+		}
+
+		p, err := loadPlanArg(args)
+		if err != nil {
+			return err
+		}
+
+		if !signSkipValidation {
+			if err := validatePlan(p, os.Stderr); err != nil {
+				return fmt.Errorf("%w: fix the plan before signing it", err)
+			}
+		}
+
+		if err := p.Sign(key, time.Now()); err != nil {
+			return err
+		}
+
+		if len(args) == 0 || args[0] == "-" {
+			return p.Write(os.Stdout)
+		}
+
+		if err := p.Save(args[0]); err != nil {
+			return err
+		}
+
+		public, _ := key.Public().(ed25519.PublicKey)
+		fmt.Fprintf(os.Stderr, "Signed %s with key %s\n", args[0], plan.KeyID(public))
+
+		return nil
+	},
+}
+
+var planVerifyCmd = &cobra.Command{
+	Use:   "verify [plan-file]",
+	Short: "Check that a plan is signed by a trusted key and unchanged since (use '-' or omit to read from stdin)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		trusted, err := loadTrustedKeys()
+		if err != nil {
+			return err
+		}
+
+		p, err := loadPlanArg(args)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range p.CheckSignatures(trusted) {
+			signer := status.Signer
+			if signer == "" {
+				signer = "unknown key"
+			}
+
+			result := "valid"
+			if status.Err != nil {
+				result = status.Err.Error()
+			}
+
+			fmt.Printf("Signed by %s (%s) at %s: %s\n", signer, status.Signature.KeyID(),
+				status.Signature.SignedAt.Local().Format(time.DateTime), result)
+		}
+
+		signers, err := p.VerifySignatures(trusted)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Plan with %d operations is signed by %s\n", len(p.Operations), strings.Join(signers, ", "))
+
+		return nil
+	},
+}
+
+// checkSignature refuses plans that are not signed by a trusted key or
+// were modified after they were signed.
+func checkSignature(p *plan.Plan) error {
+	trusted, err := loadTrustedKeys()
+	if err != nil {
+		return err
+	}
+
+	signers, err := p.VerifySignatures(trusted)
+	if err != nil {
+		return fmt.Errorf("%w: --require-signature only accepts plans signed with 'plan sign' by a trusted key", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Plan is signed by %s\n", strings.Join(signers, ", "))
+
+	return nil
+}
+
+// loadTrustedKeys reads the trusted keys file selected by --trusted-keys.
+func loadTrustedKeys() (plan.TrustedKeys, error) {
+	path, err := configPath(trustedKeysPath, trustedKeysFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.LoadTrustedKeys(path)
+}
+
+// trustKey appends a key to the trusted keys file.
+func trustKey(key plan.TrustedKey) error {
+	path, err := configPath(trustedKeysPath, trustedKeysFile)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("creating trusted keys directory: %w", err)
+	}
+
+	//nolint: gosec
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening trusted keys: %w", err)
+	}
+
+	if _, err := fmt.Fprintln(f, key); err != nil {
+		_ = f.Close()
+
+		return fmt.Errorf("writing trusted keys: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing trusted keys: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Added key to %s\n", path)
+
+	return nil
+}
+
+// configPath returns path, or name in the immich-manager configuration
+// directory when path is empty.
+func configPath(path, name string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("finding configuration directory: %w", err)
+	}
+
+	return filepath.Join(dir, "immich-manager", name), nil
+}
+
+// addSigningKeyFlag registers --key on cmd.
+func addSigningKeyFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&signingKeyPath, "key", "",
+		"Private key used to sign plans (defaults to signing_key in the immich-manager configuration directory)")
+}
+
+// addTrustedKeysFlag registers --trusted-keys on cmd.
+func addTrustedKeysFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&trustedKeysPath, "trusted-keys", "",
+		"File of public keys whose signatures are accepted "+
+			"(defaults to trusted_keys in the immich-manager configuration directory)")
+}
+
+func init() {
+	planKeygenCmd.Flags().StringVar(&keygenName, "name", "",
+		"Name of the key owner recorded in trusted keys files, such as an email address")
+	planKeygenCmd.Flags().BoolVar(&keygenTrust, "trust", false,
+		"Also add the new key to the local trusted keys file")
+	addSigningKeyFlag(planKeygenCmd)
+	addTrustedKeysFlag(planKeygenCmd)
+	planSignCmd.Flags().BoolVar(&signSkipValidation, "skip-validation", false,
+		"Sign the plan even if validation finds errors")
+	addSigningKeyFlag(planSignCmd)
+	addTrustedKeysFlag(planVerifyCmd)
+	planCmd.AddCommand(planKeygenCmd)
+	planCmd.AddCommand(planSignCmd)
+	planCmd.AddCommand(planVerifyCmd)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+var validateSchema bool
+
+var planValidateCmd = &cobra.Command{
+	Use:   "validate [plan-file]",
+	Short: "Check a plan for problems without contacting the server (use '-' or omit to read from stdin)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		if validateSchema {
+			if _, err := os.Stdout.Write(plan.Schema); err != nil {
+				return fmt.Errorf("writing schema: %w", err)
+			}
+
+			return nil
+		}
+
+		p, err := loadPlanArg(args)
+		if err != nil {
+			return err
+		}
+
+		if err := validatePlan(p, os.Stdout); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stdout, "Plan with %d operations is valid\n", len(p.Operations))
+
+		return nil
+	},
+}
+
+// validatePlan prints the issues found in p to w and returns an error if
+// any of them prevents the plan from being applied.
+func validatePlan(p *plan.Plan, w io.Writer) error {
+	issues := plan.Validate(p, immich.ParseRequest)
+	for _, issue := range issues {
+		fmt.Fprintln(w, issue)
+	}
+
+	if plan.HasErrors(issues) {
+		return errors.New("plan failed validation")
+	}
+
+	return nil
+}
+
+func init() {
+	planValidateCmd.Flags().BoolVar(&validateSchema, "schema", false,
+		"Print the JSON Schema of the plan format instead of validating a plan")
+	planCmd.AddCommand(planValidateCmd)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"immich-manager/cmd/albums"
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/immich/applier"
+	"immich-manager/pkg/immich/describe"
+	"immich-manager/pkg/plan"
+)
+
+// verifyApplied checks that the server reached the state a plan intended
+// after it was applied. A plan made by a known generator is generated again
+// with the same arguments and must report that no changes are needed.
+// Other plans, and plans applied only in part, have the postconditions of
+// each operation checked.
+func verifyApplied(
+	ctx context.Context, client *immich.Client, a *applier.Applier, p *plan.Plan, selected func(int) bool,
+) error {
+	if selected == nil {
+		regenerated, ok, err := albums.Regenerate(ctx, client, p.Metadata)
+		if errors.Is(err, plan.ErrNoChanges) {
+			regenerated, err = &plan.Plan{}, nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return verifyRegenerated(ctx, client, p, regenerated)
+		}
+	}
+
+	verification, err := a.Verify(ctx, p, selected)
+	if err != nil {
+		return fmt.Errorf("verifying plan: %w", err)
+	}
+
+	if len(verification.Unchecked) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: could not verify %d operations with requests that are not understood\n",
+			len(verification.Unchecked))
+	}
+
+	for _, unconverged := range verification.Unconverged {
+		reasons := make([]string, len(unconverged.Reasons))
+		for k, reason := range unconverged.Reasons {
+			reasons[k] = reason.Error()
+		}
+
+		fmt.Fprintf(os.Stderr, "Operation %d did not converge: %s\n",
+			unconverged.Operation+1, strings.Join(reasons, "; "))
+	}
+
+	if !verification.Converged() {
+		return fmt.Errorf("verifying plan: %d of %d checked operations did not converge",
+			len(verification.Unconverged), verification.Checked)
+	}
+
+	fmt.Fprintf(os.Stderr, "Verified %d operations\n", verification.Checked)
+
+	return nil
+}
+
+// verifyRegenerated reports the operations of a regenerated plan, which
+// are the changes still needed to reach the state p intended.
+func verifyRegenerated(ctx context.Context, client *immich.Client, p, regenerated *plan.Plan) error {
+	if len(regenerated.Operations) == 0 {
+		fmt.Fprintf(os.Stderr, "Verified: '%s' generates no further operations\n", p.Metadata.Generator)
+
+		return nil
+	}
+
+	names := describe.NewNames(client, regenerated.Names)
+
+	for _, op := range regenerated.Operations {
+		change := strings.Join(describe.Apply(ctx, names, op), "; ")
+
+		if i, ok := findOperation(p, op); ok {
+			fmt.Fprintf(os.Stderr, "Operation %d did not converge: %s\n", i+1, change)
+		} else {
+			fmt.Fprintf(os.Stderr, "Not converged: %s\n", change)
+		}
+	}
+
+	return fmt.Errorf("verifying plan: '%s' still generates %d operations",
+		p.Metadata.Generator, len(regenerated.Operations))
+}
+
+// findOperation returns the index of the operation of p with the same
+// intents and apply requests as op.
+func findOperation(p *plan.Plan, op plan.Operation) (int, bool) {
+	want, err := json.Marshal(plan.Operation{Intents: op.Intents, Apply: op.Apply})
+	if err != nil {
+		return 0, false
+	}
+
+	for i, candidate := range p.Operations {
+		got, err := json.Marshal(plan.Operation{Intents: candidate.Intents, Apply: candidate.Apply})
+		if err == nil && bytes.Equal(got, want) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"immich-manager/cmd/albums"
+	"immich-manager/pkg/immich"
+	adduser "immich-manager/pkg/immich/albums/add-user"
+	"immich-manager/pkg/immich/applier"
+	"immich-manager/pkg/plan"
+)
+
+func TestVerifyApplied_NoChangesNeeded(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+
+	user := immich.User{ID: "u1", Email: "test@example.com"}
+	albums := map[string]*immich.Album{
+		"1": {ID: "1", Name: "vacation photos"},
+		"2": {ID: "2", Name: "vacation memories"},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		path := strings.TrimPrefix(r.URL.Path, "/api/albums/")
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/users":
+			_ = json.NewEncoder(w).Encode([]immich.User{user})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/albums":
+			list := make([]immich.Album, 0, len(albums))
+			for _, album := range albums {
+				list = append(list, *album)
+			}
+
+			_ = json.NewEncoder(w).Encode(list)
+		case r.Method == http.MethodGet && albums[path] != nil:
+			_ = json.NewEncoder(w).Encode(albums[path])
+		case r.Method == http.MethodPut && strings.HasSuffix(path, "/users"):
+			album := albums[strings.TrimSuffix(path, "/users")]
+			album.AlbumUsers = append(album.AlbumUsers, immich.AlbumUser{User: user, Role: immich.RoleViewer})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := immich.NewClient(server.URL, "test-token")
+
+	p, err := adduser.NewGenerator(client, "vacation", user.Email).Generate()
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	p.Metadata = &plan.Metadata{Generator: "albums add-user", Args: []string{"vacation", user.Email}}
+
+	a := applier.NewApplier(client)
+	if err := a.Apply(p, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	if err := verifyApplied(context.Background(), client, a, p, nil); err != nil {
+		t.Errorf("verifyApplied() after applying every operation error = %v, want nil", err)
+	}
+}
+
+// fakeServer keeps albums in memory and serves the routes the album
+// generators and the applier use.
+type fakeServer struct {
+	mu      sync.Mutex
+	users   []immich.User
+	albums  map[string]*immich.Album
+	person  []string
+	created int
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var body struct {
+		Name       string                     `json:"albumName"`
+		IDs        []string                   `json:"ids"`
+		AlbumUsers []immich.AlbumUserAddition `json:"albumUsers"`
+	}
+
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/albums/")
+	id, rest, _ := strings.Cut(path, "/")
+	album := s.albums[id]
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/users":
+		_ = json.NewEncoder(w).Encode(s.users)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/search/metadata":
+		response := immich.MetadataSearchResponse{}
+		for _, assetID := range s.person {
+			response.Assets.Items = append(response.Assets.Items, immich.Asset{ID: assetID})
+		}
+
+		_ = json.NewEncoder(w).Encode(response)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/albums":
+		_ = json.NewEncoder(w).Encode(s.list(r.URL.Query()))
+	case r.Method == http.MethodPost && r.URL.Path == "/api/albums":
+		s.created++
+		album := &immich.Album{ID: fmt.Sprintf("new-%d", s.created), Name: body.Name}
+		s.albums[album.ID] = album
+		_ = json.NewEncoder(w).Encode(album)
+	case album == nil:
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodGet && rest == "":
+		_ = json.NewEncoder(w).Encode(album)
+	case r.Method == http.MethodPatch && rest == "":
+		album.Name = body.Name
+		_ = json.NewEncoder(w).Encode(album)
+	case r.Method == http.MethodDelete && rest == "":
+		delete(s.albums, id)
+	case r.Method == http.MethodPut && rest == "users":
+		for _, addition := range body.AlbumUsers {
+			album.AlbumUsers = append(album.AlbumUsers, immich.AlbumUser{User: s.user(addition.UserID), Role: addition.Role})
+		}
+
+		_ = json.NewEncoder(w).Encode(album)
+	case r.Method == http.MethodDelete && strings.HasPrefix(rest, "user/"):
+		album.AlbumUsers = slices.DeleteFunc(album.AlbumUsers, func(member immich.AlbumUser) bool {
+			return member.User.ID == strings.TrimPrefix(rest, "user/")
+		})
+	case r.Method == http.MethodPut && rest == "assets":
+		for _, assetID := range body.IDs {
+			album.Assets = append(album.Assets, immich.Asset{ID: assetID})
+		}
+
+		_, _ = w.Write([]byte("[]"))
+	case r.Method == http.MethodDelete && rest == "assets":
+		album.Assets = slices.DeleteFunc(album.Assets, func(asset immich.Asset) bool {
+			return slices.Contains(body.IDs, asset.ID)
+		})
+
+		_, _ = w.Write([]byte("[]"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// list returns the albums matching the filters of an album list request.
+func (s *fakeServer) list(query url.Values) []immich.Album {
+	list := make([]immich.Album, 0, len(s.albums))
+
+	for _, album := range s.albums {
+		if query.Get("shared") == "true" && len(album.AlbumUsers) == 0 {
+			continue
+		}
+
+		if assetID := query.Get("assetId"); assetID != "" && !slices.ContainsFunc(album.Assets, func(asset immich.Asset) bool {
+			return asset.ID == assetID
+		}) {
+			continue
+		}
+
+		list = append(list, *album)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	return list
+}
+
+// user returns the user with the given ID.
+func (s *fakeServer) user(id string) immich.User {
+	for _, user := range s.users {
+		if user.ID == id {
+			return user
+		}
+	}
+
+	return immich.User{ID: id}
+}
+
+func TestVerifyApplied_Generators(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		generator string
+		args      []string
+	}{
+		{generator: "albums replace", args: []string{"vacation", "holiday"}},
+		{generator: "albums add-user", args: []string{"vacation", "test@example.com"}},
+		{generator: "albums add-person", args: []string{"person-1", "test@example.com"}},
+		{generator: "albums clear-shared", args: []string{"friend@example.com"}},
+		{generator: "albums smart", args: []string{"friend@example.com"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.generator, func(t *testing.T) {
+			t.Parallel()
+
+			user := immich.User{ID: "u1", Email: "test@example.com", Name: "Test"}
+			friend := immich.User{ID: "u2", Email: "friend@example.com", Name: "Friend"}
+
+			fake := &fakeServer{
+				users: []immich.User{user, friend},
+				albums: map[string]*immich.Album{
+					"1": {
+						ID: "1", Name: "vacation photos",
+						AlbumUsers: []immich.AlbumUser{{User: friend, Role: immich.RoleEditor}},
+						Assets:     []immich.Asset{{ID: "a1"}, {ID: "a2"}},
+					},
+					"2": {ID: "2", Name: "vacation memories", Assets: []immich.Asset{{ID: "a3"}}},
+				},
+				person: []string{"a1", "a3"},
+			}
+
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			client := immich.NewClient(server.URL, "test-token")
+			meta := &plan.Metadata{Generator: tc.generator, Args: tc.args}
+
+			p, ok, err := albums.Regenerate(context.Background(), client, meta)
+			if err != nil || !ok {
+				t.Fatalf("Regenerate() = %v, %v; want a plan", ok, err)
+			}
+
+			p.Metadata = meta
+
+			a := applier.NewApplier(client)
+			if err := a.Apply(p, nil); err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+
+			if _, _, err := albums.Regenerate(context.Background(), client, meta); !errors.Is(err, plan.ErrNoChanges) {
+				t.Errorf("Regenerate() after applying error = %v, want %v", err, plan.ErrNoChanges)
+			}
+
+			if err := verifyApplied(context.Background(), client, a, p, nil); err != nil {
+				t.Errorf("verifyApplied() after applying every operation error = %v, want nil", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+// Package history archives applied plans so that they can be listed,
+// inspected and reverted later without the original plan file.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"immich-manager/pkg/plan"
+)
+
+// ErrNotFound is returned when no history entry matches an ID.
+var ErrNotFound = errors.New("no such history entry")
+
+// File names within the directory of an entry.
+const (
+	entryFile = "entry.json"
+	planFile  = "plan.json"
+)
+
+// Entry describes an applied plan in the history.
+type Entry struct {
+	// ID identifies the entry. It starts with the time the plan was
+	// applied, to the second, so plans applied within the same second are
+	// ordered by AppliedAt rather than by ID.
+	ID        string    `json:"id"`
+	AppliedAt time.Time `json:"appliedAt"`
+	// PlanFile is the path the plan was applied from, if it was read from
+	// a file.
+	PlanFile   string         `json:"planFile,omitempty"`
+	Metadata   *plan.Metadata `json:"metadata,omitempty"`
+	Operations int            `json:"operations"`
+	// Albums holds the IDs of the albums the plan touches.
+	Albums []string `json:"albums,omitempty"`
+	// Hash is the content hash of the plan's operations.
+	Hash string `json:"hash"`
+}
+
+// Describe returns the generator and arguments of the entry's plan, or a
+// placeholder for plans without metadata.
+func (e *Entry) Describe() string {
+	if e.Metadata == nil || e.Metadata.Generator == "" {
+		return "(unknown generator)"
+	}
+
+	if len(e.Metadata.Args) == 0 {
+		return e.Metadata.Generator
+	}
+
+	return e.Metadata.Generator + " " + strings.Join(quote(e.Metadata.Args), " ")
+}
+
+// Store is a directory holding one subdirectory per applied plan, with the
+// plan, its journal and an entry describing it.
+type Store struct {
+	dir string
+}
+
+// DefaultDir returns the history location: immich-manager under
+// $XDG_STATE_HOME, or ~/.local/state/immich-manager when it is not set.
+func DefaultDir() (string, error) {
+	if state := os.Getenv("XDG_STATE_HOME"); state != "" {
+		return filepath.Join(state, "immich-manager"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding history directory: %w", err)
+	}
+
+	return filepath.Join(home, ".local", "state", "immich-manager"), nil
+}
+
+// NewStore returns the store in dir. The directory is created when the
+// first plan is added.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the location of the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Add archives a plan that was applied from source, which is empty for
+// plans read from stdin. entries become the archived plan's journal.
+// albums are the IDs of the albums the plan touches.
+func (s *Store) Add(
+	p *plan.Plan, source string, albums []string, entries []plan.JournalEntry, now time.Time,
+) (*Entry, error) {
+	hash, err := p.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	if source != "" {
+		if abs, err := filepath.Abs(source); err == nil {
+			source = abs
+		}
+	}
+
+	entry := &Entry{
+		ID:         now.UTC().Format("20060102-150405") + "-" + shortHash(hash),
+		AppliedAt:  now.UTC(),
+		PlanFile:   source,
+		Metadata:   p.Metadata,
+		Operations: len(p.Operations),
+		Albums:     albums,
+		Hash:       hash,
+	}
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating history: %w", err)
+	}
+
+	// The same plan applied twice within a second gets a numbered ID
+	id := entry.ID
+	for n := 2; ; n++ {
+		err := os.Mkdir(filepath.Join(s.dir, entry.ID), 0o700)
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("creating history entry: %w", err)
+		}
+
+		entry.ID = fmt.Sprintf("%s-%d", id, n)
+	}
+
+	dir := filepath.Join(s.dir, entry.ID)
+
+	if err := p.Save(s.PlanPath(entry.ID)); err != nil {
+		return nil, err
+	}
+
+	journal, err := plan.OpenJournal(s.JournalPath(entry.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := journal.Bind(p); err != nil {
+		return nil, err
+	}
+
+	for _, journalEntry := range entries {
+		if err := journal.Record(journalEntry); err != nil {
+			_ = journal.Close()
+
+			return nil, err
+		}
+	}
+
+	if err := journal.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := writeEntry(filepath.Join(dir, entryFile), entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// List returns all entries in the order they were applied.
+func (s *Store) List() ([]*Entry, error) {
+	dirs, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+
+	entries := make([]*Entry, 0, len(dirs))
+
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		entry, err := readEntry(filepath.Join(s.dir, dir.Name(), entryFile))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].AppliedAt.Equal(entries[j].AppliedAt) {
+			return entries[i].AppliedAt.Before(entries[j].AppliedAt)
+		}
+
+		return entries[i].ID < entries[j].ID
+	})
+
+	return entries, nil
+}
+
+// Get returns the entry with the given ID, or the only entry whose ID
+// starts with it.
+func (s *Store) Get(id string) (*Entry, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*Entry
+
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+
+		if id != "" && strings.HasPrefix(entry.ID, id) {
+			matches = append(matches, entry)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("history ID %s is ambiguous: it matches %d entries", id, len(matches))
+	}
+}
+
+// FindHash returns the entries of plans with the given content hash.
+func (s *Store) FindHash(hash string) ([]*Entry, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*Entry
+
+	for _, entry := range entries {
+		if entry.Hash == hash {
+			found = append(found, entry)
+		}
+	}
+
+	return found, nil
+}
+
+// PlanPath returns the location of the archived plan of an entry.
+func (s *Store) PlanPath(id string) string {
+	return filepath.Join(s.dir, id, planFile)
+}
+
+// JournalPath returns the location of the journal of an archived plan.
+func (s *Store) JournalPath(id string) string {
+	return plan.JournalPath(s.PlanPath(id))
+}
+
+// Load returns the archived plan of an entry.
+func (s *Store) Load(id string) (*plan.Plan, error) {
+	return plan.Load(s.PlanPath(id))
+}
+
+// Applied returns the operations of an entry that are still applied
+// according to its journal.
+func (s *Store) Applied(id string) ([]int, error) {
+	journal, err := plan.OpenJournal(s.JournalPath(id))
+	if err != nil {
+		return nil, err
+	}
+
+	return journal.AppliedOperations(), nil
+}
+
+// LaterOverlapping returns the entries applied after entry that touch any
+// of its albums and still have operations applied.
+func (s *Store) LaterOverlapping(entry *Entry) ([]*Entry, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	albums := make(map[string]bool, len(entry.Albums))
+	for _, id := range entry.Albums {
+		albums[id] = true
+	}
+
+	var overlapping []*Entry
+
+	after := false
+
+	for _, later := range entries {
+		if later.ID == entry.ID {
+			after = true
+
+			continue
+		}
+
+		if !after || !touchesAny(later, albums) {
+			continue
+		}
+
+		applied, err := s.Applied(later.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(applied) > 0 {
+			overlapping = append(overlapping, later)
+		}
+	}
+
+	return overlapping, nil
+}
+
+// touchesAny reports whether entry touches any of the albums.
+func touchesAny(entry *Entry, albums map[string]bool) bool {
+	for _, id := range entry.Albums {
+		if albums[id] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeEntry saves an entry as JSON.
+func writeEntry(path string, entry *Entry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding history entry: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("writing history entry: %w", err)
+	}
+
+	return nil
+}
+
+// readEntry loads an entry from JSON.
+func readEntry(path string) (*Entry, error) {
+	//nolint: gosec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading history entry: %w", err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("decoding history entry %s: %w", path, err)
+	}
+
+	return &entry, nil
+}
+
+// shortHash returns a few characters of a content hash to tell apart
+// plans applied in the same second.
+func shortHash(hash string) string {
+	hex := strings.TrimPrefix(hash, "sha256:")
+	if len(hex) > 8 {
+		hex = hex[:8]
+	}
+
+	return hex
+}
+
+// quote quotes arguments that contain spaces.
+func quote(args []string) []string {
+	quoted := make([]string, len(args))
+
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'") {
+			arg = fmt.Sprintf("%q", arg)
+		}
+
+		quoted[i] = arg
+	}
+
+	return quoted
+}
@@ -0,0 +1,201 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"immich-manager/pkg/plan"
+)
+
+// renamePlan returns a plan renaming an album.
+func renamePlan(album, name string) *plan.Plan {
+	return &plan.Plan{
+		Metadata: &plan.Metadata{Generator: "albums replace", Args: []string{"old name", name}},
+		Operations: []plan.Operation{{
+			Apply:  []plan.Request{{Method: "PATCH", Path: "/api/albums/" + album, Body: []byte(`{"albumName":"` + name + `"}`)}},
+			Revert: []plan.Request{{Method: "PATCH", Path: "/api/albums/" + album, Body: []byte(`{"albumName":"old"}`)}},
+		}},
+	}
+}
+
+// applied returns journal entries applying the first n operations.
+func applied(n int) []plan.JournalEntry {
+	entries := make([]plan.JournalEntry, n)
+	for i := range entries {
+		entries[i] = plan.JournalEntry{Operation: i, Action: plan.ActionApply}
+	}
+
+	return entries
+}
+
+func TestStore_AddAndGet(t *testing.T) {
+	t.Parallel()
+
+	store := NewStore(filepath.Join(t.TempDir(), "history"))
+
+	entries, err := store.List()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("List() on a missing store = %v, %v; want no entries", entries, err)
+	}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := renamePlan("album-1", "new name")
+
+	entry, err := store.Add(p, "", []string{"album-1"}, applied(1), now)
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	if entry.ID[:16] != "20240501-120000-" {
+		t.Errorf("ID = %s, want it to start with the time it was applied", entry.ID)
+	}
+
+	if got := entry.Describe(); got != `albums replace "old name" "new name"` {
+		t.Errorf("Describe() = %s", got)
+	}
+
+	got, err := store.Get(entry.ID[:18])
+	if err != nil {
+		t.Fatalf("Get() by prefix error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, entry) {
+		t.Errorf("Get() = %+v, want %+v", got, entry)
+	}
+
+	archived, err := store.Load(entry.ID)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if hash, err := archived.Hash(); err != nil || hash != entry.Hash {
+		t.Errorf("Archived plan hash = %s, %v; want %s", hash, err, entry.Hash)
+	}
+
+	if ops, err := store.Applied(entry.ID); err != nil || !reflect.DeepEqual(ops, []int{0}) {
+		t.Errorf("Applied() = %v, %v; want [0]", ops, err)
+	}
+
+	if _, err := store.Get("2023"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of an unknown ID error = %v, want ErrNotFound", err)
+	}
+
+	found, err := store.FindHash(entry.Hash)
+	if err != nil || len(found) != 1 || found[0].ID != entry.ID {
+		t.Errorf("FindHash() = %v, %v; want the entry", found, err)
+	}
+}
+
+func TestStore_GetAmbiguous(t *testing.T) {
+	t.Parallel()
+
+	store := NewStore(t.TempDir())
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, album := range []string{"album-1", "album-2"} {
+		if _, err := store.Add(renamePlan(album, "name"), "", []string{album}, nil, now); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	again, err := store.Add(renamePlan("album-1", "name"), "", []string{"album-1"}, nil, now)
+	if err != nil {
+		t.Fatalf("Add() of the same plan again error = %v", err)
+	}
+
+	if want := "20240501-120000-" + shortHash(again.Hash) + "-2"; again.ID != want {
+		t.Errorf("ID of a plan applied twice in a second = %s, want %s", again.ID, want)
+	}
+
+	if _, err := store.Get("20240501"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of an ambiguous prefix error = %v", err)
+	}
+}
+
+func TestStore_ListSkipsForeignDirectories(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "unrelated"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := NewStore(dir).List()
+	if err != nil || len(entries) != 0 {
+		t.Errorf("List() = %v, %v; want no entries", entries, err)
+	}
+}
+
+func TestStore_LaterOverlapping(t *testing.T) {
+	t.Parallel()
+
+	store := NewStore(t.TempDir())
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	add := func(album, name string, minutes int, journal []plan.JournalEntry) *Entry {
+		t.Helper()
+
+		entry, err := store.Add(renamePlan(album, name), "", []string{album}, journal,
+			start.Add(time.Duration(minutes)*time.Minute))
+		if err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+
+		return entry
+	}
+
+	first := add("album-1", "first", 0, applied(1))
+	add("album-2", "unrelated", 1, applied(1))
+	later := add("album-1", "later", 2, applied(1))
+	add("album-1", "reverted", 3, append(applied(1), plan.JournalEntry{Operation: 0, Action: plan.ActionRevert}))
+
+	overlapping, err := store.LaterOverlapping(first)
+	if err != nil {
+		t.Fatalf("LaterOverlapping() error = %v", err)
+	}
+
+	if len(overlapping) != 1 || overlapping[0].ID != later.ID {
+		t.Errorf("LaterOverlapping() = %v, want only %s", overlapping, later.ID)
+	}
+
+	if overlapping, err := store.LaterOverlapping(later); err != nil || len(overlapping) != 0 {
+		t.Errorf("LaterOverlapping() of the latest plan = %v, %v; want none", overlapping, err)
+	}
+}
+
+func TestStore_LaterOverlappingSameSecond(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Whichever order the hashes in the IDs sort in, one of these runs
+	// applies the plan whose ID sorts first last
+	for _, names := range [][2]string{{"first", "second"}, {"second", "first"}} {
+		store := NewStore(t.TempDir())
+
+		first, err := store.Add(renamePlan("album-1", names[0]), "", []string{"album-1"}, applied(1),
+			start.Add(100*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+
+		later, err := store.Add(renamePlan("album-1", names[1]), "", []string{"album-1"}, applied(1),
+			start.Add(200*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+
+		overlapping, err := store.LaterOverlapping(first)
+		if err != nil || len(overlapping) != 1 || overlapping[0].ID != later.ID {
+			t.Errorf("LaterOverlapping(%s) = %v, %v; want %s", first.ID, overlapping, err, later.ID)
+		}
+
+		if overlapping, err := store.LaterOverlapping(later); err != nil || len(overlapping) != 0 {
+			t.Errorf("LaterOverlapping(%s) = %v, %v; want none", later.ID, overlapping, err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package immich
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"immich-manager/pkg/plan"
+)
+
+// Album user roles accepted by the Immich API.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+)
+
+// AlbumsService provides access to the album endpoints.
+type AlbumsService struct {
+	client *Client
+}
+
+// AlbumListOptions filters the albums returned by AlbumsService.List.
+type AlbumListOptions struct {
+	// Shared, when set, limits the result to shared or unshared albums.
+	Shared *bool
+	// AssetID, when set, limits the result to albums containing the asset.
+	AssetID string
+}
+
+// AlbumCreate holds the fields of an album created with Create.
+type AlbumCreate struct {
+	Name string `json:"albumName"`
+}
+
+// AlbumUpdate holds the album fields that can be changed with Update.
+type AlbumUpdate struct {
+	Name string `json:"albumName,omitempty"`
+}
+
+// AlbumUserAddition describes a user to share an album with.
+type AlbumUserAddition struct {
+	Role   string `json:"role"`
+	UserID string `json:"userId"`
+}
+
+// addUsersBody is the request body for adding users to an album.
+type addUsersBody struct {
+	AlbumUsers []AlbumUserAddition `json:"albumUsers"`
+}
+
+// assetIDsBody is the request body for adding or removing album assets.
+type assetIDsBody struct {
+	IDs []string `json:"ids"`
+}
+
+// List returns the albums visible to the authenticated user.
+func (s *AlbumsService) List(ctx context.Context, opts *AlbumListOptions) ([]Album, error) {
+	path := "/api/albums"
+
+	if opts != nil {
+		query := url.Values{}
+		if opts.Shared != nil {
+			query.Set("shared", strconv.FormatBool(*opts.Shared))
+		}
+
+		if opts.AssetID != "" {
+			query.Set("assetId", opts.AssetID)
+		}
+
+		if len(query) > 0 {
+			path += "?" + query.Encode()
+		}
+	}
+
+	var albums []Album
+	if err := s.client.get(ctx, path, &albums); err != nil {
+		return nil, fmt.Errorf("listing albums: %w", err)
+	}
+
+	return albums, nil
+}
+
+// Get returns a single album without its assets.
+func (s *AlbumsService) Get(ctx context.Context, id string) (*Album, error) {
+	var album Album
+	if err := s.client.get(ctx, "/api/albums/"+id, &album); err != nil {
+		return nil, fmt.Errorf("getting album %s: %w", id, err)
+	}
+
+	return &album, nil
+}
+
+// GetWithAssets returns a single album including its assets.
+func (s *AlbumsService) GetWithAssets(ctx context.Context, id string) (*Album, error) {
+	var album Album
+	if err := s.client.get(ctx, "/api/albums/"+id+"?withoutAssets=false", &album); err != nil {
+		return nil, fmt.Errorf("getting album %s with assets: %w", id, err)
+	}
+
+	return &album, nil
+}
+
+// AssetIDs returns the IDs of all assets in an album.
+func (s *AlbumsService) AssetIDs(ctx context.Context, id string) ([]string, error) {
+	album, err := s.GetWithAssets(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(album.Assets))
+	for _, asset := range album.Assets {
+		ids = append(ids, asset.ID)
+	}
+
+	return ids, nil
+}
+
+// CreateRequest builds the request that creates an album. The response
+// holds the new album, so other operations can reference its ID with
+// plan.Placeholder.
+func (*AlbumsService) CreateRequest(create AlbumCreate) (plan.Request, error) {
+	return newPlanRequest(http.MethodPost, "/api/albums", create)
+}
+
+// Create creates an album and returns it.
+func (s *AlbumsService) Create(ctx context.Context, create AlbumCreate) (*Album, error) {
+	req, err := s.CreateRequest(create)
+	if err != nil {
+		return nil, err
+	}
+
+	var album Album
+	if err := s.client.Send(ctx, req, &album); err != nil {
+		return nil, fmt.Errorf("creating album %q: %w", create.Name, err)
+	}
+
+	return &album, nil
+}
+
+// DeleteRequest builds the request that deletes an album.
+func (*AlbumsService) DeleteRequest(id string) (plan.Request, error) {
+	return newPlanRequest(http.MethodDelete, "/api/albums/"+id, nil)
+}
+
+// Delete deletes an album. Its assets are kept.
+func (s *AlbumsService) Delete(ctx context.Context, id string) error {
+	req, err := s.DeleteRequest(id)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Send(ctx, req, nil)
+}
+
+// UpdateRequest builds the request that updates an album.
+func (*AlbumsService) UpdateRequest(id string, update AlbumUpdate) (plan.Request, error) {
+	return newPlanRequest(http.MethodPatch, "/api/albums/"+id, update)
+}
+
+// Update changes the fields of an album.
+func (s *AlbumsService) Update(ctx context.Context, id string, update AlbumUpdate) error {
+	req, err := s.UpdateRequest(id, update)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Send(ctx, req, nil)
+}
+
+// AddUsersRequest builds the request that shares an album with users.
+func (*AlbumsService) AddUsersRequest(id string, users ...AlbumUserAddition) (plan.Request, error) {
+	return newPlanRequest(http.MethodPut, "/api/albums/"+id+"/users", addUsersBody{AlbumUsers: users})
+}
+
+// AddUsers shares an album with users.
+func (s *AlbumsService) AddUsers(ctx context.Context, id string, users ...AlbumUserAddition) error {
+	req, err := s.AddUsersRequest(id, users...)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Send(ctx, req, nil)
+}
+
+// RemoveUserRequest builds the request that stops sharing an album with a
+// user.
+func (*AlbumsService) RemoveUserRequest(id, userID string) (plan.Request, error) {
+	return newPlanRequest(http.MethodDelete, "/api/albums/"+id+"/user/"+userID, nil)
+}
+
+// RemoveUser stops sharing an album with a user.
+func (s *AlbumsService) RemoveUser(ctx context.Context, id, userID string) error {
+	req, err := s.RemoveUserRequest(id, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Send(ctx, req, nil)
+}
+
+// AddAssetsRequest builds the request that adds assets to an album.
+func (*AlbumsService) AddAssetsRequest(id string, assetIDs []string) (plan.Request, error) {
+	return newPlanRequest(http.MethodPut, "/api/albums/"+id+"/assets", assetIDsBody{IDs: assetIDs})
+}
+
+// AddAssets adds assets to an album.
+func (s *AlbumsService) AddAssets(ctx context.Context, id string, assetIDs []string) error {
+	req, err := s.AddAssetsRequest(id, assetIDs)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Send(ctx, req, nil)
+}
+
+// RemoveAssetsRequest builds the request that removes assets from an album.
+func (*AlbumsService) RemoveAssetsRequest(id string, assetIDs []string) (plan.Request, error) {
+	return newPlanRequest(http.MethodDelete, "/api/albums/"+id+"/assets", assetIDsBody{IDs: assetIDs})
+}
+
+// RemoveAssets removes assets from an album.
+func (s *AlbumsService) RemoveAssets(ctx context.Context, id string, assetIDs []string) error {
+	req, err := s.RemoveAssetsRequest(id, assetIDs)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Send(ctx, req, nil)
+}
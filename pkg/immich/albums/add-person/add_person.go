@@ -2,11 +2,8 @@
 package addperson
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
-	"net/http"
-	"strings"
 
 	"immich-manager/pkg/immich"
 	"immich-manager/pkg/plan"
@@ -28,34 +25,16 @@ func NewGenerator(client *immich.Client, personID, email string) *Generator {
 	}
 }
 
-// SearchMetadataRequest represents the request body for searching metadata by person ID.
-type SearchMetadataRequest struct {
-	Page      string   `json:"page"`
-	PersonIDs []string `json:"personIds"`
-}
-
-// Asset represents an asset in the search results.
-type Asset struct {
-	ID string `json:"id"`
-}
-
-// SearchMetadataResponse represents the response from the metadata search API.
-type SearchMetadataResponse struct {
-	Assets struct {
-		Items    []Asset `json:"items"`
-		NextPage *string `json:"nextPage"`
-	} `json:"assets"`
-}
-
-// AlbumsByAssetResponse represents the response from getting albums by asset ID.
-type AlbumsByAssetResponse []struct {
-	ID string `json:"id"`
-}
-
 // Generate creates a plan for adding a user to albums containing assets of the specified person.
 func (g *Generator) Generate() (*plan.Plan, error) {
+	return g.GenerateContext(context.Background())
+}
+
+// GenerateContext is like Generate but aborts its API requests when ctx
+// is cancelled.
+func (g *Generator) GenerateContext(ctx context.Context) (*plan.Plan, error) {
 	// Step 1: Get all asset IDs for the person (paginated)
-	assetIDs, err := g.getAllAssetsForPerson()
+	assetIDs, err := g.getAllAssetsForPerson(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getting assets for person: %w", err)
 	}
@@ -65,7 +44,7 @@ func (g *Generator) Generate() (*plan.Plan, error) {
 	}
 
 	// Step 2: Get unique album IDs containing these assets
-	albumIDs, err := g.getUniqueAlbumIDs(assetIDs)
+	albumIDs, err := g.getUniqueAlbumIDs(ctx, assetIDs)
 	if err != nil {
 		return nil, fmt.Errorf("getting album IDs: %w", err)
 	}
@@ -75,65 +54,39 @@ func (g *Generator) Generate() (*plan.Plan, error) {
 	}
 
 	// Step 3: Get all users and find the one with matching email
-	targetUser, err := g.findUserByEmail()
+	targetUser, err := g.client.Users.FindByEmail(ctx, g.email)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("finding user: %w", err)
 	}
 
 	// Step 4: Get album details and create operations
-	return g.createPlanForAlbums(albumIDs, targetUser)
+	return g.createPlanForAlbums(ctx, albumIDs, targetUser)
 }
 
 // getAllAssetsForPerson retrieves all asset IDs for a person using paginated search.
-func (g *Generator) getAllAssetsForPerson() ([]string, error) {
-	var allAssetIDs []string
-
-	page := "1"
-
-	for {
-		searchRequest := SearchMetadataRequest{
-			Page:      page,
-			PersonIDs: []string{g.personID},
-		}
-
-		req, err := g.client.NewRequest("POST", "/api/search/metadata", searchRequest)
-		if err != nil {
-			return nil, fmt.Errorf("creating search metadata request: %w", err)
-		}
-
-		var response SearchMetadataResponse
-		if err := g.client.Do(req, &response); err != nil {
-			return nil, fmt.Errorf("searching metadata for person %s (page %s): %w", g.personID, page, err)
-		}
-
-		// Collect asset IDs from this page
-		for _, asset := range response.Assets.Items {
-			allAssetIDs = append(allAssetIDs, asset.ID)
-		}
-
-		// Check if there are more pages
-		if response.Assets.NextPage == nil {
-			break
-		}
+func (g *Generator) getAllAssetsForPerson(ctx context.Context) ([]string, error) {
+	assets, err := g.client.Search.MetadataAll(ctx, immich.MetadataSearchRequest{
+		PersonIDs: []string{g.personID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("searching metadata for person %s: %w", g.personID, err)
+	}
 
-		page = *response.Assets.NextPage
+	assetIDs := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		assetIDs = append(assetIDs, asset.ID)
 	}
 
-	return allAssetIDs, nil
+	return assetIDs, nil
 }
 
 // getUniqueAlbumIDs gets unique album IDs containing the specified assets.
-func (g *Generator) getUniqueAlbumIDs(assetIDs []string) ([]string, error) {
+func (g *Generator) getUniqueAlbumIDs(ctx context.Context, assetIDs []string) ([]string, error) {
 	albumIDSet := make(map[string]bool)
 
 	for _, assetID := range assetIDs {
-		req, err := g.client.NewRequest("GET", "/api/albums?assetId="+assetID, map[string]interface{}{})
+		albums, err := g.client.Albums.List(ctx, &immich.AlbumListOptions{AssetID: assetID})
 		if err != nil {
-			return nil, fmt.Errorf("creating request for albums containing asset %s: %w", assetID, err)
-		}
-
-		var albums AlbumsByAssetResponse
-		if err := g.client.Do(req, &albums); err != nil {
 			return nil, fmt.Errorf("getting albums for asset %s: %w", assetID, err)
 		}
 
@@ -152,100 +105,60 @@ func (g *Generator) getUniqueAlbumIDs(assetIDs []string) ([]string, error) {
 	return albumIDs, nil
 }
 
-// findUserByEmail finds a user by email address.
-func (g *Generator) findUserByEmail() (*immich.User, error) {
-	req, err := g.client.NewRequest("GET", "/api/users", nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request for users: %w", err)
-	}
-
-	var users []immich.User
-	if err := g.client.Do(req, &users); err != nil {
-		return nil, fmt.Errorf("getting users: %w", err)
-	}
-
-	// Find user with matching email
-	for i, user := range users {
-		if strings.EqualFold(user.Email, g.email) {
-			return &users[i], nil
-		}
-	}
-
-	return nil, fmt.Errorf("no user found with email '%s'", g.email)
-}
-
 // createPlanForAlbums creates a plan for adding the user to the specified albums.
-func (g *Generator) createPlanForAlbums(albumIDs []string, targetUser *immich.User) (*plan.Plan, error) {
+func (g *Generator) createPlanForAlbums(
+	ctx context.Context, albumIDs []string, targetUser *immich.User,
+) (*plan.Plan, error) {
 	p := &plan.Plan{
 		Operations: make([]plan.Operation, 0, len(albumIDs)),
 	}
 
 	for _, albumID := range albumIDs {
 		// Get album details to check if user is already in the album
-		req, err := g.client.NewRequest("GET", "/api/albums/"+albumID, nil)
+		album, err := g.client.Albums.Get(ctx, albumID)
 		if err != nil {
-			return nil, fmt.Errorf("creating request for album %s: %w", albumID, err)
-		}
+			// Albums deleted or unshared since the search are skipped
+			if immich.IsNotFound(err) || immich.IsForbidden(err) {
+				continue
+			}
 
-		var album immich.Album
-		if err := g.client.Do(req, &album); err != nil {
 			return nil, fmt.Errorf("getting album %s: %w", albumID, err)
 		}
 
-		// Check if user is already in the album
-		userAlreadyInAlbum := false
-
-		for _, albumUser := range album.AlbumUsers {
-			if albumUser.User.ID == targetUser.ID {
-				userAlreadyInAlbum = true
-
-				break
-			}
-		}
-
-		if userAlreadyInAlbum {
+		// Skip albums the user is already in
+		if _, ok := album.Member(targetUser.ID); ok {
 			continue
 		}
 
 		// Add user to album request
-		addUserBody := map[string]any{
-			"albumUsers": []map[string]string{
-				{
-					"role":   "viewer",
-					"userId": targetUser.ID,
-				},
-			},
-		}
-
-		addUserBodyJSON, err := json.Marshal(addUserBody)
+		addUser, err := g.client.Albums.AddUsersRequest(album.ID, immich.AlbumUserAddition{
+			Role:   immich.RoleViewer,
+			UserID: targetUser.ID,
+		})
 		if err != nil {
-			return nil, fmt.Errorf("marshaling add user body: %w", err)
+			return nil, fmt.Errorf("building add user request: %w", err)
 		}
 
 		// Remove user from album request
-		removeUserPath := fmt.Sprintf("/api/albums/%s/user/%s", album.ID, targetUser.ID)
+		removeUser, err := g.client.Albums.RemoveUserRequest(album.ID, targetUser.ID)
+		if err != nil {
+			return nil, fmt.Errorf("building remove user request: %w", err)
+		}
 
+		p.SetName(album.ID, album.Name)
 		p.Operations = append(p.Operations, plan.Operation{
-			Apply: []plan.Request{
-				{
-					Path:   fmt.Sprintf("/api/albums/%s/users", album.ID),
-					Method: http.MethodPut,
-					Body:   addUserBodyJSON,
-				},
-			},
-			Revert: []plan.Request{
-				{
-					Path:   removeUserPath,
-					Method: http.MethodDelete,
-					Body:   nil,
-				},
-			},
+			Preconditions: []plan.Precondition{plan.AlbumNotMember(album.ID, targetUser.ID)},
+			Apply:         []plan.Request{addUser},
+			Revert:        []plan.Request{removeUser},
 		})
 	}
 
 	if len(p.Operations) == 0 {
-		return nil, errors.New("no changes needed - user is already in all albums containing assets for this person")
+		return nil, fmt.Errorf("%w - user is already in all albums containing assets for this person",
+			plan.ErrNoChanges)
 	}
 
+	p.SetName(targetUser.ID, targetUser.Email)
+
 	return p, nil
 }
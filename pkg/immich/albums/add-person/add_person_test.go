@@ -2,6 +2,7 @@ package addperson
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -10,6 +11,7 @@ import (
 	"testing"
 
 	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
 )
 
 //nolint:maintidx
@@ -21,7 +23,7 @@ func TestGenerator_Generate(t *testing.T) {
 		switch {
 		case r.URL.Path == "/api/search/metadata" && r.Method == http.MethodPost:
 			// Parse request body to get page number
-			var searchReq SearchMetadataRequest
+			var searchReq immich.MetadataSearchRequest
 			if err := json.NewDecoder(r.Body).Decode(&searchReq); err != nil {
 				http.Error(w, "Invalid request body", http.StatusBadRequest)
 
@@ -31,18 +33,18 @@ func TestGenerator_Generate(t *testing.T) {
 			// Mock paginated response
 			page, _ := strconv.Atoi(searchReq.Page)
 
-			var response SearchMetadataResponse
+			var response immich.MetadataSearchResponse
 
 			switch page {
 			case 1:
 				// First page with assets and nextPage
 				nextPage := "2"
-				response = SearchMetadataResponse{
+				response = immich.MetadataSearchResponse{
 					Assets: struct {
-						Items    []Asset `json:"items"`
-						NextPage *string `json:"nextPage"`
+						Items    []immich.Asset `json:"items"`
+						NextPage *string        `json:"nextPage"`
 					}{
-						Items: []Asset{
+						Items: []immich.Asset{
 							{ID: "asset1"},
 							{ID: "asset2"},
 						},
@@ -51,12 +53,12 @@ func TestGenerator_Generate(t *testing.T) {
 				}
 			case 2:
 				// Second page with assets, no nextPage (end of results)
-				response = SearchMetadataResponse{
+				response = immich.MetadataSearchResponse{
 					Assets: struct {
-						Items    []Asset `json:"items"`
-						NextPage *string `json:"nextPage"`
+						Items    []immich.Asset `json:"items"`
+						NextPage *string        `json:"nextPage"`
 					}{
-						Items: []Asset{
+						Items: []immich.Asset{
 							{ID: "asset3"},
 						},
 						NextPage: nil,
@@ -77,21 +79,21 @@ func TestGenerator_Generate(t *testing.T) {
 			// Mock albums by asset ID
 			assetID := r.URL.Query().Get("assetId")
 
-			var albums AlbumsByAssetResponse
+			var albums []immich.Album
 
 			switch assetID {
 			case "asset1":
-				albums = AlbumsByAssetResponse{
+				albums = []immich.Album{
 					{ID: "album1"},
 					{ID: "album2"},
 				}
 			case "asset2":
-				albums = AlbumsByAssetResponse{
+				albums = []immich.Album{
 					{ID: "album2"},
 					{ID: "album3"},
 				}
 			case "asset3":
-				albums = AlbumsByAssetResponse{
+				albums = []immich.Album{
 					{ID: "album3"},
 				}
 			}
@@ -279,12 +281,12 @@ func TestGenerator_NoAssetsForPerson(t *testing.T) {
 		switch r.URL.Path {
 		case "/api/search/metadata":
 			// Return empty response
-			response := SearchMetadataResponse{
+			response := immich.MetadataSearchResponse{
 				Assets: struct {
-					Items    []Asset `json:"items"`
-					NextPage *string `json:"nextPage"`
+					Items    []immich.Asset `json:"items"`
+					NextPage *string        `json:"nextPage"`
 				}{
-					Items:    []Asset{},
+					Items:    []immich.Asset{},
 					NextPage: nil,
 				},
 			}
@@ -326,12 +328,12 @@ func TestGenerator_NoAlbumsContainAssets(t *testing.T) {
 		switch {
 		case r.URL.Path == "/api/search/metadata":
 			// Return assets
-			response := SearchMetadataResponse{
+			response := immich.MetadataSearchResponse{
 				Assets: struct {
-					Items    []Asset `json:"items"`
-					NextPage *string `json:"nextPage"`
+					Items    []immich.Asset `json:"items"`
+					NextPage *string        `json:"nextPage"`
 				}{
-					Items: []Asset{
+					Items: []immich.Asset{
 						{ID: "asset1"},
 					},
 					NextPage: nil,
@@ -344,7 +346,7 @@ func TestGenerator_NoAlbumsContainAssets(t *testing.T) {
 
 		case strings.HasPrefix(r.URL.Path, "/api/albums") && strings.Contains(r.URL.RawQuery, "assetId="):
 			// Return empty albums list for all assets
-			albums := AlbumsByAssetResponse{}
+			albums := []immich.Album{}
 			_ = json.NewEncoder(w).Encode(albums)
 
 			return
@@ -383,12 +385,12 @@ func TestGenerator_UserNotFound(t *testing.T) {
 		switch {
 		case r.URL.Path == "/api/search/metadata":
 			// Return assets
-			response := SearchMetadataResponse{
+			response := immich.MetadataSearchResponse{
 				Assets: struct {
-					Items    []Asset `json:"items"`
-					NextPage *string `json:"nextPage"`
+					Items    []immich.Asset `json:"items"`
+					NextPage *string        `json:"nextPage"`
 				}{
-					Items: []Asset{
+					Items: []immich.Asset{
 						{ID: "asset1"},
 					},
 					NextPage: nil,
@@ -401,7 +403,7 @@ func TestGenerator_UserNotFound(t *testing.T) {
 
 		case strings.HasPrefix(r.URL.Path, "/api/albums") && strings.Contains(r.URL.RawQuery, "assetId="):
 			// Return albums containing the asset
-			albums := AlbumsByAssetResponse{
+			albums := []immich.Album{
 				{ID: "album1"},
 			}
 
@@ -453,12 +455,12 @@ func TestGenerator_UserAlreadyInAllAlbums(t *testing.T) {
 		switch {
 		case r.URL.Path == "/api/search/metadata":
 			// Return assets
-			response := SearchMetadataResponse{
+			response := immich.MetadataSearchResponse{
 				Assets: struct {
-					Items    []Asset `json:"items"`
-					NextPage *string `json:"nextPage"`
+					Items    []immich.Asset `json:"items"`
+					NextPage *string        `json:"nextPage"`
 				}{
-					Items: []Asset{
+					Items: []immich.Asset{
 						{ID: "asset1"},
 					},
 					NextPage: nil,
@@ -471,7 +473,7 @@ func TestGenerator_UserAlreadyInAllAlbums(t *testing.T) {
 
 		case strings.HasPrefix(r.URL.Path, "/api/albums") && strings.Contains(r.URL.RawQuery, "assetId="):
 			// Return albums containing the asset
-			albums := AlbumsByAssetResponse{
+			albums := []immich.Album{
 				{ID: "album1"},
 			}
 
@@ -525,6 +527,10 @@ func TestGenerator_UserAlreadyInAllAlbums(t *testing.T) {
 		t.Error("Expected error for user already in all albums, got nil")
 	}
 
+	if !errors.Is(err, plan.ErrNoChanges) {
+		t.Errorf("Expected error to wrap ErrNoChanges, got '%v'", err)
+	}
+
 	expectedErrorMsg := "no changes needed - user is already in all albums containing assets for this person"
 	if !strings.Contains(err.Error(), expectedErrorMsg) {
 		t.Errorf("Expected error message to contain '%s', got '%s'", expectedErrorMsg, err.Error())
@@ -542,7 +548,7 @@ func TestGenerator_PaginatedAssets(t *testing.T) {
 			requestCount++
 
 			// Parse request body to get page number
-			var searchReq SearchMetadataRequest
+			var searchReq immich.MetadataSearchRequest
 			if err := json.NewDecoder(r.Body).Decode(&searchReq); err != nil {
 				http.Error(w, "Invalid request body", http.StatusBadRequest)
 
@@ -552,17 +558,17 @@ func TestGenerator_PaginatedAssets(t *testing.T) {
 			// Mock paginated response across 3 pages
 			page, _ := strconv.Atoi(searchReq.Page)
 
-			var response SearchMetadataResponse
+			var response immich.MetadataSearchResponse
 
 			switch page {
 			case 1:
 				nextPage := "2"
-				response = SearchMetadataResponse{
+				response = immich.MetadataSearchResponse{
 					Assets: struct {
-						Items    []Asset `json:"items"`
-						NextPage *string `json:"nextPage"`
+						Items    []immich.Asset `json:"items"`
+						NextPage *string        `json:"nextPage"`
 					}{
-						Items: []Asset{
+						Items: []immich.Asset{
 							{ID: "asset1"},
 							{ID: "asset2"},
 						},
@@ -571,12 +577,12 @@ func TestGenerator_PaginatedAssets(t *testing.T) {
 				}
 			case 2:
 				nextPage := "3"
-				response = SearchMetadataResponse{
+				response = immich.MetadataSearchResponse{
 					Assets: struct {
-						Items    []Asset `json:"items"`
-						NextPage *string `json:"nextPage"`
+						Items    []immich.Asset `json:"items"`
+						NextPage *string        `json:"nextPage"`
 					}{
-						Items: []Asset{
+						Items: []immich.Asset{
 							{ID: "asset3"},
 							{ID: "asset4"},
 						},
@@ -585,12 +591,12 @@ func TestGenerator_PaginatedAssets(t *testing.T) {
 				}
 			case 3:
 				// Final page
-				response = SearchMetadataResponse{
+				response = immich.MetadataSearchResponse{
 					Assets: struct {
-						Items    []Asset `json:"items"`
-						NextPage *string `json:"nextPage"`
+						Items    []immich.Asset `json:"items"`
+						NextPage *string        `json:"nextPage"`
 					}{
-						Items: []Asset{
+						Items: []immich.Asset{
 							{ID: "asset5"},
 						},
 						NextPage: nil,
@@ -608,7 +614,7 @@ func TestGenerator_PaginatedAssets(t *testing.T) {
 
 		case strings.HasPrefix(r.URL.Path, "/api/albums") && strings.Contains(r.URL.RawQuery, "assetId="):
 			// All assets belong to the same album for simplicity
-			albums := AlbumsByAssetResponse{
+			albums := []immich.Album{
 				{ID: "album1"},
 			}
 
@@ -664,3 +670,41 @@ func TestGenerator_PaginatedAssets(t *testing.T) {
 		t.Errorf("Expected 1 operation, got %d", len(p.Operations))
 	}
 }
+
+func TestGenerator_SkipsDeletedAlbums(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/search/metadata":
+			response := immich.MetadataSearchResponse{}
+			response.Assets.Items = []immich.Asset{{ID: "asset1"}}
+			_ = json.NewEncoder(w).Encode(response)
+		case r.URL.Path == "/api/albums" && r.URL.Query().Get("assetId") == "asset1":
+			_ = json.NewEncoder(w).Encode([]immich.Album{{ID: "album1"}, {ID: "deleted"}})
+		case r.URL.Path == "/api/users":
+			_ = json.NewEncoder(w).Encode([]immich.User{{ID: "user123", Email: "test@example.com"}})
+		case r.URL.Path == "/api/albums/album1":
+			_ = json.NewEncoder(w).Encode(immich.Album{ID: "album1", Name: "Summer Vacation"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"Album not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	generator := NewGenerator(immich.NewClient(server.URL, "test-token"), "person123", "test@example.com")
+
+	p, err := generator.Generate()
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	if len(p.Operations) != 1 {
+		t.Fatalf("Expected 1 operation, got %d", len(p.Operations))
+	}
+
+	if p.Operations[0].Apply[0].Path != "/api/albums/album1/users" {
+		t.Errorf("Expected operation for album1, got %s", p.Operations[0].Apply[0].Path)
+	}
+}
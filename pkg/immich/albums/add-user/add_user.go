@@ -2,10 +2,8 @@
 package adduser
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"immich-manager/pkg/immich"
@@ -30,14 +28,15 @@ func NewGenerator(client *immich.Client, searchTerm, email string) *Generator {
 
 // Generate creates a plan for adding a user to albums matching the search term.
 func (g *Generator) Generate() (*plan.Plan, error) {
+	return g.GenerateContext(context.Background())
+}
+
+// GenerateContext is like Generate but aborts its API requests when ctx
+// is cancelled.
+func (g *Generator) GenerateContext(ctx context.Context) (*plan.Plan, error) {
 	// Get all albums
-	req, err := g.client.NewRequest("GET", "/api/albums", nil)
+	albums, err := g.client.Albums.List(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating request for albums: %w", err)
-	}
-
-	var albums []immich.Album
-	if err := g.client.Do(req, &albums); err != nil {
 		return nil, fmt.Errorf("getting albums: %w", err)
 	}
 
@@ -54,30 +53,10 @@ func (g *Generator) Generate() (*plan.Plan, error) {
 		return nil, fmt.Errorf("no albums found matching search term '%s'", g.searchTerm)
 	}
 
-	// Get all users and find the one with matching email
-	req, err = g.client.NewRequest("GET", "/api/users", nil)
+	// Find the user with matching email
+	targetUser, err := g.client.Users.FindByEmail(ctx, g.email)
 	if err != nil {
-		return nil, fmt.Errorf("creating request for users: %w", err)
-	}
-
-	var users []immich.User
-	if err := g.client.Do(req, &users); err != nil {
-		return nil, fmt.Errorf("getting users: %w", err)
-	}
-
-	// Find user with matching email
-	var targetUser *immich.User
-
-	for i, user := range users {
-		if strings.EqualFold(user.Email, g.email) {
-			targetUser = &users[i]
-
-			break
-		}
-	}
-
-	if targetUser == nil {
-		return nil, fmt.Errorf("no user found with email '%s'", g.email)
+		return nil, fmt.Errorf("finding user: %w", err)
 	}
 
 	p := &plan.Plan{
@@ -86,61 +65,40 @@ func (g *Generator) Generate() (*plan.Plan, error) {
 
 	// Create operations for each album
 	for _, album := range filteredAlbums {
-		// Check if user is already in the album
-		userAlreadyInAlbum := false
-
-		for _, albumUser := range album.AlbumUsers {
-			if albumUser.User.ID == targetUser.ID {
-				userAlreadyInAlbum = true
-
-				break
-			}
-		}
-
-		if userAlreadyInAlbum {
+		// Skip albums the user is already in
+		if _, ok := album.Member(targetUser.ID); ok {
 			continue
 		}
 
 		// Add user to album request
-		addUserBody := map[string]any{
-			"albumUsers": []map[string]string{
-				{
-					"role":   "viewer",
-					"userId": targetUser.ID,
-				},
-			},
-		}
-
-		addUserBodyJSON, err := json.Marshal(addUserBody)
+		addUser, err := g.client.Albums.AddUsersRequest(album.ID, immich.AlbumUserAddition{
+			Role:   immich.RoleViewer,
+			UserID: targetUser.ID,
+		})
 		if err != nil {
-			return nil, fmt.Errorf("marshaling add user body: %w", err)
+			return nil, fmt.Errorf("building add user request: %w", err)
 		}
 
 		// Remove user from album request
-		removeUserPath := fmt.Sprintf("/api/albums/%s/user/%s", album.ID, targetUser.ID)
+		removeUser, err := g.client.Albums.RemoveUserRequest(album.ID, targetUser.ID)
+		if err != nil {
+			return nil, fmt.Errorf("building remove user request: %w", err)
+		}
 
+		p.SetName(album.ID, album.Name)
 		p.Operations = append(p.Operations, plan.Operation{
-			Apply: []plan.Request{
-				{
-					Path:   fmt.Sprintf("/api/albums/%s/users", album.ID),
-					Method: http.MethodPut,
-					Body:   addUserBodyJSON,
-				},
-			},
-			Revert: []plan.Request{
-				{
-					Path:   removeUserPath,
-					Method: http.MethodDelete,
-					Body:   nil,
-				},
-			},
+			Preconditions: []plan.Precondition{plan.AlbumNotMember(album.ID, targetUser.ID)},
+			Apply:         []plan.Request{addUser},
+			Revert:        []plan.Request{removeUser},
 		})
 	}
 
 	if len(p.Operations) == 0 {
-		return nil, errors.New("no changes needed - user is already in all matching albums")
+		return nil, fmt.Errorf("%w - user is already in all matching albums", plan.ErrNoChanges)
 	}
 
+	p.SetName(targetUser.ID, targetUser.Email)
+
 	return p, nil
 }
 
@@ -2,9 +2,8 @@
 package clearshared
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"immich-manager/pkg/immich"
@@ -27,52 +26,22 @@ func NewGenerator(client *immich.Client, email string) *Generator {
 
 // Generate creates a plan for removing a user from shared albums.
 func (g *Generator) Generate() (*plan.Plan, error) {
-	// Get all shared albums
-	req, err := g.client.NewRequest("GET", "/api/albums?shared=true", nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request for shared albums: %w", err)
-	}
-
-	// Define structs to match the API response structure
-	type AlbumUserUser struct {
-		ID    string `json:"id"`
-		Email string `json:"email"`
-		Name  string `json:"name"`
-	}
-
-	type AlbumUser struct {
-		User AlbumUserUser `json:"user"`
-		Role string        `json:"role"`
-	}
-
-	type Owner struct {
-		ID               string `json:"id"`
-		Email            string `json:"email"`
-		Name             string `json:"name"`
-		ProfileImagePath string `json:"profileImagePath"`
-		AvatarColor      string `json:"avatarColor"`
-	}
+	return g.GenerateContext(context.Background())
+}
 
-	type Album struct {
-		ID          string      `json:"id"`
-		AlbumName   string      `json:"albumName"`
-		OwnerID     string      `json:"ownerId"`
-		Owner       Owner       `json:"owner"`
-		Shared      bool        `json:"shared"`
-		AlbumUsers  []AlbumUser `json:"albumUsers"`
-		Description string      `json:"description"`
-		CreatedAt   string      `json:"createdAt"`
-		UpdatedAt   string      `json:"updatedAt"`
-		AssetCount  int         `json:"assetCount"`
-	}
+// GenerateContext is like Generate but aborts its API requests when ctx
+// is cancelled.
+func (g *Generator) GenerateContext(ctx context.Context) (*plan.Plan, error) {
+	// Get all shared albums
+	shared := true
 
-	var sharedAlbums []Album
-	if err := g.client.Do(req, &sharedAlbums); err != nil {
+	sharedAlbums, err := g.client.Albums.List(ctx, &immich.AlbumListOptions{Shared: &shared})
+	if err != nil {
 		return nil, fmt.Errorf("getting shared albums: %w", err)
 	}
 
 	// Filter shared albums by those that include the target user
-	userSharedAlbums := make([]Album, 0)
+	userSharedAlbums := make([]immich.Album, 0)
 
 	var targetUserID string
 
@@ -91,7 +60,7 @@ func (g *Generator) Generate() (*plan.Plan, error) {
 	}
 
 	if len(userSharedAlbums) == 0 {
-		return nil, fmt.Errorf("no shared albums found for user '%s'", g.email)
+		return nil, fmt.Errorf("%w - no shared albums found for user '%s'", plan.ErrNoChanges, g.email)
 	}
 
 	if targetUserID == "" {
@@ -101,6 +70,7 @@ func (g *Generator) Generate() (*plan.Plan, error) {
 	p := &plan.Plan{
 		Operations: make([]plan.Operation, 0, len(userSharedAlbums)),
 	}
+	p.SetName(targetUserID, g.email)
 
 	// Create operations for each album
 	for i := range userSharedAlbums {
@@ -118,44 +88,31 @@ func (g *Generator) Generate() (*plan.Plan, error) {
 
 		// Default to "viewer" if role is not found or invalid
 		if userRole == "" {
-			userRole = "viewer"
-		} else if userRole != "viewer" && userRole != "editor" {
+			userRole = immich.RoleViewer
+		} else if userRole != immich.RoleViewer && userRole != immich.RoleEditor {
 			// Ensure role is one of the valid values
-			userRole = "viewer"
+			userRole = immich.RoleViewer
 		}
 
-		// Prepare revert (add user back) body
-		revertBody := map[string]any{
-			"albumUsers": []map[string]string{
-				{
-					"role":   userRole,
-					"userId": targetUserID,
-				},
-			},
+		removeUser, err := g.client.Albums.RemoveUserRequest(album.ID, targetUserID)
+		if err != nil {
+			return nil, fmt.Errorf("building remove user request: %w", err)
 		}
 
-		revertBodyJSON, err := json.Marshal(revertBody)
+		// Revert adds the user back with their previous role
+		addUser, err := g.client.Albums.AddUsersRequest(album.ID, immich.AlbumUserAddition{
+			Role:   userRole,
+			UserID: targetUserID,
+		})
 		if err != nil {
-			return nil, fmt.Errorf("marshaling revert body: %w", err)
+			return nil, fmt.Errorf("building add user request: %w", err)
 		}
 
-		// For DELETE requests to the Immich API, we need to explicitly specify
-		// nil for the body to ensure no data is sent
+		p.SetName(album.ID, album.Name)
 		p.Operations = append(p.Operations, plan.Operation{
-			Apply: []plan.Request{
-				{
-					Path:   fmt.Sprintf("/api/albums/%s/user/%s", album.ID, targetUserID),
-					Method: http.MethodDelete,
-					// Explicitly set no body for DELETE request
-				},
-			},
-			Revert: []plan.Request{
-				{
-					Path:   fmt.Sprintf("/api/albums/%s/users", album.ID),
-					Method: http.MethodPut,
-					Body:   revertBodyJSON,
-				},
-			},
+			Preconditions: []plan.Precondition{plan.AlbumMember(album.ID, targetUserID)},
+			Apply:         []plan.Request{removeUser},
+			Revert:        []plan.Request{addUser},
 		})
 	}
 
@@ -41,86 +41,50 @@ func TestGenerator_Generate(t *testing.T) {
 			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				// Handle shared albums request
 				if r.URL.Path == "/api/albums" && r.Method == http.MethodGet && strings.Contains(r.URL.RawQuery, "shared=true") {
-					type AlbumUserUser struct {
-						ID    string `json:"id"`
-						Email string `json:"email"`
-						Name  string `json:"name"`
-					}
-
-					type AlbumUser struct {
-						User AlbumUserUser `json:"user"`
-						Role string        `json:"role"`
-					}
-
-					type Owner struct {
-						ID               string `json:"id"`
-						Email            string `json:"email"`
-						Name             string `json:"name"`
-						ProfileImagePath string `json:"profileImagePath"`
-						AvatarColor      string `json:"avatarColor"`
-					}
-
-					type Album struct {
-						ID          string      `json:"id"`
-						AlbumName   string      `json:"albumName"`
-						OwnerID     string      `json:"ownerId"`
-						Owner       Owner       `json:"owner"`
-						Shared      bool        `json:"shared"`
-						AlbumUsers  []AlbumUser `json:"albumUsers"`
-						Description string      `json:"description"`
-						CreatedAt   string      `json:"createdAt"`
-						UpdatedAt   string      `json:"updatedAt"`
-						AssetCount  int         `json:"assetCount"`
-					}
-
-					owner := Owner{
+					owner := immich.User{
 						ID:    "user1",
 						Email: "user1@example.com",
 						Name:  "User One",
 					}
 
-					user1 := AlbumUserUser{
-						ID:    "user1",
-						Email: "user1@example.com",
-						Name:  "User One",
-					}
+					user1 := owner
 
-					user2 := AlbumUserUser{
+					user2 := immich.User{
 						ID:    "user2",
 						Email: "user2@example.com",
 						Name:  "User Two",
 					}
 
-					albums := []Album{
+					albums := []immich.Album{
 						{
-							ID:        "album1",
-							AlbumName: "Family Photos",
-							OwnerID:   "user1",
-							Owner:     owner,
-							Shared:    true,
-							AlbumUsers: []AlbumUser{
+							ID:      "album1",
+							Name:    "Family Photos",
+							OwnerID: "user1",
+							Owner:   owner,
+							Shared:  true,
+							AlbumUsers: []immich.AlbumUser{
 								{User: user1, Role: "owner"},
 								{User: user2, Role: "viewer"},
 							},
 						},
 						{
-							ID:        "album2",
-							AlbumName: "Vacation 2023",
-							OwnerID:   "user1",
-							Owner:     owner,
-							Shared:    true,
-							AlbumUsers: []AlbumUser{
+							ID:      "album2",
+							Name:    "Vacation 2023",
+							OwnerID: "user1",
+							Owner:   owner,
+							Shared:  true,
+							AlbumUsers: []immich.AlbumUser{
 								{User: user1, Role: "owner"},
 								{User: user2, Role: "viewer"},
 							},
 						},
 						{
-							ID:        "album3",
-							AlbumName: "Work Documents",
-							OwnerID:   "user1",
-							Owner:     owner,
-							Shared:    true,
-							AlbumUsers: []AlbumUser{
+							ID:      "album3",
+							Name:    "Work Documents",
+							OwnerID: "user1",
+							Owner:   owner,
+							Shared:  true,
+							AlbumUsers: []immich.AlbumUser{
 								{User: user1, Role: "owner"},
 							},
 						},
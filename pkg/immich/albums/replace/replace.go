@@ -2,9 +2,8 @@
 package replace
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"immich-manager/pkg/immich"
@@ -29,14 +28,15 @@ func NewGenerator(client *immich.Client, before, after string) *Generator {
 
 // Generate creates a plan for renaming albums.
 func (g *Generator) Generate() (*plan.Plan, error) {
+	return g.GenerateContext(context.Background())
+}
+
+// GenerateContext is like Generate but aborts its API requests when ctx
+// is cancelled.
+func (g *Generator) GenerateContext(ctx context.Context) (*plan.Plan, error) {
 	// Get all albums
-	req, err := g.client.NewRequest("GET", "/api/albums", nil)
+	albums, err := g.client.Albums.List(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
-
-	var albums []immich.Album
-	if err := g.client.Do(req, &albums); err != nil {
 		return nil, fmt.Errorf("getting albums: %w", err)
 	}
 
@@ -56,42 +56,28 @@ func (g *Generator) Generate() (*plan.Plan, error) {
 		}
 
 		// Create update operation
-		updateBody := map[string]string{
-			"albumName": newName,
-		}
-		jsonBody, err := json.Marshal(updateBody)
-
+		update, err := g.client.Albums.UpdateRequest(album.ID, immich.AlbumUpdate{Name: newName})
 		if err != nil {
-			return nil, fmt.Errorf("marshaling update body: %w", err)
+			return nil, fmt.Errorf("building update request: %w", err)
 		}
 
 		// Create revert operation
-		revertBody := map[string]string{
-			"albumName": album.Name,
-		}
-		revertJSONBody, err := json.Marshal(revertBody)
-
+		revert, err := g.client.Albums.UpdateRequest(album.ID, immich.AlbumUpdate{Name: album.Name})
 		if err != nil {
-			return nil, fmt.Errorf("marshaling revert body: %w", err)
+			return nil, fmt.Errorf("building revert request: %w", err)
 		}
 
+		p.SetName(album.ID, album.Name)
 		p.Operations = append(p.Operations, plan.Operation{
-			Apply: []plan.Request{
-				{
-					Path:   "/api/albums/" + album.ID,
-					Method: http.MethodPatch,
-					Body:   jsonBody,
-				},
-			},
-			Revert: []plan.Request{
-				{
-					Path:   "/api/albums/" + album.ID,
-					Method: http.MethodPatch,
-					Body:   revertJSONBody,
-				},
-			},
+			Preconditions: []plan.Precondition{plan.AlbumNamed(album.ID, album.Name)},
+			Apply:         []plan.Request{update},
+			Revert:        []plan.Request{revert},
 		})
 	}
 
+	if len(p.Operations) == 0 {
+		return nil, fmt.Errorf("%w - no album names contain '%s'", plan.ErrNoChanges, g.before)
+	}
+
 	return p, nil
 }
@@ -2,11 +2,14 @@ package replace
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
 )
 
 func TestGenerator_Generate(t *testing.T) {
@@ -46,6 +49,11 @@ func TestGenerator_Generate(t *testing.T) {
 	// Verify first operation
 	op1 := p.Operations[0]
 
+	// Verify the rename only applies while the album keeps its old name
+	if !reflect.DeepEqual(op1.Preconditions, []plan.Precondition{plan.AlbumNamed("1", "foo album")}) {
+		t.Errorf("Unexpected preconditions %+v", op1.Preconditions)
+	}
+
 	// Verify apply operations
 	if len(op1.Apply) != 1 {
 		t.Errorf("Expected 1 apply request, got %d", len(op1.Apply))
@@ -143,3 +151,25 @@ func TestGenerator_Generate(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerator_NoMatchingAlbums(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/albums" {
+			_ = json.NewEncoder(w).Encode([]immich.Album{{ID: "1", Name: "bar album"}})
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := immich.NewClient(server.URL, "test-token")
+
+	_, err := NewGenerator(client, "foo", "baz").Generate()
+	if !errors.Is(err, plan.ErrNoChanges) {
+		t.Errorf("Generate() error = %v, want %v", err, plan.ErrNoChanges)
+	}
+}
@@ -2,11 +2,13 @@
 package smart
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"net/http"
+	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"immich-manager/pkg/immich"
 	"immich-manager/pkg/plan"
@@ -31,8 +33,14 @@ func NewGenerator(client *immich.Client, email string) *Generator {
 
 // Generate creates a plan for managing a smart album.
 func (g *Generator) Generate() (*plan.Plan, error) {
+	return g.GenerateContext(context.Background())
+}
+
+// GenerateContext is like Generate but aborts its API requests when ctx
+// is cancelled.
+func (g *Generator) GenerateContext(ctx context.Context) (*plan.Plan, error) {
 	// 1. Find the user by email
-	user, err := g.findUserByEmail()
+	user, err := g.client.Users.FindByEmail(ctx, g.email)
 	if err != nil {
 		return nil, fmt.Errorf("finding user by email: %w", err)
 	}
@@ -41,39 +49,51 @@ func (g *Generator) Generate() (*plan.Plan, error) {
 	smartAlbumName := "All " + user.Name
 
 	// 3. Find the "All NAME" album
-	smartAlbum, err := g.findSmartAlbum(smartAlbumName)
-	if err != nil {
-		if errors.Is(err, ErrAlbumNotFound) {
-			return nil, fmt.Errorf("smart album '%s' does not exist - please create it first", smartAlbumName)
-		}
-
+	smartAlbum, err := g.findSmartAlbum(ctx, smartAlbumName)
+	if err != nil && !errors.Is(err, ErrAlbumNotFound) {
 		return nil, fmt.Errorf("finding smart album: %w", err)
 	}
 
 	// 4. Get all shared albums for the user
-	sharedAlbums, err := g.getSharedAlbums(user.ID)
+	sharedAlbums, err := g.getSharedAlbums(ctx, user.ID)
 	if err != nil {
 		return nil, fmt.Errorf("getting shared albums: %w", err)
 	}
 
 	// 5. Collect all assets from shared albums
-	sharedAssets, err := g.getAssetsFromSharedAlbums(sharedAlbums)
+	sharedAssets, err := g.getAssetsFromSharedAlbums(ctx, sharedAlbums)
 	if err != nil {
 		return nil, fmt.Errorf("getting assets from shared albums: %w", err)
 	}
 
-	// 6. Get current assets in the smart album
-	smartAlbumAssets, err := g.getAlbumAssets(smartAlbum.ID)
-	if err != nil {
-		return nil, fmt.Errorf("getting assets in smart album: %w", err)
-	}
-
-	// 7. Create plan operations
+	// 6. Create plan operations, starting with creating the smart album
+	// when it is missing, or get its current assets
 	p := &plan.Plan{
 		Operations: make([]plan.Operation, 0),
 	}
 
-	// Find assets to remove (in smart album but not in any shared album)
+	smartAlbumAssets := make(map[string]bool)
+	created := smartAlbum == nil
+
+	if created {
+		create, err := g.createAlbumOperation(smartAlbumName)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Operations = append(p.Operations, create)
+		smartAlbum = &immich.Album{ID: plan.Placeholder(create.ID, "id"), Name: smartAlbumName}
+	} else {
+		smartAlbumAssets, err = g.getAlbumAssets(ctx, smartAlbum.ID)
+		if err != nil {
+			return nil, fmt.Errorf("getting assets in smart album: %w", err)
+		}
+	}
+
+	p.SetName(smartAlbum.ID, smartAlbum.Name)
+
+	// Find assets to remove (in smart album but not in any shared album),
+	// sorted so that the same state always gives the same plan
 	assetsToRemove := make([]string, 0)
 
 	for assetID := range smartAlbumAssets {
@@ -82,42 +102,25 @@ func (g *Generator) Generate() (*plan.Plan, error) {
 		}
 	}
 
+	sort.Strings(assetsToRemove)
+
 	// Create operations for removing assets
 	if len(assetsToRemove) > 0 {
-		removeBody := map[string]any{
-			"ids": assetsToRemove,
-		}
-
-		removeBodyJSON, err := json.Marshal(removeBody)
+		remove, err := g.client.Albums.RemoveAssetsRequest(smartAlbum.ID, assetsToRemove)
 		if err != nil {
-			return nil, fmt.Errorf("marshaling remove assets body: %w", err)
+			return nil, fmt.Errorf("building remove assets request: %w", err)
 		}
 
 		// Revert would be to add these assets back to the album
-		addRemovedBody := map[string]any{
-			"ids": assetsToRemove,
-		}
-
-		addRemovedBodyJSON, err := json.Marshal(addRemovedBody)
+		addRemoved, err := g.client.Albums.AddAssetsRequest(smartAlbum.ID, assetsToRemove)
 		if err != nil {
-			return nil, fmt.Errorf("marshaling add removed assets body: %w", err)
+			return nil, fmt.Errorf("building add removed assets request: %w", err)
 		}
 
 		p.Operations = append(p.Operations, plan.Operation{
-			Apply: []plan.Request{
-				{
-					Path:   fmt.Sprintf("/api/albums/%s/assets", smartAlbum.ID),
-					Method: http.MethodDelete,
-					Body:   removeBodyJSON,
-				},
-			},
-			Revert: []plan.Request{
-				{
-					Path:   fmt.Sprintf("/api/albums/%s/assets", smartAlbum.ID),
-					Method: http.MethodPut,
-					Body:   addRemovedBodyJSON,
-				},
-			},
+			Preconditions: []plan.Precondition{plan.AlbumExists(smartAlbum.ID)},
+			Apply:         []plan.Request{remove},
+			Revert:        []plan.Request{addRemoved},
 		})
 	}
 
@@ -130,84 +133,98 @@ func (g *Generator) Generate() (*plan.Plan, error) {
 		}
 	}
 
+	sort.Strings(assetsToAdd)
+
 	// Create operations for adding assets
 	if len(assetsToAdd) > 0 {
-		addBody := map[string]any{
-			"ids": assetsToAdd,
+		add, err := g.client.Albums.AddAssetsRequest(smartAlbum.ID, assetsToAdd)
+		if err != nil {
+			return nil, fmt.Errorf("building add assets request: %w", err)
 		}
 
-		addBodyJSON, err := json.Marshal(addBody)
+		// Revert would be to remove these assets from the album
+		removeAdded, err := g.client.Albums.RemoveAssetsRequest(smartAlbum.ID, assetsToAdd)
 		if err != nil {
-			return nil, fmt.Errorf("marshaling add assets body: %w", err)
+			return nil, fmt.Errorf("building remove added assets request: %w", err)
 		}
 
-		// Revert would be to remove these assets from the album
-		removeAddedBody := map[string]any{
-			"ids": assetsToAdd,
+		op := plan.Operation{
+			Preconditions: []plan.Precondition{plan.AlbumExists(smartAlbum.ID)},
+			Apply:         []plan.Request{add},
+			Revert:        []plan.Request{removeAdded},
 		}
 
-		removeAddedBodyJSON, err := json.Marshal(removeAddedBody)
-		if err != nil {
-			return nil, fmt.Errorf("marshaling remove added assets body: %w", err)
+		// A new album only exists once the plan has created it
+		if created {
+			op.Preconditions = nil
+			op.DependsOn = []string{createAlbumID(smartAlbumName)}
 		}
 
-		p.Operations = append(p.Operations, plan.Operation{
-			Apply: []plan.Request{
-				{
-					Path:   fmt.Sprintf("/api/albums/%s/assets", smartAlbum.ID),
-					Method: http.MethodPut,
-					Body:   addBodyJSON,
-				},
-			},
-			Revert: []plan.Request{
-				{
-					Path:   fmt.Sprintf("/api/albums/%s/assets", smartAlbum.ID),
-					Method: http.MethodDelete,
-					Body:   removeAddedBodyJSON,
-				},
-			},
-		})
+		p.Operations = append(p.Operations, op)
 	}
 
-	// If no operations are needed, return an empty plan
 	if len(p.Operations) == 0 {
-		return p, nil
+		return nil, fmt.Errorf("%w - album '%s' already holds exactly the assets of the shared albums",
+			plan.ErrNoChanges, smartAlbumName)
 	}
 
 	return p, nil
 }
 
-// findUserByEmail finds a user by their email address.
-func (g *Generator) findUserByEmail() (*immich.User, error) {
-	req, err := g.client.NewRequest("GET", "/api/users", nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request for users: %w", err)
+// createAlbumID identifies the operation creating the smart album with the
+// given name, whose ID the other operations reference. It is derived from
+// the name so that plans for different smart albums can be merged.
+func createAlbumID(name string) string {
+	var b strings.Builder
+
+	b.WriteString("create")
+
+	separate := true
+
+	for _, r := range strings.ToLower(name) {
+		if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if separate {
+				b.WriteByte('_')
+			}
+
+			b.WriteRune(r)
+
+			separate = false
+		} else {
+			separate = true
+		}
 	}
 
-	var users []immich.User
-	if err := g.client.Do(req, &users); err != nil {
-		return nil, fmt.Errorf("getting users: %w", err)
+	return b.String()
+}
+
+// createAlbumOperation returns the operation creating the smart album,
+// which deletes it again when reverted.
+func (g *Generator) createAlbumOperation(name string) (plan.Operation, error) {
+	create, err := g.client.Albums.CreateRequest(immich.AlbumCreate{Name: name})
+	if err != nil {
+		return plan.Operation{}, fmt.Errorf("building create album request: %w", err)
 	}
 
-	for i, user := range users {
-		if strings.EqualFold(user.Email, g.email) {
-			return &users[i], nil
-		}
+	id := createAlbumID(name)
+
+	remove, err := g.client.Albums.DeleteRequest(plan.Placeholder(id, "id"))
+	if err != nil {
+		return plan.Operation{}, fmt.Errorf("building delete album request: %w", err)
 	}
 
-	return nil, fmt.Errorf("no user found with email '%s'", g.email)
+	return plan.Operation{
+		ID:     id,
+		Apply:  []plan.Request{create},
+		Revert: []plan.Request{remove},
+	}, nil
 }
 
 // findSmartAlbum finds the "All NAME" album, or returns nil if it doesn't exist.
-func (g *Generator) findSmartAlbum(albumName string) (*immich.Album, error) {
+func (g *Generator) findSmartAlbum(ctx context.Context, albumName string) (*immich.Album, error) {
 	// Try to find an existing album with this name
-	req, err := g.client.NewRequest("GET", "/api/albums", nil)
+	albums, err := g.client.Albums.List(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating request for albums: %w", err)
-	}
-
-	var albums []immich.Album
-	if err := g.client.Do(req, &albums); err != nil {
 		return nil, fmt.Errorf("getting albums: %w", err)
 	}
 
@@ -222,14 +239,9 @@ func (g *Generator) findSmartAlbum(albumName string) (*immich.Album, error) {
 }
 
 // getSharedAlbums gets all albums shared with the user.
-func (g *Generator) getSharedAlbums(userID string) ([]immich.Album, error) {
-	req, err := g.client.NewRequest("GET", "/api/albums", nil)
+func (g *Generator) getSharedAlbums(ctx context.Context, userID string) ([]immich.Album, error) {
+	allAlbums, err := g.client.Albums.List(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating request for albums: %w", err)
-	}
-
-	var allAlbums []immich.Album
-	if err := g.client.Do(req, &allAlbums); err != nil {
 		return nil, fmt.Errorf("getting albums: %w", err)
 	}
 
@@ -243,13 +255,8 @@ func (g *Generator) getSharedAlbums(userID string) ([]immich.Album, error) {
 		}
 
 		// Check if the user is in this album
-
-		for _, albumUser := range album.AlbumUsers {
-			if albumUser.User.ID == userID {
-				sharedAlbums = append(sharedAlbums, album)
-
-				break
-			}
+		if _, ok := album.Member(userID); ok {
+			sharedAlbums = append(sharedAlbums, album)
 		}
 	}
 
@@ -257,12 +264,17 @@ func (g *Generator) getSharedAlbums(userID string) ([]immich.Album, error) {
 }
 
 // getAssetsFromSharedAlbums gets all unique assets from the shared albums.
-func (g *Generator) getAssetsFromSharedAlbums(albums []immich.Album) (map[string]bool, error) {
+func (g *Generator) getAssetsFromSharedAlbums(ctx context.Context, albums []immich.Album) (map[string]bool, error) {
 	uniqueAssets := make(map[string]bool)
 
 	for _, album := range albums {
-		assets, err := g.getAlbumAssets(album.ID)
+		assets, err := g.getAlbumAssets(ctx, album.ID)
 		if err != nil {
+			// The album was deleted after it was listed
+			if immich.IsNotFound(err) {
+				continue
+			}
+
 			return nil, fmt.Errorf("getting assets for album %s: %w", album.ID, err)
 		}
 
@@ -276,25 +288,15 @@ func (g *Generator) getAssetsFromSharedAlbums(albums []immich.Album) (map[string
 }
 
 // getAlbumAssets gets all assets in an album.
-func (g *Generator) getAlbumAssets(albumID string) (map[string]bool, error) {
-	req, err := g.client.NewRequest("GET", fmt.Sprintf("/api/albums/%s?withoutAssets=false", albumID), nil)
+func (g *Generator) getAlbumAssets(ctx context.Context, albumID string) (map[string]bool, error) {
+	assetIDs, err := g.client.Albums.AssetIDs(ctx, albumID)
 	if err != nil {
-		return nil, fmt.Errorf("creating request for album assets: %w", err)
-	}
-
-	var album struct {
-		Assets []struct {
-			ID string `json:"id"`
-		} `json:"assets"`
-	}
-
-	if err := g.client.Do(req, &album); err != nil {
 		return nil, fmt.Errorf("getting album with assets: %w", err)
 	}
 
 	assets := make(map[string]bool)
-	for _, asset := range album.Assets {
-		assets[asset.ID] = true
+	for _, assetID := range assetIDs {
+		assets[assetID] = true
 	}
 
 	return assets, nil
@@ -2,6 +2,7 @@ package smart
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -35,9 +36,7 @@ func TestGenerator_Generate(t *testing.T) {
 					Name: "Vacation Photos",
 					AlbumUsers: []immich.AlbumUser{
 						{
-							User: struct {
-								ID string `json:"id"`
-							}{ID: "user123"},
+							User: immich.User{ID: "user123"},
 							Role: "viewer",
 						},
 					},
@@ -47,9 +46,7 @@ func TestGenerator_Generate(t *testing.T) {
 					Name: "Work Photos",
 					AlbumUsers: []immich.AlbumUser{
 						{
-							User: struct {
-								ID string `json:"id"`
-							}{ID: "user123"},
+							User: immich.User{ID: "user123"},
 							Role: "viewer",
 						},
 					},
@@ -59,9 +56,7 @@ func TestGenerator_Generate(t *testing.T) {
 					Name: "Family Photos",
 					AlbumUsers: []immich.AlbumUser{
 						{
-							User: struct {
-								ID string `json:"id"`
-							}{ID: "user456"},
+							User: immich.User{ID: "user456"},
 							Role: "viewer",
 						},
 					},
@@ -71,9 +66,7 @@ func TestGenerator_Generate(t *testing.T) {
 					Name: "All Test User",
 					AlbumUsers: []immich.AlbumUser{
 						{
-							User: struct {
-								ID string `json:"id"`
-							}{ID: "user123"},
+							User: immich.User{ID: "user123"},
 							Role: "owner",
 						},
 					},
@@ -85,18 +78,10 @@ func TestGenerator_Generate(t *testing.T) {
 
 		case "/api/albums/album1":
 			// Return album1 with assets
-			response := struct {
-				ID     string `json:"id"`
-				Name   string `json:"albumName"`
-				Assets []struct {
-					ID string `json:"id"`
-				} `json:"assets"`
-			}{
+			response := immich.Album{
 				ID:   "album1",
 				Name: "Vacation Photos",
-				Assets: []struct {
-					ID string `json:"id"`
-				}{
+				Assets: []immich.Asset{
 					{ID: "asset1"},
 					{ID: "asset2"},
 					{ID: "asset3"},
@@ -108,18 +93,10 @@ func TestGenerator_Generate(t *testing.T) {
 
 		case "/api/albums/album2":
 			// Return album2 with assets
-			response := struct {
-				ID     string `json:"id"`
-				Name   string `json:"albumName"`
-				Assets []struct {
-					ID string `json:"id"`
-				} `json:"assets"`
-			}{
+			response := immich.Album{
 				ID:   "album2",
 				Name: "Work Photos",
-				Assets: []struct {
-					ID string `json:"id"`
-				}{
+				Assets: []immich.Asset{
 					{ID: "asset3"},
 					{ID: "asset4"},
 					{ID: "asset5"},
@@ -131,18 +108,10 @@ func TestGenerator_Generate(t *testing.T) {
 
 		case "/api/albums/album3":
 			// Return album3 with assets (not shared with our test user)
-			response := struct {
-				ID     string `json:"id"`
-				Name   string `json:"albumName"`
-				Assets []struct {
-					ID string `json:"id"`
-				} `json:"assets"`
-			}{
+			response := immich.Album{
 				ID:   "album3",
 				Name: "Family Photos",
-				Assets: []struct {
-					ID string `json:"id"`
-				}{
+				Assets: []immich.Asset{
 					{ID: "asset6"},
 					{ID: "asset7"},
 				},
@@ -153,18 +122,10 @@ func TestGenerator_Generate(t *testing.T) {
 
 		case "/api/albums/smartalbum":
 			// Return smart album with assets
-			response := struct {
-				ID     string `json:"id"`
-				Name   string `json:"albumName"`
-				Assets []struct {
-					ID string `json:"id"`
-				} `json:"assets"`
-			}{
+			response := immich.Album{
 				ID:   "smartalbum",
 				Name: "All Test User",
-				Assets: []struct {
-					ID string `json:"id"`
-				}{
+				Assets: []immich.Asset{
 					{ID: "asset1"},
 					{ID: "asset2"},
 					{ID: "asset3"},
@@ -313,7 +274,7 @@ func verifySmartAlbumPlan(t *testing.T, p *plan.Plan) {
 	}
 }
 
-func TestGenerator_ErrorWhenAlbumDoesNotExist(t *testing.T) {
+func TestGenerator_CreatesMissingAlbum(t *testing.T) {
 	t.Parallel()
 	// Set up test server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -335,21 +296,7 @@ func TestGenerator_ErrorWhenAlbumDoesNotExist(t *testing.T) {
 					Name: "Vacation Photos",
 					AlbumUsers: []immich.AlbumUser{
 						{
-							User: struct {
-								ID string `json:"id"`
-							}{ID: "user123"},
-							Role: "viewer",
-						},
-					},
-				},
-				{
-					ID:   "album2",
-					Name: "Work Photos",
-					AlbumUsers: []immich.AlbumUser{
-						{
-							User: struct {
-								ID string `json:"id"`
-							}{ID: "user123"},
+							User: immich.User{ID: "user123"},
 							Role: "viewer",
 						},
 					},
@@ -359,6 +306,19 @@ func TestGenerator_ErrorWhenAlbumDoesNotExist(t *testing.T) {
 
 			return
 
+		case "/api/albums/album1":
+			response := immich.Album{
+				ID:   "album1",
+				Name: "Vacation Photos",
+				Assets: []immich.Asset{
+					{ID: "6b4e0f52-7e0c-4cf0-9d3e-3f1c7a2b8e01"},
+					{ID: "6b4e0f52-7e0c-4cf0-9d3e-3f1c7a2b8e02"},
+				},
+			}
+			_ = json.NewEncoder(w).Encode(response)
+
+			return
+
 		default:
 			w.WriteHeader(http.StatusNotFound)
 
@@ -371,15 +331,128 @@ func TestGenerator_ErrorWhenAlbumDoesNotExist(t *testing.T) {
 	client := immich.NewClient(server.URL, "test-token")
 	generator := NewGenerator(client, "test@example.com")
 
-	// Generate plan - should error because the smart album doesn't exist
-	_, err := generator.Generate()
-	if err == nil {
-		t.Fatalf("Expected error when smart album doesn't exist, got nil")
+	p, err := generator.Generate()
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	if len(p.Operations) != 2 {
+		t.Fatalf("Expected an operation creating the album and one adding assets, got %d", len(p.Operations))
+	}
+
+	create, add := p.Operations[0], p.Operations[1]
+	albumID := plan.Placeholder("create_all_test_user", "id")
+
+	if create.ID != "create_all_test_user" || create.Apply[0].Method != http.MethodPost ||
+		!strings.Contains(string(create.Apply[0].Body), `"albumName":"All Test User"`) {
+		t.Errorf("First operation does not create the smart album: %+v", create)
+	}
+
+	if create.Revert[0].Method != http.MethodDelete || create.Revert[0].Path != "/api/albums/"+albumID {
+		t.Errorf("Creating the album is not reverted by deleting it: %+v", create.Revert)
+	}
+
+	if !reflect.DeepEqual(add.Dependencies(), []string{"create_all_test_user"}) || len(add.Preconditions) != 0 {
+		t.Errorf("Adding assets does not depend on creating the album: %+v", add)
+	}
+
+	if add.Apply[0].Path != "/api/albums/"+albumID+"/assets" {
+		t.Errorf("Assets are added to %s, want the created album", add.Apply[0].Path)
+	}
+
+	if p.Names[albumID] != "All Test User" {
+		t.Errorf("Names[%s] = %q, want the name of the new album", albumID, p.Names[albumID])
 	}
 
-	// Check error message
-	expectedErrMsg := "smart album 'All Test User' does not exist - please create it first"
-	if !strings.Contains(err.Error(), expectedErrMsg) {
-		t.Errorf("Expected error message to contain '%s', got '%s'", expectedErrMsg, err.Error())
+	if issues := plan.Validate(p, immich.ParseRequest); plan.HasErrors(issues) {
+		t.Errorf("Validate() = %v", issues)
+	}
+
+	// The plan that undoes it removes the assets before deleting the album
+	// it created, whose ID was captured when the plan was applied
+	if _, err := plan.Undo(p, nil, immich.ParseRequest); !errors.Is(err, plan.ErrNotCaptured) {
+		t.Errorf("Undo() without captured values error = %v, want ErrNotCaptured", err)
+	}
+
+	createdID := "0b6e1a52-1f9c-4c53-9f0a-6f1f7f0d2c11"
+
+	undo, err := plan.Undo(p, plan.Captures{create.ID: {"id": createdID}}, immich.ParseRequest)
+	if err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+
+	if issues := plan.Validate(undo, immich.ParseRequest); plan.HasErrors(issues) {
+		t.Errorf("Validate() of the undo plan = %v", issues)
+	}
+
+	remove, deleteAlbum := undo.Operations[0], undo.Operations[1]
+	if remove.Apply[0].Path != "/api/albums/"+createdID+"/assets" || remove.ID == "" {
+		t.Errorf("First undo operation does not remove the assets from the created album: %+v", remove)
+	}
+
+	if deleteAlbum.Apply[0].Method != http.MethodDelete || deleteAlbum.Apply[0].Path != "/api/albums/"+createdID ||
+		!reflect.DeepEqual(deleteAlbum.DependsOn, []string{remove.ID}) {
+		t.Errorf("Second undo operation does not delete the album after the assets are removed: %+v", deleteAlbum)
+	}
+}
+
+func TestCreateAlbumID(t *testing.T) {
+	t.Parallel()
+
+	generator := NewGenerator(immich.NewClient("http://immich.local", "test-token"), "")
+	plans := make([]*plan.Plan, 0, 2)
+
+	for name, want := range map[string]string{
+		"All Jane Doe":    "create_all_jane_doe",
+		"All John O'Neil": "create_all_john_o_neil",
+	} {
+		create, err := generator.createAlbumOperation(name)
+		if err != nil {
+			t.Fatalf("createAlbumOperation() error = %v", err)
+		}
+
+		if create.ID != want {
+			t.Errorf("ID of the operation creating %q = %q, want %q", name, create.ID, want)
+		}
+
+		plans = append(plans, &plan.Plan{Operations: []plan.Operation{create}})
+	}
+
+	if _, _, err := plan.Merge(plans, immich.ParseRequest); err != nil {
+		t.Errorf("Merge() of plans creating different smart albums error = %v", err)
+	}
+}
+
+func TestGenerator_AlbumUpToDate(t *testing.T) {
+	t.Parallel()
+
+	assets := []immich.Asset{{ID: "6b4e0f52-7e0c-4cf0-9d3e-3f1c7a2b8e01"}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/users":
+			_ = json.NewEncoder(w).Encode([]immich.User{{ID: "user123", Email: "test@example.com", Name: "Test User"}})
+		case "/api/albums":
+			_ = json.NewEncoder(w).Encode([]immich.Album{
+				{ID: "smart1", Name: "All Test User"},
+				{
+					ID:         "album1",
+					Name:       "Vacation Photos",
+					AlbumUsers: []immich.AlbumUser{{User: immich.User{ID: "user123"}, Role: "viewer"}},
+				},
+			})
+		case "/api/albums/smart1", "/api/albums/album1":
+			_ = json.NewEncoder(w).Encode(immich.Album{Assets: assets})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := immich.NewClient(server.URL, "test-token")
+
+	_, err := NewGenerator(client, "test@example.com").Generate()
+	if !errors.Is(err, plan.ErrNoChanges) {
+		t.Errorf("Generate() error = %v, want %v", err, plan.ErrNoChanges)
 	}
 }
@@ -0,0 +1,186 @@
+package immich
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"immich-manager/pkg/plan"
+)
+
+func TestAlbumsService_List(t *testing.T) {
+	t.Parallel()
+
+	shared := true
+
+	testCases := []struct {
+		name      string
+		opts      *AlbumListOptions
+		wantQuery string
+	}{
+		{name: "no options", opts: nil, wantQuery: ""},
+		{name: "shared", opts: &AlbumListOptions{Shared: &shared}, wantQuery: "shared=true"},
+		{name: "by asset", opts: &AlbumListOptions{AssetID: "asset-1"}, wantQuery: "assetId=asset-1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/albums" || r.URL.RawQuery != tc.wantQuery {
+					t.Errorf("Unexpected request %s?%s", r.URL.Path, r.URL.RawQuery)
+				}
+
+				_ = json.NewEncoder(w).Encode([]Album{{ID: "album-1", Name: "Italy 2023"}})
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL, "test-token")
+
+			albums, err := client.Albums.List(context.Background(), tc.opts)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+
+			if len(albums) != 1 || albums[0].Name != "Italy 2023" {
+				t.Errorf("Unexpected albums %+v", albums)
+			}
+		})
+	}
+}
+
+func TestAlbumsService_AssetIDs(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/albums/album-1" || r.URL.Query().Get("withoutAssets") != "false" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"id":"album-1","assets":[{"id":"a"},{"id":"b"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+
+	ids, err := client.Albums.AssetIDs(context.Background(), "album-1")
+	if err != nil {
+		t.Fatalf("AssetIDs() error = %v", err)
+	}
+
+	if len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Errorf("AssetIDs() = %v, want [a b]", ids)
+	}
+
+	_, err = client.Albums.AssetIDs(context.Background(), "missing")
+	if !IsNotFound(err) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
+func TestAlbumsService_MutationRequests(t *testing.T) {
+	t.Parallel()
+
+	albums := NewClient("http://immich.test", "test-token").Albums
+
+	testCases := []struct {
+		name       string
+		build      func() (plan.Request, error)
+		wantMethod string
+		wantPath   string
+		wantBody   string
+	}{
+		{
+			name: "update",
+			build: func() (plan.Request, error) {
+				return albums.UpdateRequest("a1", AlbumUpdate{Name: "New name"})
+			},
+			wantMethod: http.MethodPatch,
+			wantPath:   "/api/albums/a1",
+			wantBody:   `{"albumName":"New name"}`,
+		},
+		{
+			name: "add users",
+			build: func() (plan.Request, error) {
+				return albums.AddUsersRequest("a1", AlbumUserAddition{Role: RoleEditor, UserID: "u1"})
+			},
+			wantMethod: http.MethodPut,
+			wantPath:   "/api/albums/a1/users",
+			wantBody:   `{"albumUsers":[{"role":"editor","userId":"u1"}]}`,
+		},
+		{
+			name: "remove user",
+			build: func() (plan.Request, error) {
+				return albums.RemoveUserRequest("a1", "u1")
+			},
+			wantMethod: http.MethodDelete,
+			wantPath:   "/api/albums/a1/user/u1",
+		},
+		{
+			name: "add assets",
+			build: func() (plan.Request, error) {
+				return albums.AddAssetsRequest("a1", []string{"x", "y"})
+			},
+			wantMethod: http.MethodPut,
+			wantPath:   "/api/albums/a1/assets",
+			wantBody:   `{"ids":["x","y"]}`,
+		},
+		{
+			name: "remove assets",
+			build: func() (plan.Request, error) {
+				return albums.RemoveAssetsRequest("a1", []string{"x"})
+			},
+			wantMethod: http.MethodDelete,
+			wantPath:   "/api/albums/a1/assets",
+			wantBody:   `{"ids":["x"]}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := tc.build()
+			if err != nil {
+				t.Fatalf("Building request failed: %v", err)
+			}
+
+			if req.Method != tc.wantMethod || req.Path != tc.wantPath {
+				t.Errorf("Got %s %s, want %s %s", req.Method, req.Path, tc.wantMethod, tc.wantPath)
+			}
+
+			if string(req.Body) != tc.wantBody {
+				t.Errorf("Body = %s, want %s", req.Body, tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestAlbumsService_AddUsersSendsRequest(t *testing.T) {
+	t.Parallel()
+
+	var received string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Method + " " + r.URL.Path
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+
+	err := client.Albums.AddUsers(context.Background(), "a1", AlbumUserAddition{Role: RoleViewer, UserID: "u1"})
+	if err != nil {
+		t.Fatalf("AddUsers() error = %v", err)
+	}
+
+	if received != "PUT /api/albums/a1/users" {
+		t.Errorf("Unexpected request %s", received)
+	}
+}
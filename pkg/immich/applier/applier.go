@@ -2,12 +2,16 @@
 package applier
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"time"
 
 	"immich-manager/pkg/immich"
+	"immich-manager/pkg/immich/describe"
 	"immich-manager/pkg/plan"
 )
 
@@ -19,7 +23,55 @@ type Applier struct {
 // ApplyOptions contains options for the Apply operation.
 type ApplyOptions struct {
 	DryRun bool
-	Writer io.Writer // Used for dry run output
+	Writer io.Writer // Used for dry run output and drift warnings
+	// Journal, when set, records each completed operation. Apply skips
+	// operations the journal already marks as applied and Revert only
+	// reverts those operations.
+	Journal *plan.Journal
+	// All executes every selected operation whatever the journal says,
+	// for example to revert operations it does not record as applied. The
+	// journal still provides captured values and recorded reverts, and
+	// records each operation executed.
+	All bool
+	// Atomic rolls back every operation executed during this run, in
+	// reverse order, when an operation fails. Requests already sent by an
+	// operation that fails part way are reverted first, which requires its
+	// revert requests to undo its apply requests one by one in reverse
+	// order; otherwise the rollback is incomplete.
+	Atomic bool
+	// OnDrift decides what happens to operations whose preconditions no
+	// longer hold. The zero value behaves like DriftFail.
+	OnDrift DriftPolicy
+	// Selected, when set, limits execution to the operations it returns
+	// true for, identified by their index in the plan.
+	Selected func(i int) bool
+	// ContinueOnError keeps executing the remaining operations after an
+	// operation fails, and returns an *OperationsError listing every
+	// failure at the end. It has no effect on atomic runs.
+	ContinueOnError bool
+	// Parallelism is the number of operations executed concurrently.
+	// Values below 2 execute operations one at a time.
+	Parallelism int
+	// Names resolves IDs in dry run output. When nil only the names
+	// embedded in the plan are used and the server is not contacted.
+	Names *describe.Names
+	// Observer, when set, receives progress events. Dry runs emit none.
+	Observer Observer
+	// RecordState reads the state each operation changes right before it
+	// is applied and journals the intents that restore exactly that
+	// state. Reverts and rollbacks use them instead of the revert requests
+	// of the plan. Operations whose requests are not understood keep the
+	// revert of the plan.
+	RecordState bool
+
+	// events forwards the events of the current run to Observer.
+	events *tracker
+	// captures holds the values captured from responses during the
+	// current run.
+	captures *captures
+	// version is the version of the server, when it was needed to compile
+	// intents.
+	version *immich.ServerVersion
 }
 
 // DefaultApplyOptions returns the default options for Apply.
@@ -30,6 +82,34 @@ func DefaultApplyOptions() *ApplyOptions {
 	}
 }
 
+// direction describes whether a plan is being applied or reverted. Reverts
+// execute the inverted plan, so operation indices are mapped back to the
+// original plan that the journal and error messages refer to.
+type direction struct {
+	action plan.Action
+	// total is the number of operations in the plan.
+	total int
+}
+
+// index maps the position of an operation in the executed plan to its
+// index in the original plan.
+func (d direction) index(i int) int {
+	if d.action == plan.ActionRevert {
+		return d.total - 1 - i
+	}
+
+	return i
+}
+
+// opposite returns the action that undoes the direction's action.
+func (d direction) opposite() plan.Action {
+	if d.action == plan.ActionRevert {
+		return plan.ActionApply
+	}
+
+	return plan.ActionRevert
+}
+
 // NewApplier creates a new plan applier.
 func NewApplier(client *immich.Client) *Applier {
 	return &Applier{
@@ -39,143 +119,513 @@ func NewApplier(client *immich.Client) *Applier {
 
 // Apply executes all operations in the plan.
 func (a *Applier) Apply(p *plan.Plan, opts *ApplyOptions) error {
+	return a.ApplyContext(context.Background(), p, opts)
+}
+
+// ApplyContext is like Apply but stops when ctx is cancelled. The
+// operation in flight is allowed to finish, though not to retry, after
+// which an *InterruptedError describing what was done is returned.
+func (a *Applier) ApplyContext(ctx context.Context, p *plan.Plan, opts *ApplyOptions) error {
+	return a.execute(ctx, p, opts, direction{action: plan.ActionApply, total: len(p.Operations)})
+}
+
+// Revert executes all revert operations in the plan in reverse order.
+func (a *Applier) Revert(p *plan.Plan, opts *ApplyOptions) error {
+	return a.RevertContext(context.Background(), p, opts)
+}
+
+// RevertContext is like Revert but stops when ctx is cancelled, finishing
+// the operation in flight first as ApplyContext does. Reverting applies the
+// inverted plan, so drift checks, atomic rollback and journaling behave as
+// they do for Apply.
+func (a *Applier) RevertContext(ctx context.Context, p *plan.Plan, opts *ApplyOptions) error {
+	inverted, err := plan.Invert(p, immich.ParseRequest)
+	if err != nil {
+		return fmt.Errorf("inverting plan: %w", err)
+	}
+
+	return a.execute(ctx, inverted, opts, direction{action: plan.ActionRevert, total: len(p.Operations)})
+}
+
+// execute runs the apply requests of every pending operation of p in order.
+func (a *Applier) execute(ctx context.Context, p *plan.Plan, opts *ApplyOptions, dir direction) error {
 	if opts == nil {
 		opts = DefaultApplyOptions()
 	}
 
+	if dir.action == plan.ActionRevert {
+		p = exactReverts(p, opts.Journal, dir)
+	}
+
+	p, version, err := a.compile(ctx, p, opts)
+	if err != nil {
+		return err
+	}
+
 	if opts.DryRun {
-		return a.dryRunApply(p, opts.Writer)
+		return a.dryRun(ctx, p, opts, dir)
+	}
+
+	observed := *opts
+	observed.events = newTracker(opts.Observer, dir.action, opts.countPending(0, len(p.Operations), dir))
+	observed.captures = newCaptures(p, opts.Journal, opts.All, dir)
+	observed.version = version
+	opts = &observed
+
+	opts.events.started()
+
+	if opts.Parallelism > 1 {
+		err = a.executeParallel(ctx, p, opts, dir)
+	} else {
+		err = a.executeSequential(ctx, p, opts, dir)
+	}
+
+	opts.events.finished(err)
+
+	return err
+}
+
+// compile replaces the intents of p with requests for the server's version
+// and returns the version, which recorded reverts are also compiled for.
+// Dry runs do not contact the server and show the requests of the current
+// API.
+func (a *Applier) compile(
+	ctx context.Context, p *plan.Plan, opts *ApplyOptions,
+) (*plan.Plan, *immich.ServerVersion, error) {
+	semantic := false
+	for _, op := range p.Operations {
+		semantic = semantic || len(op.Intents) > 0
+	}
+
+	if !semantic && !opts.RecordState {
+		return p, nil, nil
+	}
+
+	var version *immich.ServerVersion
+
+	if !opts.DryRun {
+		var err error
+		if version, err = a.client.Server.Version(ctx); err != nil {
+			return nil, nil, fmt.Errorf("compiling intents: %w", err)
+		}
+	}
+
+	if !semantic {
+		return p, version, nil
+	}
+
+	compiled, err := immich.Compile(p, version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("compiling intents: %w", err)
 	}
 
+	return compiled, version, nil
+}
+
+// executeSequential runs the pending operations of p one at a time.
+func (a *Applier) executeSequential(ctx context.Context, p *plan.Plan, opts *ApplyOptions, dir direction) error {
+	// Operations executed during this run, used for atomic rollback
+	executed := make([]int, 0, len(p.Operations))
+
+	// Operations that failed when continuing on error
+	var failures []*OperationError
+
+	// In-flight operations run to completion even once ctx is cancelled,
+	// without waiting to retry their requests
+	opCtx := context.WithoutCancel(ctx)
+	runCtx := immich.WithRetryStop(opCtx, ctx)
+
 	for i, op := range p.Operations {
-		for j, req := range op.Apply {
-			request, err := a.client.NewRequest(req.Method, req.Path, req.Body)
-			if err != nil {
-				return fmt.Errorf("creating request %d for operation %d: %w", j, i, err)
+		if !opts.pending(dir.index(i), dir.action) {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			interrupted := &InterruptedError{
+				Err:       err,
+				Action:    dir.action,
+				Completed: originalIndices(executed, dir),
+				Remaining: opts.countPending(i, len(p.Operations), dir),
+			}
+
+			if opts.Atomic {
+				return a.rollback(opCtx, p, executed, interrupted, opts, dir)
 			}
 
-			if err := a.client.Do(request, nil); err != nil {
-				return fmt.Errorf("executing request %d for operation %d: %w", j, i, err)
+			if len(failures) > 0 {
+				return errors.Join(interrupted, failed(failures, executed, opts.captures.snapshot(), dir))
 			}
+
+			return interrupted
+		}
+
+		ok, err := a.runOperation(runCtx, i, op, opts, dir)
+		if err != nil {
+			if opts.Atomic {
+				return a.rollback(opCtx, p, executed, err, opts, dir)
+			}
+
+			if opts.ContinueOnError {
+				failures = append(failures, &OperationError{Operation: dir.index(i), Err: err})
+
+				continue
+			}
+
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		executed = append(executed, i)
+
+		if err := opts.record(dir.index(i), dir.action); err != nil {
+			return fmt.Errorf("recording operation %d: %w", dir.index(i), err)
 		}
 	}
 
+	if len(failures) > 0 {
+		return failed(failures, executed, opts.captures.snapshot(), dir)
+	}
+
 	return nil
 }
 
-// Revert executes all revert operations in the plan in reverse order.
-func (a *Applier) Revert(p *plan.Plan, opts *ApplyOptions) error {
-	if opts == nil {
-		opts = DefaultApplyOptions()
+// runOperation applies operation i unless the drift policy says to skip
+// it, reports its outcome to the observer and whether it was applied.
+func (a *Applier) runOperation(
+	ctx context.Context, i int, op plan.Operation, opts *ApplyOptions, dir direction,
+) (bool, error) {
+	start := time.Now()
+
+	opts.events.emit(Event{Type: EventOperationStarted, Operation: dir.index(i)})
+
+	applied, err := a.applyIfCurrent(ctx, i, op, opts, dir)
+
+	var (
+		captured map[string]string
+		revert   []plan.Intent
+	)
+
+	if applied && dir.action == plan.ActionApply {
+		captured = opts.captures.captured(op.ID)
+		revert, _ = opts.captures.exactRevert(dir.index(i))
 	}
 
-	if opts.DryRun {
-		return a.dryRunRevert(p, opts.Writer)
+	opts.events.operation(dir.index(i), start, applied, captured, revert, err)
+
+	return applied, err
+}
+
+// applyIfCurrent applies operation i unless the operations it depends on
+// are not ready or the drift policy says to skip it, and reports whether
+// it was applied.
+func (a *Applier) applyIfCurrent(
+	ctx context.Context, i int, op plan.Operation, opts *ApplyOptions, dir direction,
+) (bool, error) {
+	if err := opts.captures.ready(dir.index(i), op); err != nil {
+		return false, err
 	}
 
-	// Execute operations in reverse order
-	for i := len(p.Operations) - 1; i >= 0; i-- {
-		op := p.Operations[i]
+	apply, err := a.handleDrift(ctx, dir.index(i), op, opts)
+	if err != nil || !apply {
+		return false, err
+	}
 
-		// Execute each revert request in order
-		for j, req := range op.Revert {
-			request, err := a.client.NewRequest(req.Method, req.Path, req.Body)
-			if err != nil {
-				return fmt.Errorf("creating revert request %d for operation %d: %w", j, i, err)
-			}
+	if err := a.applyOperation(ctx, i, op, opts, dir); err != nil {
+		return false, err
+	}
 
-			if err := a.client.Do(request, nil); err != nil {
-				return fmt.Errorf("executing revert request %d for operation %d: %w", j, i, err)
-			}
+	return true, nil
+}
+
+// applyOperation executes the apply requests of operation i in order. When
+// applying, the state the operation changes may be recorded first, and the
+// values other operations reference are captured from the response to the
+// last request. Failing to capture them does not undo the operation, it
+// only fails the operations that need them. Operations that fail after
+// some of their requests were sent are remembered for atomic rollback.
+func (a *Applier) applyOperation(
+	ctx context.Context, i int, op plan.Operation, opts *ApplyOptions, dir direction,
+) error {
+	reverting := dir.action == plan.ActionRevert
+
+	if !reverting && opts.RecordState {
+		a.recordState(ctx, i, op, opts, dir)
+	}
+
+	var response *json.RawMessage
+	if !reverting && opts.captures.referenced(op.ID) {
+		response = new(json.RawMessage)
+	}
+
+	sent, err := a.send(ctx, dir.index(i), op.Apply, reverting, response, opts)
+	if err != nil {
+		if sent > 0 {
+			opts.captures.sentPartly(dir.index(i), sent)
 		}
+
+		return err
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	if err := opts.captures.capture(op.ID, *response); err != nil {
+		opts.warnf("Warning: operation %d: %v\n", dir.index(i), err)
 	}
 
 	return nil
 }
 
-// dryRunApply simulates applying the plan without making actual API calls.
-func (*Applier) dryRunApply(p *plan.Plan, w io.Writer) error {
-	if w == nil {
-		return errors.New("writer is required for dry run")
+// revertOperation executes the revert requests of operation i in order,
+// or the recorded revert restoring the state found before it was applied.
+func (a *Applier) revertOperation(
+	ctx context.Context, i int, op plan.Operation, opts *ApplyOptions, dir direction,
+) error {
+	reverting := dir.action != plan.ActionRevert
+
+	reqs := op.Revert
+	if reverting {
+		if revert, ok := opts.captures.exactRevert(dir.index(i)); ok {
+			var err error
+			if reqs, err = immich.CompileIntents(revert, opts.version); err != nil {
+				return fmt.Errorf("compiling recorded revert of operation %d: %w", dir.index(i), err)
+			}
+		}
 	}
 
-	// Count total requests
-	totalRequests := 0
-	for _, op := range p.Operations {
-		totalRequests += len(op.Apply)
+	_, err := a.send(ctx, dir.index(i), reqs, reverting, nil, opts)
+
+	return err
+}
+
+// revertPartly executes the revert requests undoing the first sent apply
+// requests of operation i, which failed after sending them.
+func (a *Applier) revertPartly(
+	ctx context.Context, i int, op plan.Operation, sent int, opts *ApplyOptions, dir direction,
+) error {
+	reqs, ok := op.PartialRevert(sent, immich.ParseRequest)
+	if !ok {
+		return fmt.Errorf("operation %d failed after %d of its %d requests were sent, "+
+			"and its revert requests do not undo them one by one", dir.index(i), sent, len(op.Apply))
 	}
 
-	if _, err := fmt.Fprintf(w, "Dry run mode: would execute %d operations with %d total requests\n",
-		len(p.Operations), totalRequests); err != nil {
-		return fmt.Errorf("writing dry run summary: %w", err)
+	_, err := a.send(ctx, dir.index(i), reqs, dir.action != plan.ActionRevert, nil, opts)
+
+	return err
+}
+
+// send executes the requests of operation i in order, after replacing
+// their placeholders with captured values, and returns the number of
+// requests that succeeded. When reverting, deleting something that no
+// longer exists already has the desired effect and is treated as
+// successful. Each request is reported to the observer. When response is
+// set, it receives the response to the last request.
+func (a *Applier) send(
+	ctx context.Context, i int, reqs []plan.Request, reverting bool, response *json.RawMessage, opts *ApplyOptions,
+) (int, error) {
+	kind := "request"
+	if reverting {
+		kind = "revert request"
 	}
 
-	for i, op := range p.Operations {
-		if _, err := fmt.Fprintf(w, "Operation %d: %d requests\n", i+1, len(op.Apply)); err != nil {
-			return fmt.Errorf("writing operation summary: %w", err)
+	for j, req := range reqs {
+		req, err := opts.captures.resolve(req)
+		if err != nil {
+			return j, fmt.Errorf("%s %d for operation %d: %w", kind, j, i, err)
 		}
 
-		for j, req := range op.Apply {
-			if _, err := fmt.Fprintf(w, "  Request %d.%d: %s %s\n", i+1, j+1, req.Method, req.Path); err != nil {
-				return fmt.Errorf("writing request summary: %w", err)
-			}
+		request, err := a.client.NewRequestWithContext(ctx, req.Method, req.Path, req.Body)
+		if err != nil {
+			return j, fmt.Errorf("creating %s %d for operation %d: %w", kind, j, i, err)
+		}
 
-			if req.Body != nil {
-				bodyJSON, err := json.MarshalIndent(req.Body, "    ", "  ")
-				if err != nil {
-					return fmt.Errorf("marshaling body for operation %d request %d: %w", i, j, err)
-				}
+		var v any
+		if response != nil && j == len(reqs)-1 {
+			v = response
+		}
 
-				if _, err := fmt.Fprintf(w, "    Body: %s\n", bodyJSON); err != nil {
-					return fmt.Errorf("writing request body: %w", err)
-				}
-			}
+		start := time.Now()
+		status, err := a.client.DoStatus(request, v)
+
+		event := Event{
+			Type:      EventRequest,
+			Operation: i,
+			Request:   j,
+			Method:    req.Method,
+			Path:      req.Path,
+			Status:    status,
+			Duration:  time.Since(start),
+		}
+
+		// e.g. the album was removed since the plan was applied
+		tolerated := reverting && req.Method == http.MethodDelete && immich.IsNotFound(err)
+
+		if err != nil && !tolerated {
+			err = fmt.Errorf("executing %s %d for operation %d: %w", kind, j, i, explain(err))
+			event.Error = summarize(err)
+		} else {
+			err = nil
+		}
+
+		opts.events.emit(event)
+
+		if err != nil {
+			return j, err
+		}
+	}
+
+	return len(reqs), nil
+}
+
+// originalIndices maps positions in the executed plan to indices in the
+// original plan.
+func originalIndices(executed []int, dir direction) []int {
+	indices := make([]int, len(executed))
+	for k, i := range executed {
+		indices[k] = dir.index(i)
+	}
+
+	return indices
+}
+
+// explain adds guidance to API errors that are caused by configuration
+// rather than by the plan itself.
+func explain(err error) error {
+	switch {
+	case immich.IsUnauthorized(err):
+		return fmt.Errorf("authentication failed, check IMMICH_TOKEN: %w", err)
+	case immich.IsForbidden(err):
+		return fmt.Errorf("permission denied, the API key may lack the required permissions: %w", err)
+	default:
+		return err
+	}
+}
+
+// pending reports whether operation i is selected and still needs to be
+// executed in the given direction according to the journal. Without a
+// journal, or with All, every selected operation is pending.
+func (o *ApplyOptions) pending(i int, action plan.Action) bool {
+	if o.Selected != nil && !o.Selected(i) {
+		return false
+	}
+
+	if o.Journal == nil || o.All {
+		return true
+	}
+
+	applied := o.Journal.Applied(i)
+	if action == plan.ActionApply {
+		return !applied
+	}
+
+	return applied
+}
+
+// countPending returns how many operations of the executed plan, from
+// position start up to end, are pending.
+func (o *ApplyOptions) countPending(start, end int, dir direction) int {
+	count := 0
+
+	for i := start; i < end; i++ {
+		if o.pending(dir.index(i), dir.action) {
+			count++
 		}
 	}
 
+	return count
+}
+
+// record marks operation i as executed and writes its completion, with
+// the values captured from its response and its recorded revert, to the
+// journal, if any.
+func (o *ApplyOptions) record(i int, action plan.Action) error {
+	entry := o.captures.completed(i, action)
+
+	if o.Journal == nil {
+		return nil
+	}
+
+	if err := o.Journal.Record(entry); err != nil {
+		return fmt.Errorf("writing journal: %w", err)
+	}
+
 	return nil
 }
 
-// dryRunRevert simulates reverting the plan without making actual API calls.
-func (*Applier) dryRunRevert(p *plan.Plan, w io.Writer) error {
+// warnf writes a warning to the writer, if any.
+func (o *ApplyOptions) warnf(format string, args ...any) {
+	if o.Writer != nil {
+		_, _ = fmt.Fprintf(o.Writer, format, args...)
+	}
+}
+
+// names returns the resolver used to describe requests of p.
+func (o *ApplyOptions) names(p *plan.Plan) *describe.Names {
+	if o.Names != nil {
+		return o.Names
+	}
+
+	return describe.NewNames(nil, p.Names)
+}
+
+// dryRun describes the requests that executing the plan would send without
+// making any API calls.
+func (*Applier) dryRun(ctx context.Context, p *plan.Plan, opts *ApplyOptions, dir direction) error {
+	w := opts.Writer
 	if w == nil {
 		return errors.New("writer is required for dry run")
 	}
 
+	names := opts.names(p)
+
 	// Count total requests
+	totalOperations := 0
 	totalRequests := 0
-	for _, op := range p.Operations {
-		totalRequests += len(op.Revert)
+
+	for i, op := range p.Operations {
+		if opts.pending(dir.index(i), dir.action) {
+			totalOperations++
+			totalRequests += len(op.Apply)
+		}
 	}
 
-	if _, err := fmt.Fprintf(w, "Dry run mode: would revert %d operations with %d total requests\n",
-		len(p.Operations), totalRequests); err != nil {
-		return fmt.Errorf("writing dry run revert summary: %w", err)
+	verb := "execute"
+	if dir.action == plan.ActionRevert {
+		verb = "revert"
 	}
 
-	// Operations are processed in reverse order for revert
-	for i := len(p.Operations) - 1; i >= 0; i-- {
-		op := p.Operations[i]
-		opNumber := len(p.Operations) - i
+	if _, err := fmt.Fprintf(w, "Dry run mode: would %s %d operations with %d total requests\n",
+		verb, totalOperations, totalRequests); err != nil {
+		return fmt.Errorf("writing dry run summary: %w", err)
+	}
 
-		if _, err := fmt.Fprintf(w, "Operation %d: %d requests\n", opNumber, len(op.Revert)); err != nil {
-			return fmt.Errorf("writing revert operation summary: %w", err)
+	for i, op := range p.Operations {
+		if !opts.pending(dir.index(i), dir.action) {
+			continue
 		}
 
-		// Requests within an operation are processed in original order
-		for j, req := range op.Revert {
-			if _, err := fmt.Fprintf(w, "  Request %d.%d: %s %s\n", opNumber, j+1, req.Method, req.Path); err != nil {
-				return fmt.Errorf("writing revert request summary: %w", err)
+		if _, err := fmt.Fprintf(w, "Operation %d: %d requests\n", i+1, len(op.Apply)); err != nil {
+			return fmt.Errorf("writing operation summary: %w", err)
+		}
+
+		for j, req := range op.Apply {
+			if _, err := fmt.Fprintf(w, "  Request %d.%d: %s %s\n    %s\n", i+1, j+1, req.Method, req.Path,
+				describe.Request(ctx, names, req)); err != nil {
+				return fmt.Errorf("writing request summary: %w", err)
 			}
 
 			if req.Body != nil {
 				bodyJSON, err := json.MarshalIndent(req.Body, "    ", "  ")
 				if err != nil {
-					return fmt.Errorf("marshaling body for revert operation %d request %d: %w", i, j, err)
+					return fmt.Errorf("marshaling body for operation %d request %d: %w", dir.index(i), j, err)
 				}
 
 				if _, err := fmt.Fprintf(w, "    Body: %s\n", bodyJSON); err != nil {
-					return fmt.Errorf("writing revert request body: %w", err)
+					return fmt.Errorf("writing request body: %w", err)
 				}
 			}
 		}
@@ -6,6 +6,8 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -242,6 +244,7 @@ func TestDryRunApply(t *testing.T) {
 		"would execute 1 operations",
 		"Operation 1:",
 		"Request 1.1: PATCH /api/albums/1",
+		"Rename album '1' to 'new name 1'",
 		"albumName",
 		"new name 1",
 	}
@@ -574,3 +577,488 @@ func TestDeleteRequestWithNilBody(t *testing.T) {
 		t.Error("Request body should be empty for DELETE request with nil body")
 	}
 }
+
+func TestApplier_ResumeFromJournal(t *testing.T) {
+	t.Parallel()
+
+	var requests []string
+
+	failAlbum := "2"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		if r.URL.Path == "/api/albums/"+failAlbum {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p := &plan.Plan{Operations: make([]plan.Operation, 0, 3)}
+	for _, id := range []string{"1", "2", "3"} {
+		p.Operations = append(p.Operations, plan.Operation{
+			Apply:  []plan.Request{{Path: "/api/albums/" + id, Method: http.MethodPatch}},
+			Revert: []plan.Request{{Path: "/api/albums/" + id, Method: http.MethodDelete}},
+		})
+	}
+
+	journal, err := plan.OpenJournal(filepath.Join(t.TempDir(), "plan.json.journal"))
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+
+	defer func() { _ = journal.Close() }()
+
+	applier := NewApplier(immich.NewClient(server.URL, "test-token"))
+	opts := &ApplyOptions{Journal: journal}
+
+	// First run fails on the second operation
+	if err := applier.Apply(p, opts); err == nil {
+		t.Fatal("Expected Apply() to fail on operation 2")
+	}
+
+	if got := journal.AppliedOperations(); !reflect.DeepEqual(got, []int{0}) {
+		t.Fatalf("Expected only operation 0 to be journaled, got %v", got)
+	}
+
+	// Resuming only sends the remaining operations
+	failAlbum = ""
+	requests = nil
+
+	if err := applier.Apply(p, opts); err != nil {
+		t.Fatalf("Apply() resume error = %v", err)
+	}
+
+	wantApply := []string{"PATCH /api/albums/2", "PATCH /api/albums/3"}
+	if !reflect.DeepEqual(requests, wantApply) {
+		t.Errorf("Resumed requests = %v, want %v", requests, wantApply)
+	}
+
+	// Reverting with the journal touches only applied operations
+	if err := journal.Record(plan.JournalEntry{Operation: 1, Action: plan.ActionRevert}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	requests = nil
+
+	if err := applier.Revert(p, opts); err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+
+	wantRevert := []string{"DELETE /api/albums/3", "DELETE /api/albums/1"}
+	if !reflect.DeepEqual(requests, wantRevert) {
+		t.Errorf("Revert requests = %v, want %v", requests, wantRevert)
+	}
+
+	if got := journal.AppliedOperations(); len(got) != 0 {
+		t.Errorf("Expected no applied operations after revert, got %v", got)
+	}
+}
+
+func TestApplier_RevertDeleteNotFound(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/albums/gone/user/123":
+			// The album was deleted since the plan was applied
+			w.WriteHeader(http.StatusNotFound)
+		case "/api/albums/denied/user/123":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	newPlan := func(albumID string) *plan.Plan {
+		return &plan.Plan{
+			Operations: []plan.Operation{
+				{
+					Apply: []plan.Request{{
+						Path:   "/api/albums/" + albumID + "/users",
+						Method: http.MethodPut,
+						Body:   json.RawMessage(`{"albumUsers":[{"role":"viewer","userId":"123"}]}`),
+					}},
+					Revert: []plan.Request{{
+						Path:   "/api/albums/" + albumID + "/user/123",
+						Method: http.MethodDelete,
+					}},
+				},
+			},
+		}
+	}
+
+	applier := NewApplier(immich.NewClient(server.URL, "test-token"))
+
+	if err := applier.Revert(newPlan("gone"), nil); err != nil {
+		t.Errorf("Expected a 404 on a revert DELETE to be treated as done, got %v", err)
+	}
+
+	err := applier.Revert(newPlan("denied"), nil)
+	if !immich.IsForbidden(err) {
+		t.Fatalf("Expected a forbidden API error, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected error to explain the permission problem, got %v", err)
+	}
+}
+
+func TestApplier_Selected(t *testing.T) {
+	t.Parallel()
+
+	var requests []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p := &plan.Plan{Operations: make([]plan.Operation, 0, 3)}
+	for _, id := range []string{"1", "2", "3"} {
+		p.Operations = append(p.Operations, plan.Operation{
+			Apply:  []plan.Request{{Path: "/api/albums/" + id, Method: http.MethodPatch}},
+			Revert: []plan.Request{{Path: "/api/albums/" + id, Method: http.MethodDelete}},
+		})
+	}
+
+	journal, err := plan.OpenJournal(filepath.Join(t.TempDir(), "plan.json.journal"))
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+
+	defer func() { _ = journal.Close() }()
+
+	applier := NewApplier(immich.NewClient(server.URL, "test-token"))
+	opts := &ApplyOptions{
+		Journal:  journal,
+		Selected: func(i int) bool { return i != 1 },
+	}
+
+	if err := applier.Apply(p, opts); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	if got := journal.AppliedOperations(); !reflect.DeepEqual(got, []int{0, 2}) {
+		t.Errorf("Expected operations 0 and 2 to be journaled, got %v", got)
+	}
+
+	opts.Selected = func(i int) bool { return i == 2 }
+
+	if err := applier.Revert(p, opts); err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+
+	want := []string{"PATCH /api/albums/1", "PATCH /api/albums/3", "DELETE /api/albums/3"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("Requests = %v, want %v", requests, want)
+	}
+}
+
+func TestApplier_Intents(t *testing.T) {
+	t.Parallel()
+
+	var requests []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/server/version" {
+			_, _ = w.Write([]byte(`{"major":1,"minor":105,"patch":1}`))
+
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p := &plan.Plan{Operations: []plan.Operation{{
+		Intents: []plan.Intent{
+			{Kind: plan.IntentAlbumRename, AlbumID: "a1", Name: "Italy", From: "Rome"},
+			{Kind: plan.IntentAlbumAddUser, AlbumID: "a1", UserID: "u1", Role: "viewer"},
+		},
+	}}}
+
+	applier := NewApplier(immich.NewClient(server.URL, "test-token"))
+
+	if err := applier.Apply(p, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	if err := applier.Revert(p, nil); err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+
+	want := []string{
+		`PATCH /api/album/a1 {"albumName":"Italy"}`,
+		`PUT /api/album/a1/users {"albumUsers":[{"role":"viewer","userId":"u1"}]}`,
+		`DELETE /api/album/a1/user/u1 `,
+		`PATCH /api/album/a1 {"albumName":"Rome"}`,
+	}
+
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("Requests = %q, want %q", requests, want)
+	}
+}
+
+func TestApplier_Captures(t *testing.T) {
+	t.Parallel()
+
+	var requests []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, strings.TrimSpace(r.Method+" "+r.URL.Path+" "+string(body)))
+
+		if r.Method == http.MethodPost && r.URL.Path == "/api/albums" {
+			_, _ = w.Write([]byte(`{"id":"new-album","albumName":"All Jane"}`))
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	albumID := plan.Placeholder("create_album", "id")
+	p := &plan.Plan{Operations: []plan.Operation{
+		{
+			ID:     "create_album",
+			Apply:  []plan.Request{{Path: "/api/albums", Method: http.MethodPost, Body: json.RawMessage(`{"albumName":"All Jane"}`)}},
+			Revert: []plan.Request{{Path: "/api/albums/" + albumID, Method: http.MethodDelete}},
+		},
+		{
+			Apply:  []plan.Request{{Path: "/api/albums/" + albumID + "/assets", Method: http.MethodPut, Body: json.RawMessage(`{"ids":["asset-1"]}`)}},
+			Revert: []plan.Request{{Path: "/api/albums/" + albumID + "/assets", Method: http.MethodDelete, Body: json.RawMessage(`{"ids":["asset-1"]}`)}},
+		},
+		{
+			DependsOn: []string{"create_album"},
+			Apply:     []plan.Request{{Path: "/api/albums/" + albumID, Method: http.MethodPatch, Body: json.RawMessage(`{"description":"` + albumID + `"}`)}},
+			Revert:    []plan.Request{{Path: "/api/albums/" + albumID, Method: http.MethodPatch, Body: json.RawMessage(`{"description":""}`)}},
+		},
+	}}
+
+	journalPath := filepath.Join(t.TempDir(), "plan.json.journal")
+	applier := NewApplier(immich.NewClient(server.URL, "test-token"))
+
+	journal, err := plan.OpenJournal(journalPath)
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+
+	// Dependencies must be applied first
+	selected := func(i int) bool { return i == 1 }
+	if err := applier.Apply(p, &ApplyOptions{Journal: journal, Selected: selected}); err == nil ||
+		!strings.Contains(err.Error(), `depends on operation "create_album"`) {
+		t.Fatalf("Apply() of a dependent operation alone error = %v", err)
+	}
+
+	if err := applier.Apply(p, &ApplyOptions{Journal: journal, Parallelism: 3}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	wantApply := []string{
+		`POST /api/albums {"albumName":"All Jane"}`,
+		`PUT /api/albums/new-album/assets {"ids":["asset-1"]}`,
+		`PATCH /api/albums/new-album {"description":"new-album"}`,
+	}
+
+	// Operations on the new album still run in plan order
+	if !reflect.DeepEqual(requests, wantApply) {
+		t.Errorf("Apply requests = %q, want %q", requests, wantApply)
+	}
+
+	// Reverting in a later run uses the values recorded in the journal
+	journal, err = plan.OpenJournal(journalPath)
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+
+	defer func() { _ = journal.Close() }()
+
+	if got := journal.Captured(0); !reflect.DeepEqual(got, map[string]string{"id": "new-album"}) {
+		t.Errorf("Captured(0) = %v, want the ID of the created album", got)
+	}
+
+	requests = nil
+
+	if err := applier.Revert(p, &ApplyOptions{Journal: journal}); err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+
+	wantRevert := []string{
+		`PATCH /api/albums/new-album {"description":""}`,
+		`DELETE /api/albums/new-album/assets {"ids":["asset-1"]}`,
+		`DELETE /api/albums/new-album`,
+	}
+
+	if !reflect.DeepEqual(requests, wantRevert) {
+		t.Errorf("Revert requests = %q, want %q", requests, wantRevert)
+	}
+}
+
+func TestApplier_RevertAll(t *testing.T) {
+	t.Parallel()
+
+	var (
+		requests   []string
+		failDelete = true
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		switch {
+		case r.Method == http.MethodPost:
+			_, _ = w.Write([]byte(`{"id":"new-album"}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/api/albums/new-album" && failDelete:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	albumID := plan.Placeholder("create_album", "id")
+	p := &plan.Plan{Operations: []plan.Operation{
+		{
+			ID:     "create_album",
+			Apply:  []plan.Request{{Path: "/api/albums", Method: http.MethodPost, Body: json.RawMessage(`{"albumName":"All Jane"}`)}},
+			Revert: []plan.Request{{Path: "/api/albums/" + albumID, Method: http.MethodDelete}},
+		},
+		{
+			Apply:  []plan.Request{{Path: "/api/albums/" + albumID + "/assets", Method: http.MethodPut, Body: json.RawMessage(`{"ids":["asset-1"]}`)}},
+			Revert: []plan.Request{{Path: "/api/albums/" + albumID + "/assets", Method: http.MethodDelete, Body: json.RawMessage(`{"ids":["asset-1"]}`)}},
+		},
+	}}
+
+	journal, err := plan.OpenJournal(filepath.Join(t.TempDir(), "plan.json.journal"))
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+
+	defer func() { _ = journal.Close() }()
+
+	applier := NewApplier(immich.NewClient(server.URL, "test-token"))
+	if err := applier.Apply(p, &ApplyOptions{Journal: journal}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	// Operations are recorded as they are reverted, even when a later one fails
+	if err := applier.Revert(p, &ApplyOptions{Journal: journal, All: true}); err == nil {
+		t.Fatal("Expected Revert() to fail")
+	}
+
+	if got := journal.AppliedOperations(); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("Applied operations after a failed revert = %v, want [0]", got)
+	}
+
+	failDelete = false
+	requests = nil
+
+	// Every operation is reverted again, with the values the journal recorded
+	if err := applier.Revert(p, &ApplyOptions{Journal: journal, All: true}); err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+
+	want := []string{"DELETE /api/albums/new-album/assets", "DELETE /api/albums/new-album"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("Revert requests = %q, want %q", requests, want)
+	}
+
+	if got := journal.AppliedOperations(); len(got) != 0 {
+		t.Errorf("Applied operations after reverting all = %v, want none", got)
+	}
+}
+
+func TestApplier_RecordState(t *testing.T) {
+	t.Parallel()
+
+	var requests []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/server/version":
+			_, _ = w.Write([]byte(`{"major":1,"minor":120,"patch":0}`))
+		case r.Method == http.MethodGet:
+			// The album as it is right before the plan is applied
+			_, _ = w.Write([]byte(`{"id":"a1","albumName":"Renamed since",` +
+				`"albumUsers":[{"user":{"id":"u1"},"role":"editor"}],"assets":[{"id":"x1"}]}`))
+		default:
+			body, _ := io.ReadAll(r.Body)
+			requests = append(requests, strings.TrimSpace(r.Method+" "+r.URL.Path+" "+string(body)))
+		}
+	}))
+	defer server.Close()
+
+	p := &plan.Plan{Operations: []plan.Operation{
+		{
+			// The plan guesses the role the user had
+			Apply:  []plan.Request{{Path: "/api/albums/a1/user/u1", Method: http.MethodDelete}},
+			Revert: []plan.Request{{Path: "/api/albums/a1/users", Method: http.MethodPut, Body: json.RawMessage(`{"albumUsers":[{"role":"viewer","userId":"u1"}]}`)}},
+		},
+		{
+			// The name was read when the plan was generated
+			Intents: []plan.Intent{{Kind: plan.IntentAlbumRename, AlbumID: "a1", Name: "New", From: "Old"}},
+		},
+		{
+			Apply:  []plan.Request{{Path: "/api/albums/a1/assets", Method: http.MethodPut, Body: json.RawMessage(`{"ids":["x1","x2"]}`)}},
+			Revert: []plan.Request{{Path: "/api/albums/a1/assets", Method: http.MethodDelete, Body: json.RawMessage(`{"ids":["x1","x2"]}`)}},
+		},
+	}}
+
+	journalPath := filepath.Join(t.TempDir(), "plan.json.journal")
+	applier := NewApplier(immich.NewClient(server.URL, "test-token"))
+
+	journal, err := plan.OpenJournal(journalPath)
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+
+	if err := applier.Apply(p, &ApplyOptions{Journal: journal, RecordState: true}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	journal, err = plan.OpenJournal(journalPath)
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+
+	defer func() { _ = journal.Close() }()
+
+	wantRevert := []plan.Intent{{Kind: plan.IntentAlbumAddUser, AlbumID: "a1", UserID: "u1", Role: "editor"}}
+	if got, ok := journal.Revert(0); !ok || !reflect.DeepEqual(got, wantRevert) {
+		t.Errorf("Revert(0) = %v, %v; want %v", got, ok, wantRevert)
+	}
+
+	requests = nil
+
+	if err := applier.Revert(p, &ApplyOptions{Journal: journal}); err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+
+	// Only the asset that was added is removed, and the name and role the
+	// server had are restored
+	want := []string{
+		`DELETE /api/albums/a1/assets {"ids":["x2"]}`,
+		`PATCH /api/albums/a1 {"albumName":"Renamed since"}`,
+		`PUT /api/albums/a1/users {"albumUsers":[{"role":"editor","userId":"u1"}]}`,
+	}
+
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("Revert requests = %q, want %q", requests, want)
+	}
+}
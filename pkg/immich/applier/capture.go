@@ -0,0 +1,278 @@
+package applier
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sync"
+
+	"immich-manager/pkg/plan"
+)
+
+// captures tracks which operations of a run are applied and the values
+// captured from their responses, so that operations can wait for the
+// operations they depend on and have their placeholders resolved.
+type captures struct {
+	mu sync.Mutex
+	// fields lists the response fields referenced by other operations, by
+	// operation ID.
+	fields map[string][]string
+	// ids maps indices in the original plan to operation IDs, and indices
+	// maps them back.
+	ids     map[int]string
+	indices map[string]int
+	values  plan.Captures
+	// applied holds the operations currently applied, by index in the
+	// original plan.
+	applied map[int]bool
+	// dependents maps operation IDs to the indices of the operations that
+	// depend on them.
+	dependents map[string][]int
+	// reverts holds the intents that restore the state recorded right
+	// before operations were applied, by index in the original plan.
+	reverts map[int][]plan.Intent
+	// partial holds the number of apply requests sent for operations that
+	// failed part way, by index in the original plan.
+	partial map[int]int
+	dir     direction
+}
+
+// newCaptures returns the captures of a run executing p. Values, recorded
+// reverts and applied operations are taken from the journal, if any.
+// Without a journal, or when all operations are executed, reverts assume
+// every operation is applied.
+func newCaptures(p *plan.Plan, journal *plan.Journal, all bool, dir direction) *captures {
+	c := &captures{
+		fields:     p.CapturedFields(),
+		ids:        make(map[int]string),
+		indices:    make(map[string]int),
+		values:     make(plan.Captures),
+		applied:    make(map[int]bool),
+		dependents: make(map[string][]int),
+		reverts:    make(map[int][]plan.Intent),
+		partial:    make(map[int]int),
+		dir:        dir,
+	}
+
+	for i, op := range p.Operations {
+		index := dir.index(i)
+
+		if op.ID != "" {
+			c.ids[index] = op.ID
+			c.indices[op.ID] = index
+		}
+
+		for _, id := range op.Dependencies() {
+			c.dependents[id] = append(c.dependents[id], index)
+		}
+
+		switch {
+		case dir.action == plan.ActionRevert && (journal == nil || all):
+			c.applied[index] = true
+		case journal != nil:
+			c.applied[index] = journal.Applied(index)
+		}
+
+		if journal == nil {
+			continue
+		}
+
+		if values := journal.Captured(index); values != nil && op.ID != "" {
+			c.values[op.ID] = values
+		}
+
+		if revert, ok := journal.Revert(index); ok {
+			c.reverts[index] = revert
+		}
+	}
+
+	return c
+}
+
+// ready returns an error unless operation i, by index in the original
+// plan, can be executed: when applying, the operations it depends on must
+// be applied, and when reverting, the operations that depend on it must
+// no longer be.
+func (c *captures) ready(i int, op plan.Operation) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dir.action == plan.ActionRevert {
+		for _, j := range c.dependents[op.ID] {
+			if c.applied[j] {
+				return fmt.Errorf("operation %d depends on operation %d and must be reverted first", j, i)
+			}
+		}
+
+		return nil
+	}
+
+	for _, id := range op.Dependencies() {
+		if j, ok := c.indices[id]; !ok || !c.applied[j] {
+			return fmt.Errorf("operation %d depends on operation %q, which is not applied", i, id)
+		}
+	}
+
+	return nil
+}
+
+// after returns the positions in the executed plan of the operations that
+// must finish before the operation at position i starts: those it depends
+// on when applying, and those depending on it when reverting.
+func (c *captures) after(p *plan.Plan, i int) []int {
+	var positions []int
+
+	op := p.Operations[i]
+
+	if c.dir.action == plan.ActionRevert {
+		if op.ID == "" {
+			return nil
+		}
+
+		for _, j := range c.dependents[op.ID] {
+			positions = append(positions, c.dir.index(j))
+		}
+	} else {
+		for _, id := range op.Dependencies() {
+			if j, ok := c.indices[id]; ok {
+				positions = append(positions, j)
+			}
+		}
+	}
+
+	// Later positions would never finish first
+	earlier := positions[:0]
+
+	for _, j := range positions {
+		if j < i {
+			earlier = append(earlier, j)
+		}
+	}
+
+	return earlier
+}
+
+// referenced reports whether other operations reference values of the
+// operation with the given ID.
+func (c *captures) referenced(id string) bool {
+	return id != "" && len(c.fields[id]) > 0
+}
+
+// capture stores the referenced values of the operation with the given ID
+// from the response to its last apply request.
+func (c *captures) capture(id string, response json.RawMessage) error {
+	values, err := plan.Capture(response, c.fields[id])
+
+	c.mu.Lock()
+	c.values[id] = values
+	c.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("capturing values of operation %q: %w", id, err)
+	}
+
+	return nil
+}
+
+// captured returns the values captured from the response of the
+// operation with the given ID.
+func (c *captures) captured(id string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.values[id]
+}
+
+// snapshot returns the values captured from the operations with an ID that
+// are applied, with no values for those nothing was captured from.
+func (c *captures) snapshot() plan.Captures {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := make(plan.Captures)
+
+	for i, id := range c.ids {
+		if !c.applied[i] {
+			continue
+		}
+
+		values := make(map[string]string, len(c.values[id]))
+		for field, value := range c.values[id] {
+			values[field] = value
+		}
+
+		snapshot[id] = values
+	}
+
+	return snapshot
+}
+
+// resolve replaces the placeholders of req with captured values.
+func (c *captures) resolve(req plan.Request) (plan.Request, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resolved, err := c.values.Resolve(req)
+	if err != nil {
+		return plan.Request{}, fmt.Errorf("resolving %s %s: %w", req.Method, req.Path, err)
+	}
+
+	return resolved, nil
+}
+
+// recordRevert stores the intents that restore the state recorded right
+// before operation i, by index in the original plan, was applied.
+func (c *captures) recordRevert(i int, revert []plan.Intent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reverts[i] = revert
+}
+
+// exactRevert returns the intents that restore the state recorded right
+// before operation i, by index in the original plan, was applied, and
+// false when no state was recorded.
+func (c *captures) exactRevert(i int) ([]plan.Intent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	revert, ok := c.reverts[i]
+
+	return revert, ok
+}
+
+// sentPartly records that operation i, by index in the original plan,
+// failed after its first sent apply requests succeeded.
+func (c *captures) sentPartly(i, sent int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.partial[i] = sent
+}
+
+// sentPartlyOperations returns the number of apply requests sent for the
+// operations that failed part way, by index in the original plan.
+func (c *captures) sentPartlyOperations() map[int]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return maps.Clone(c.partial)
+}
+
+// completed records that operation i, by index in the original plan, was
+// executed in the given direction and returns its journal entry, with the
+// captured values and recorded revert of applied operations.
+func (c *captures) completed(i int, action plan.Action) plan.JournalEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.applied[i] = action == plan.ActionApply
+
+	entry := plan.JournalEntry{Operation: i, Action: action}
+	if action == plan.ActionApply {
+		entry.Captures = c.values[c.ids[i]]
+		entry.Revert, entry.Exact = c.reverts[i]
+	}
+
+	return entry
+}
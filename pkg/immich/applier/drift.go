@@ -0,0 +1,116 @@
+package applier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"immich-manager/pkg/plan"
+)
+
+// DriftPolicy decides what happens to an operation whose preconditions no
+// longer hold.
+type DriftPolicy string
+
+const (
+	// DriftFail stops the apply at the first drifted operation.
+	DriftFail DriftPolicy = "fail"
+	// DriftSkip skips drifted operations and continues with the rest.
+	DriftSkip DriftPolicy = "skip"
+	// DriftForce applies drifted operations anyway.
+	DriftForce DriftPolicy = "force"
+)
+
+// ParseDriftPolicy parses a drift policy name.
+func ParseDriftPolicy(s string) (DriftPolicy, error) {
+	switch policy := DriftPolicy(s); policy {
+	case DriftFail, DriftSkip, DriftForce:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown drift policy %q: must be one of fail, skip or force", s)
+	}
+}
+
+// DriftError is returned when an operation's preconditions no longer hold
+// and the drift policy is DriftFail.
+type DriftError struct {
+	// Operation is the index of the drifted operation.
+	Operation int
+	// Drift holds one error per violated precondition.
+	Drift []error
+}
+
+// Error implements the error interface.
+func (e *DriftError) Error() string {
+	reasons := make([]string, len(e.Drift))
+	for k, err := range e.Drift {
+		reasons[k] = err.Error()
+	}
+
+	return fmt.Sprintf("operation %d: %s", e.Operation, strings.Join(reasons, "; "))
+}
+
+// Unwrap returns the violated preconditions.
+func (e *DriftError) Unwrap() []error {
+	return e.Drift
+}
+
+// checkPreconditions verifies the preconditions of operation i. Violated
+// preconditions are returned as a *DriftError, failures to check them as
+// a plain error.
+func (a *Applier) checkPreconditions(ctx context.Context, i int, op plan.Operation) error {
+	var drift []error
+
+	for _, pc := range op.Preconditions {
+		err := a.client.CheckPrecondition(ctx, pc)
+		if errors.Is(err, plan.ErrDrift) {
+			drift = append(drift, err)
+
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("checking precondition for operation %d (%s): %w", i, pc, explain(err))
+		}
+	}
+
+	if len(drift) > 0 {
+		return &DriftError{Operation: i, Drift: drift}
+	}
+
+	return nil
+}
+
+// handleDrift checks the preconditions of operation i and applies the drift
+// policy. It reports whether the operation should be applied.
+func (a *Applier) handleDrift(ctx context.Context, i int, op plan.Operation, opts *ApplyOptions) (bool, error) {
+	if len(op.Preconditions) == 0 {
+		return true, nil
+	}
+
+	err := a.checkPreconditions(ctx, i, op)
+	if err == nil {
+		return true, nil
+	}
+
+	var driftErr *DriftError
+	if !errors.As(err, &driftErr) {
+		return false, err
+	}
+
+	switch opts.OnDrift {
+	case DriftSkip:
+		opts.warnf("Skipping drifted %v\n", driftErr)
+
+		return false, nil
+	case DriftForce:
+		opts.warnf("Applying drifted %v\n", driftErr)
+
+		return true, nil
+	case DriftFail:
+		return false, driftErr
+	default:
+		return false, driftErr
+	}
+}
@@ -0,0 +1,184 @@
+package applier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+// newDriftServer serves two albums, one of which was renamed since the
+// test plan was generated, and records the renames it receives.
+func newDriftServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var (
+		mu      sync.Mutex
+		renamed []string
+	)
+
+	names := map[string]string{"1": "Italy 2023", "2": "Renamed by someone"}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/albums/")
+
+		name, ok := names[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			_ = json.NewEncoder(w).Encode(immich.Album{ID: id, Name: name})
+		case http.MethodPatch:
+			mu.Lock()
+			renamed = append(renamed, id)
+			mu.Unlock()
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return renamed
+	}
+}
+
+func renamePlan() *plan.Plan {
+	rename := func(id, from, to string) plan.Operation {
+		return plan.Operation{
+			Preconditions: []plan.Precondition{plan.AlbumNamed(id, from)},
+			Apply: []plan.Request{{
+				Path: "/api/albums/" + id, Method: http.MethodPatch, Body: mustMarshal(map[string]string{"albumName": to}),
+			}},
+			Revert: []plan.Request{{
+				Path: "/api/albums/" + id, Method: http.MethodPatch, Body: mustMarshal(map[string]string{"albumName": from}),
+			}},
+		}
+	}
+
+	return &plan.Plan{Operations: []plan.Operation{
+		rename("1", "Italy 2023", "Italy 2024"),
+		rename("2", "Spain 2023", "Spain 2024"),
+	}}
+}
+
+func TestApplier_DriftPolicies(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		policy  DriftPolicy
+		renamed []string
+		drifted bool
+	}{
+		{policy: "", renamed: []string{"1"}, drifted: true},
+		{policy: DriftFail, renamed: []string{"1"}, drifted: true},
+		{policy: DriftSkip, renamed: []string{"1"}},
+		{policy: DriftForce, renamed: []string{"1", "2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			t.Parallel()
+
+			server, renamed := newDriftServer(t)
+			defer server.Close()
+
+			var out bytes.Buffer
+
+			a := NewApplier(immich.NewClient(server.URL, "test-token"))
+			err := a.Apply(renamePlan(), &ApplyOptions{Writer: &out, OnDrift: tt.policy})
+
+			var driftErr *DriftError
+			if tt.drifted {
+				if !errors.As(err, &driftErr) || driftErr.Operation != 1 || !errors.Is(err, plan.ErrDrift) {
+					t.Fatalf("Expected a drift error for operation 1, got %v", err)
+				}
+
+				if !strings.Contains(err.Error(), "is named 'Renamed by someone', expected 'Spain 2023'") {
+					t.Errorf("Expected the drift to be described, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(renamed(), tt.renamed) {
+				t.Errorf("Expected albums %v to be renamed, got %v", tt.renamed, renamed())
+			}
+
+			if !tt.drifted && !strings.Contains(out.String(), "drifted operation 1") {
+				t.Errorf("Expected a drift warning, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestParseDriftPolicy(t *testing.T) {
+	t.Parallel()
+
+	if policy, err := ParseDriftPolicy("skip"); err != nil || policy != DriftSkip {
+		t.Errorf("ParseDriftPolicy(skip) = %v, %v", policy, err)
+	}
+
+	if _, err := ParseDriftPolicy("ignore"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
+
+func TestApplier_RevertChecksDrift(t *testing.T) {
+	t.Parallel()
+
+	server, renamed := newDriftServer(t)
+	defer server.Close()
+
+	// Album 1 still has the name the plan gave it, album 2 was renamed by
+	// someone else after the plan was applied
+	p := &plan.Plan{Operations: []plan.Operation{
+		{
+			Preconditions: []plan.Precondition{plan.AlbumNamed("1", "Italy 2022")},
+			Apply: []plan.Request{{
+				Path: "/api/albums/1", Method: http.MethodPatch, Body: mustMarshal(map[string]string{"albumName": "Italy 2023"}),
+			}},
+			Revert: []plan.Request{{
+				Path: "/api/albums/1", Method: http.MethodPatch, Body: mustMarshal(map[string]string{"albumName": "Italy 2022"}),
+			}},
+		},
+		{
+			Preconditions: []plan.Precondition{plan.AlbumNamed("2", "Spain 2022")},
+			Apply: []plan.Request{{
+				Path: "/api/albums/2", Method: http.MethodPatch, Body: mustMarshal(map[string]string{"albumName": "Spain 2023"}),
+			}},
+			Revert: []plan.Request{{
+				Path: "/api/albums/2", Method: http.MethodPatch, Body: mustMarshal(map[string]string{"albumName": "Spain 2022"}),
+			}},
+		},
+	}}
+
+	var out bytes.Buffer
+
+	a := NewApplier(immich.NewClient(server.URL, "test-token"))
+	if err := a.Revert(p, &ApplyOptions{Writer: &out, OnDrift: DriftSkip}); err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(renamed(), []string{"1"}) {
+		t.Errorf("Expected only album 1 to be reverted, got %v", renamed())
+	}
+
+	if !strings.Contains(out.String(), "Skipping drifted operation 1") {
+		t.Errorf("Expected the drifted operation to be reported by its index in the plan, got %q", out.String())
+	}
+}
@@ -0,0 +1,94 @@
+package applier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"immich-manager/pkg/plan"
+)
+
+// OperationError is the failure of a single operation.
+type OperationError struct {
+	// Operation is the index of the operation in the original plan.
+	Operation int
+	// Err is the reason the operation failed.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *OperationError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the reason the operation failed.
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// OperationsError is returned when operations fail during a parallel run
+// or a run that continues on error. Operations already in flight when the
+// first failure occurs are allowed to finish, so more than one operation
+// can fail even when the run stops at the first failure.
+type OperationsError struct {
+	// Errors holds one error per failed operation, ordered by operation.
+	Errors []*OperationError
+	// Completed holds the indices of operations that were executed.
+	Completed []int
+	// Captured holds the values captured from the operations with an ID
+	// that are applied at the end of the run, with no values for those
+	// nothing was captured from. Operations that failed can be applied
+	// again on their own using them.
+	Captured plan.Captures
+}
+
+// Error implements the error interface.
+func (e *OperationsError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%d operations failed:", len(e.Errors))
+
+	for _, err := range e.Errors {
+		fmt.Fprintf(&b, "\n  %v", err)
+	}
+
+	return b.String()
+}
+
+// Unwrap returns the failure of every operation.
+func (e *OperationsError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for k, err := range e.Errors {
+		errs[k] = err
+	}
+
+	return errs
+}
+
+// Operations returns the indices of the failed operations in ascending
+// order.
+func (e *OperationsError) Operations() []int {
+	indices := make([]int, len(e.Errors))
+	for k, err := range e.Errors {
+		indices[k] = err.Operation
+	}
+
+	return indices
+}
+
+// failed builds the error for a run in which the given operations failed,
+// with failures and completed operations ordered by operation.
+func failed(failures []*OperationError, executed []int, captured plan.Captures, dir direction) *OperationsError {
+	sort.Slice(failures, func(x, y int) bool {
+		return failures[x].Operation < failures[y].Operation
+	})
+
+	completed := originalIndices(executed, dir)
+	sort.Ints(completed)
+
+	return &OperationsError{Errors: failures, Completed: completed, Captured: captured}
+}
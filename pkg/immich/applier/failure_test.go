@@ -0,0 +1,126 @@
+package applier
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+func TestApplier_ContinueOnError(t *testing.T) {
+	t.Parallel()
+
+	for _, parallelism := range []int{1, 3} {
+		t.Run("parallelism "+strconv.Itoa(parallelism), func(t *testing.T) {
+			t.Parallel()
+
+			s := &concurrencyServer{
+				active: make(map[string]int),
+				failing: map[string]bool{
+					"PATCH /api/albums/2": true,
+					"PATCH /api/albums/4": true,
+				},
+			}
+
+			server := httptest.NewServer(s)
+			defer server.Close()
+
+			p := &plan.Plan{}
+			for _, album := range []string{"1", "2", "3", "4", "2"} {
+				p.Operations = append(p.Operations, renameOperation(album, "name"))
+			}
+
+			journal, err := plan.OpenJournal(filepath.Join(t.TempDir(), "plan.json.journal"))
+			if err != nil {
+				t.Fatalf("OpenJournal() error = %v", err)
+			}
+
+			defer func() { _ = journal.Close() }()
+
+			applier := NewApplier(immich.NewClient(server.URL, "test-token"))
+			opts := &ApplyOptions{Journal: journal, ContinueOnError: true, Parallelism: parallelism}
+
+			err = applier.Apply(p, opts)
+
+			var opsErr *OperationsError
+			if !errors.As(err, &opsErr) {
+				t.Fatalf("Expected an *OperationsError, got %v", err)
+			}
+
+			if got := opsErr.Operations(); !reflect.DeepEqual(got, []int{1, 3, 4}) {
+				t.Errorf("Failed operations = %v, want [1 3 4]", got)
+			}
+
+			if !reflect.DeepEqual(opsErr.Completed, []int{0, 2}) {
+				t.Errorf("Completed operations = %v, want [0 2]", opsErr.Completed)
+			}
+
+			if got := journal.AppliedOperations(); !reflect.DeepEqual(got, []int{0, 2}) {
+				t.Errorf("Journaled operations = %v, want [0 2]", got)
+			}
+
+			if len(s.requests) != len(p.Operations) {
+				t.Errorf("Expected every operation to be attempted, got %v", s.requests)
+			}
+
+			var apiErr *immich.APIError
+			if !errors.As(opsErr.Errors[0], &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected the API error to be preserved, got %v", opsErr.Errors[0])
+			}
+		})
+	}
+}
+
+func TestApplier_ContinueOnErrorCaptured(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/albums" {
+			_, _ = w.Write([]byte(`{"id":"new"}`))
+
+			return
+		}
+
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	albumID := plan.Placeholder("create_album", "id")
+	p := &plan.Plan{Operations: []plan.Operation{
+		{
+			ID:     "create_album",
+			Apply:  []plan.Request{{Path: "/api/albums", Method: http.MethodPost, Body: []byte(`{"albumName":"New"}`)}},
+			Revert: []plan.Request{{Path: "/api/albums/" + albumID, Method: http.MethodDelete}},
+		},
+		{
+			DependsOn: []string{"create_album"},
+			Apply:     []plan.Request{{Path: "/api/albums/" + albumID + "/assets", Method: http.MethodPut}},
+		},
+	}}
+
+	err := NewApplier(immich.NewClient(server.URL, "test-token")).Apply(p, &ApplyOptions{ContinueOnError: true})
+
+	var opsErr *OperationsError
+	if !errors.As(err, &opsErr) {
+		t.Fatalf("Expected an *OperationsError, got %v", err)
+	}
+
+	if want := (plan.Captures{"create_album": {"id": "new"}}); !reflect.DeepEqual(opsErr.Captured, want) {
+		t.Errorf("Captured = %v, want %v", opsErr.Captured, want)
+	}
+
+	retry, err := p.Retry(opsErr.Operations(), opsErr.Captured)
+	if err != nil {
+		t.Fatalf("Retry() error = %v", err)
+	}
+
+	if got := retry.Operations[0].Apply[0].Path; got != "/api/albums/new/assets" {
+		t.Errorf("Retried operation path = %s, want the captured album ID filled in", got)
+	}
+}
@@ -0,0 +1,34 @@
+package applier
+
+import (
+	"fmt"
+
+	"immich-manager/pkg/plan"
+)
+
+// InterruptedError is returned when execution stops early because its
+// context was cancelled. Requests are not cancelled while in flight, so the
+// operation in flight is either completed and recorded in the journal, or,
+// when its retries were stopped part way through, reported as failed with
+// the requests it sent, which an atomic run rolls back.
+type InterruptedError struct {
+	// Err is the context error that caused the interruption.
+	Err error
+	// Action is the direction that was being executed.
+	Action plan.Action
+	// Completed holds the indices of operations executed before stopping.
+	Completed []int
+	// Remaining is the number of operations that were not started.
+	Remaining int
+}
+
+// Error implements the error interface.
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("%s interrupted after %d operations with %d remaining: %v",
+		e.Action, len(e.Completed), e.Remaining, e.Err)
+}
+
+// Unwrap returns the context error.
+func (e *InterruptedError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,76 @@
+package applier
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+func TestApplier_ApplyContextInterrupted(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var requests []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		// Interrupt while the first request of operation 2 is in flight
+		if r.URL.Path == "/api/albums/2/users" {
+			cancel()
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p := &plan.Plan{Operations: make([]plan.Operation, 0, 3)}
+	for _, id := range []string{"1", "2", "3"} {
+		p.Operations = append(p.Operations, plan.Operation{
+			Apply: []plan.Request{
+				{Path: "/api/albums/" + id + "/users", Method: http.MethodPut},
+				{Path: "/api/albums/" + id, Method: http.MethodPatch},
+			},
+		})
+	}
+
+	applier := NewApplier(immich.NewClient(server.URL, "test-token"))
+
+	err := applier.ApplyContext(ctx, p, nil)
+
+	var interrupted *InterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("Expected an InterruptedError, got %v", err)
+	}
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected error to wrap context.Canceled, got %v", err)
+	}
+
+	// The in-flight operation is completed before stopping
+	if !reflect.DeepEqual(interrupted.Completed, []int{0, 1}) {
+		t.Errorf("Completed = %v, want [0 1]", interrupted.Completed)
+	}
+
+	if interrupted.Remaining != 1 {
+		t.Errorf("Remaining = %d, want 1", interrupted.Remaining)
+	}
+
+	want := []string{
+		"PUT /api/albums/1/users",
+		"PATCH /api/albums/1",
+		"PUT /api/albums/2/users",
+		"PATCH /api/albums/2",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("Requests = %v, want %v", requests, want)
+	}
+}
@@ -0,0 +1,222 @@
+package applier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+// EventType identifies what happened during execution.
+type EventType string
+
+const (
+	// EventStarted is emitted once before the first operation.
+	EventStarted EventType = "started"
+	// EventOperationStarted is emitted before an operation is executed.
+	EventOperationStarted EventType = "operation.started"
+	// EventOperationSucceeded is emitted when all requests of an
+	// operation completed.
+	EventOperationSucceeded EventType = "operation.succeeded"
+	// EventOperationSkipped is emitted when the drift policy skipped an
+	// operation.
+	EventOperationSkipped EventType = "operation.skipped"
+	// EventOperationFailed is emitted when an operation failed.
+	EventOperationFailed EventType = "operation.failed"
+	// EventOperationRolledBack is emitted when an atomic run reverted an
+	// operation it had executed.
+	EventOperationRolledBack EventType = "operation.rolledBack"
+	// EventRequest is emitted after each request with its latency.
+	EventRequest EventType = "request"
+	// EventFinished is emitted once when execution ends.
+	EventFinished EventType = "finished"
+)
+
+// Event describes progress while a plan is applied or reverted.
+type Event struct {
+	Type   EventType   `json:"type"`
+	Time   time.Time   `json:"time"`
+	Action plan.Action `json:"action"`
+	// Operation is the index of the operation in the plan, or -1 for
+	// events about the whole run.
+	Operation int `json:"operation"`
+	// Request is the index of the request within the operation. It is
+	// only meaningful for request events.
+	Request int    `json:"request"`
+	Method  string `json:"method,omitempty"`
+	Path    string `json:"path,omitempty"`
+	// Status is the HTTP status code of a request's response, or zero if
+	// no response was received.
+	Status int `json:"status,omitempty"`
+	// Duration is the latency of a request or the length of an operation
+	// or of the whole run.
+	Duration time.Duration `json:"-"`
+	// Error describes a failure on a single line. API errors are reduced
+	// to their method, URL, status, message and correlation ID.
+	Error string `json:"error,omitempty"`
+	// Captures holds the response values other operations reference. It
+	// is only set when an operation was applied.
+	Captures map[string]string `json:"captures,omitempty"`
+	// Revert holds the intents restoring the state recorded right before
+	// an operation was applied. It is nil when no state was recorded and
+	// empty when the operation changed nothing.
+	Revert []plan.Intent `json:"revert,omitempty"`
+
+	// Counts of operations at the time of the event.
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+}
+
+// MarshalJSON encodes the event with its duration in milliseconds.
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+
+	data, err := json.Marshal(struct {
+		event
+		DurationMS float64 `json:"durationMs,omitempty"`
+	}{
+		event:      event(e),
+		DurationMS: float64(e.Duration) / float64(time.Millisecond),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding event: %w", err)
+	}
+
+	return data, nil
+}
+
+// Done returns the number of operations that have finished.
+func (e Event) Done() int {
+	return e.Succeeded + e.Failed + e.Skipped
+}
+
+// Observer receives the events emitted while a plan is executed. Events
+// are delivered one at a time, even when operations run in parallel.
+type Observer interface {
+	Observe(event Event)
+}
+
+// Observers delivers each event to several observers in turn.
+type Observers []Observer
+
+// Observe implements Observer.
+func (o Observers) Observe(event Event) {
+	for _, observer := range o {
+		observer.Observe(event)
+	}
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(event Event)
+
+// Observe calls f(event).
+func (f ObserverFunc) Observe(event Event) {
+	f(event)
+}
+
+// tracker counts operation outcomes and forwards events to an observer.
+// A nil tracker discards events.
+type tracker struct {
+	mu       sync.Mutex
+	observer Observer
+	action   plan.Action
+	start    time.Time
+	total    int
+	counts   map[EventType]int
+}
+
+// newTracker returns a tracker for a run of total operations, or nil
+// when there is no observer.
+func newTracker(observer Observer, action plan.Action, total int) *tracker {
+	if observer == nil {
+		return nil
+	}
+
+	return &tracker{
+		observer: observer,
+		action:   action,
+		start:    time.Now(),
+		total:    total,
+		counts:   make(map[EventType]int),
+	}
+}
+
+// emit completes event with the run's details and delivers it.
+func (t *tracker) emit(event Event) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.counts[event.Type]++
+
+	event.Time = time.Now().UTC()
+	event.Action = t.action
+	event.Total = t.total
+	event.Succeeded = t.counts[EventOperationSucceeded]
+	event.Failed = t.counts[EventOperationFailed]
+	event.Skipped = t.counts[EventOperationSkipped]
+
+	t.observer.Observe(event)
+}
+
+// started emits the start of the run.
+func (t *tracker) started() {
+	t.emit(Event{Type: EventStarted, Operation: -1})
+}
+
+// finished emits the end of the run and its error, if any.
+func (t *tracker) finished(err error) {
+	if t == nil {
+		return
+	}
+
+	event := Event{Type: EventFinished, Operation: -1, Duration: time.Since(t.start)}
+	if err != nil {
+		event.Error = summarize(err)
+	}
+
+	t.emit(event)
+}
+
+// operation emits the outcome of operation i, which started at start,
+// with the values captured and the revert recorded when it was applied.
+func (t *tracker) operation(
+	i int, start time.Time, applied bool, captured map[string]string, revert []plan.Intent, err error,
+) {
+	event := Event{Operation: i, Duration: time.Since(start), Captures: captured, Revert: revert}
+
+	switch {
+	case err != nil:
+		event.Type = EventOperationFailed
+		event.Error = summarize(err)
+	case applied:
+		event.Type = EventOperationSucceeded
+	default:
+		event.Type = EventOperationSkipped
+	}
+
+	t.emit(event)
+}
+
+// summarize formats err on a single line, replacing the multi-line text of
+// any API error it wraps with the error's summary.
+func summarize(err error) string {
+	text := err.Error()
+
+	var apiErr *immich.APIError
+	if errors.As(err, &apiErr) {
+		text = strings.ReplaceAll(text, apiErr.Error(), apiErr.Summary())
+	}
+
+	return strings.ReplaceAll(text, "\n", " ")
+}
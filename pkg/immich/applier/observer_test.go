@@ -0,0 +1,175 @@
+package applier
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+func TestApplier_Observer(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/albums/2" {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p := &plan.Plan{Operations: []plan.Operation{
+		renameOperation("1", "first"),
+		renameOperation("2", "second"),
+		renameOperation("3", "third"),
+	}}
+
+	var events []Event
+
+	applier := NewApplier(immich.NewClient(server.URL, "test-token"))
+	opts := &ApplyOptions{
+		Observer: ObserverFunc(func(event Event) { events = append(events, event) }),
+	}
+
+	if err := applier.Apply(p, opts); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	types := make([]EventType, len(events))
+	for k, event := range events {
+		types[k] = event.Type
+	}
+
+	want := []EventType{
+		EventStarted,
+		EventOperationStarted, EventRequest, EventOperationSucceeded,
+		EventOperationStarted, EventRequest, EventOperationFailed,
+		EventFinished,
+	}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("Events = %v, want %v", types, want)
+	}
+
+	failed := events[5]
+	if failed.Operation != 1 || failed.Status != http.StatusBadRequest || failed.Method != http.MethodPatch {
+		t.Errorf("Unexpected failed request event %+v", failed)
+	}
+
+	finished := events[len(events)-1]
+	if finished.Total != 3 || finished.Succeeded != 1 || finished.Failed != 1 || finished.Error == "" {
+		t.Errorf("Unexpected finished event %+v", finished)
+	}
+
+	if finished.Operation != -1 || finished.Action != plan.ActionApply {
+		t.Errorf("Expected a run event for apply, got %+v", finished)
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	events := NewJSONLines(&buf)
+	events.Observe(Event{Type: EventRequest, Operation: 2, Method: http.MethodPut, Duration: 1500 * time.Microsecond})
+	events.Observe(Event{Type: EventFinished, Operation: -1})
+
+	if err := events.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", buf.String())
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &decoded); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+
+	if decoded["type"] != "request" || decoded["durationMs"] != 1.5 || decoded["method"] != http.MethodPut {
+		t.Errorf("Unexpected event %v", decoded)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+
+	progress := NewProgress(&buf)
+	progress.Observe(Event{Type: EventStarted, Time: start, Total: 4})
+	progress.Observe(Event{
+		Type: EventOperationFailed, Time: start.Add(10 * time.Second), Total: 4, Succeeded: 1, Failed: 1,
+	})
+
+	want := "[===============>              ] 2/4  50% ETA 10s, 1 failed"
+	if got := buf.String(); !strings.HasSuffix(got, "\r"+want+"\x1b[K") {
+		t.Errorf("Progress output = %q, want it to end with %q", got, want)
+	}
+
+	progress.Observe(Event{Type: EventFinished, Time: start.Add(20 * time.Second), Total: 4, Succeeded: 4})
+
+	if got := buf.String(); !strings.HasSuffix(got, "] 4/4 100% in 20s\x1b[K\n") {
+		t.Errorf("Unexpected final progress output %q", got)
+	}
+}
+
+func TestProgress_Above(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var bar, out bytes.Buffer
+
+	progress := NewProgress(&bar)
+	above := progress.Above(&out)
+
+	if _, err := above.Write([]byte("before\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if bar.Len() != 0 {
+		t.Errorf("Expected nothing drawn before the run started, got %q", bar.String())
+	}
+
+	progress.Observe(Event{Type: EventStarted, Time: start, Total: 2})
+	bar.Reset()
+
+	if _, err := above.Write([]byte("Warning: drifted\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := "\r\x1b[K\r[>                             ] 0/2   0% ETA --\x1b[K"
+	if got := bar.String(); got != want {
+		t.Errorf("Progress output around write = %q, want %q", got, want)
+	}
+
+	if got := out.String(); got != "before\nWarning: drifted\n" {
+		t.Errorf("Written output = %q", got)
+	}
+
+	progress.Observe(Event{Type: EventFinished, Time: start.Add(time.Second), Total: 2, Succeeded: 2})
+	bar.Reset()
+
+	if _, err := above.Write([]byte("after\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if bar.Len() != 0 {
+		t.Errorf("Expected nothing drawn after the run finished, got %q", bar.String())
+	}
+}
@@ -0,0 +1,198 @@
+package applier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+// parallelRun tracks the outcome of operations executed concurrently.
+type parallelRun struct {
+	mu sync.Mutex
+	// executed holds positions in the executed plan in completion order.
+	executed []int
+	failures []*OperationError
+	// notStarted counts pending operations skipped after a failure or
+	// cancellation.
+	notStarted int
+	stopped    bool
+	// continueOnError keeps starting operations after a failure.
+	continueOnError bool
+}
+
+// start reports whether another operation may begin, counting it as not
+// started otherwise.
+func (r *parallelRun) start(ctx context.Context) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped || ctx.Err() != nil {
+		r.notStarted++
+
+		return false
+	}
+
+	return true
+}
+
+// fail records the failure of operation i and, unless continuing on
+// error, stops further operations from starting.
+func (r *parallelRun) fail(i int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.failures = append(r.failures, &OperationError{Operation: i, Err: err})
+	r.stopped = !r.continueOnError
+}
+
+// executeParallel runs the pending operations of p on up to
+// opts.Parallelism workers. The requests of an operation are still sent in
+// order, operations touching the same album run one after another in plan
+// order, and operations wait for the operations they depend on.
+func (a *Applier) executeParallel(ctx context.Context, p *plan.Plan, opts *ApplyOptions, dir direction) error {
+	// Warnings from concurrent operations must not interleave
+	parallelOpts := *opts
+	if opts.Writer != nil {
+		parallelOpts.Writer = &lockedWriter{w: opts.Writer}
+	}
+
+	opts = &parallelOpts
+
+	// In-flight operations run to completion even once ctx is cancelled,
+	// without waiting to retry their requests
+	opCtx := context.WithoutCancel(ctx)
+	runCtx := immich.WithRetryStop(opCtx, ctx)
+
+	run := &parallelRun{continueOnError: opts.ContinueOnError && !opts.Atomic}
+	slots := make(chan struct{}, opts.Parallelism)
+	done := make([]chan struct{}, len(p.Operations))
+	last := make(map[string]int)
+
+	var wg sync.WaitGroup
+
+	for i, op := range p.Operations {
+		done[i] = make(chan struct{})
+
+		if !opts.pending(dir.index(i), dir.action) {
+			close(done[i])
+
+			continue
+		}
+
+		// Wait for the previous operation on each album this one touches
+		var after []chan struct{}
+
+		for _, album := range immich.OperationAlbums(op) {
+			if j, ok := last[album]; ok {
+				after = append(after, done[j])
+			}
+
+			last[album] = i
+		}
+
+		// and for the operations it depends on
+		for _, j := range opts.captures.after(p, i) {
+			after = append(after, done[j])
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer close(done[i])
+
+			for _, ch := range after {
+				<-ch
+			}
+
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			if !run.start(ctx) {
+				return
+			}
+
+			a.runParallel(runCtx, run, i, op, opts, dir)
+		}()
+	}
+
+	wg.Wait()
+
+	var err error
+
+	switch {
+	case ctx.Err() != nil && run.notStarted > 0:
+		completed := originalIndices(run.executed, dir)
+		sort.Ints(completed)
+
+		err = &InterruptedError{
+			Err:       ctx.Err(),
+			Action:    dir.action,
+			Completed: completed,
+			Remaining: run.notStarted,
+		}
+
+		if len(run.failures) > 0 {
+			err = errors.Join(err, failed(run.failures, run.executed, opts.captures.snapshot(), dir))
+		}
+	case len(run.failures) > 0:
+		err = failed(run.failures, run.executed, opts.captures.snapshot(), dir)
+	default:
+		return nil
+	}
+
+	if opts.Atomic {
+		return a.rollback(opCtx, p, run.executed, err, opts, dir)
+	}
+
+	return err
+}
+
+// runParallel executes operation i as part of a parallel run.
+func (a *Applier) runParallel(
+	ctx context.Context, run *parallelRun, i int, op plan.Operation, opts *ApplyOptions, dir direction,
+) {
+	ok, err := a.runOperation(ctx, i, op, opts, dir)
+	if err != nil {
+		run.fail(dir.index(i), err)
+
+		return
+	}
+
+	if !ok {
+		return
+	}
+
+	run.mu.Lock()
+	run.executed = append(run.executed, i)
+	run.mu.Unlock()
+
+	if err := opts.record(dir.index(i), dir.action); err != nil {
+		run.fail(dir.index(i), fmt.Errorf("recording operation %d: %w", dir.index(i), err))
+	}
+}
+
+// lockedWriter serialises writes from concurrent operations.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write implements io.Writer.
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n, err := l.w.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("writing output: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,155 @@
+package applier
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+// concurrencyServer records requests and the highest number of requests
+// handled at the same time, overall and per album.
+type concurrencyServer struct {
+	mu       sync.Mutex
+	active   map[string]int
+	inFlight int
+	peak     int
+	overlap  bool
+	requests []string
+	failing  map[string]bool
+}
+
+func (s *concurrencyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Method + " " + r.URL.Path
+	album := immich.AlbumID(r.URL.Path)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, key)
+	s.inFlight++
+	s.peak = max(s.peak, s.inFlight)
+	s.active[album]++
+	s.overlap = s.overlap || s.active[album] > 1
+	s.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	s.mu.Lock()
+	s.inFlight--
+	s.active[album]--
+	s.mu.Unlock()
+
+	if s.failing[key] {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func renameOperation(album, name string) plan.Operation {
+	return plan.Operation{
+		Apply: []plan.Request{{
+			Path: "/api/albums/" + album, Method: http.MethodPatch, Body: []byte(`{"albumName":"` + name + `"}`),
+		}},
+		Revert: []plan.Request{{Path: "/api/albums/" + album + "/assets", Method: http.MethodDelete}},
+	}
+}
+
+func TestApplier_Parallel(t *testing.T) {
+	t.Parallel()
+
+	s := &concurrencyServer{active: make(map[string]int)}
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	p := &plan.Plan{}
+	for _, album := range []string{"1", "2", "3", "4", "1", "1"} {
+		p.Operations = append(p.Operations, renameOperation(album, "name"))
+	}
+
+	journal, err := plan.OpenJournal(filepath.Join(t.TempDir(), "plan.json.journal"))
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+
+	defer func() { _ = journal.Close() }()
+
+	applier := NewApplier(immich.NewClient(server.URL, "test-token"))
+	opts := &ApplyOptions{Journal: journal, Parallelism: 3}
+
+	if err := applier.Apply(p, opts); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	if s.peak < 2 || s.peak > 3 {
+		t.Errorf("Expected between 2 and 3 concurrent requests, got %d", s.peak)
+	}
+
+	if s.overlap {
+		t.Error("Operations on the same album ran concurrently")
+	}
+
+	if got := journal.AppliedOperations(); !reflect.DeepEqual(got, []int{0, 1, 2, 3, 4, 5}) {
+		t.Errorf("Expected every operation to be journaled, got %v", got)
+	}
+
+	if err := applier.Revert(p, opts); err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+
+	if got := journal.AppliedOperations(); len(got) != 0 {
+		t.Errorf("Expected every operation to be reverted, got %v applied", got)
+	}
+
+	if s.overlap {
+		t.Error("Reverts on the same album ran concurrently")
+	}
+}
+
+func TestApplier_ParallelFailure(t *testing.T) {
+	t.Parallel()
+
+	s := &concurrencyServer{
+		active:  make(map[string]int),
+		failing: map[string]bool{"PATCH /api/albums/1": true},
+	}
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	p := &plan.Plan{Operations: []plan.Operation{
+		renameOperation("1", "first"),
+		renameOperation("1", "second"),
+	}}
+
+	applier := NewApplier(immich.NewClient(server.URL, "test-token"))
+
+	err := applier.Apply(p, &ApplyOptions{Parallelism: 4})
+
+	var opsErr *OperationsError
+	if !errors.As(err, &opsErr) {
+		t.Fatalf("Expected a *OperationsError, got %v", err)
+	}
+
+	if len(opsErr.Errors) != 1 || opsErr.Errors[0].Operation != 0 {
+		t.Errorf("Expected operation 0 to fail, got %v", opsErr.Errors)
+	}
+
+	if !immich.IsBadRequest(err) {
+		t.Errorf("Expected the API error to be preserved, got %v", err)
+	}
+
+	// The second rename waits for the first and never starts
+	if len(s.requests) != 1 {
+		t.Errorf("Expected 1 request, got %v", s.requests)
+	}
+}
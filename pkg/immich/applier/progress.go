@@ -0,0 +1,154 @@
+package applier
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressWidth is the number of characters in the progress bar.
+const progressWidth = 30
+
+// Progress is an Observer that renders a single line progress bar with an
+// estimate of the time remaining. It is meant for terminals.
+type Progress struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	// drawn is the line on screen, empty when no bar is drawn.
+	drawn string
+}
+
+// NewProgress returns a progress bar that is drawn on w.
+func NewProgress(w io.Writer) *Progress {
+	return &Progress{w: w}
+}
+
+// Observe implements Observer.
+func (p *Progress) Observe(event Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch event.Type {
+	case EventStarted:
+		p.start = event.Time
+		p.draw(event)
+	case EventOperationSucceeded, EventOperationFailed, EventOperationSkipped:
+		p.draw(event)
+	case EventFinished:
+		p.draw(event)
+		_, _ = fmt.Fprintln(p.w)
+		p.drawn = ""
+	}
+}
+
+// draw redraws the progress bar in place.
+func (p *Progress) draw(event Event) {
+	p.drawn = p.line(event)
+	_, _ = fmt.Fprintf(p.w, "\r%s\x1b[K", p.drawn)
+}
+
+// Above returns a writer to w that clears the progress bar before each
+// write and draws it again afterwards, so that warnings written while a
+// plan is executed are not mixed into the bar.
+func (p *Progress) Above(w io.Writer) io.Writer {
+	return &aboveProgress{progress: p, w: w}
+}
+
+// aboveProgress is the writer returned by Progress.Above.
+type aboveProgress struct {
+	progress *Progress
+	w        io.Writer
+}
+
+// Write implements io.Writer.
+func (a *aboveProgress) Write(b []byte) (int, error) {
+	p := a.progress
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.drawn != "" {
+		_, _ = fmt.Fprint(p.w, "\r\x1b[K")
+	}
+
+	n, err := a.w.Write(b)
+
+	if p.drawn != "" {
+		_, _ = fmt.Fprintf(p.w, "\r%s\x1b[K", p.drawn)
+	}
+
+	if err != nil {
+		return n, fmt.Errorf("writing above progress: %w", err)
+	}
+
+	return n, nil
+}
+
+// line formats the progress bar for event.
+func (p *Progress) line(event Event) string {
+	done := event.Done()
+
+	fraction := 1.0
+	if event.Total > 0 {
+		fraction = float64(done) / float64(event.Total)
+	}
+
+	filled := int(fraction * progressWidth)
+	bar := strings.Repeat("=", filled)
+
+	if filled < progressWidth {
+		bar += ">" + strings.Repeat(" ", progressWidth-filled-1)
+	}
+
+	line := fmt.Sprintf("[%s] %d/%d %3.0f%%", bar, done, event.Total, fraction*100)
+
+	elapsed := event.Time.Sub(p.start)
+
+	switch {
+	case event.Type == EventFinished:
+		line += " in " + elapsed.Round(time.Second).String()
+	case done == 0:
+		line += " ETA --"
+	default:
+		eta := time.Duration(float64(elapsed) / float64(done) * float64(event.Total-done))
+		line += " ETA " + eta.Round(time.Second).String()
+	}
+
+	if event.Skipped > 0 {
+		line += fmt.Sprintf(", %d skipped", event.Skipped)
+	}
+
+	if event.Failed > 0 {
+		line += fmt.Sprintf(", %d failed", event.Failed)
+	}
+
+	return line
+}
+
+// JSONLines is an Observer that writes each event as a line of JSON, for
+// tools that follow progress.
+type JSONLines struct {
+	enc *json.Encoder
+	err error
+}
+
+// NewJSONLines returns an observer that writes events to w.
+func NewJSONLines(w io.Writer) *JSONLines {
+	return &JSONLines{enc: json.NewEncoder(w)}
+}
+
+// Observe implements Observer.
+func (j *JSONLines) Observe(event Event) {
+	if err := j.enc.Encode(event); err != nil && j.err == nil {
+		j.err = fmt.Errorf("writing event: %w", err)
+	}
+}
+
+// Err returns the first error encountered while writing events.
+func (j *JSONLines) Err() error {
+	return j.err
+}
@@ -0,0 +1,103 @@
+package applier
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"immich-manager/pkg/plan"
+)
+
+// RollbackError is returned by an atomic Apply when an operation fails.
+// It carries the original failure along with the outcome of reverting the
+// operations that had already been applied.
+type RollbackError struct {
+	// Err is the failure that triggered the rollback.
+	Err error
+	// RolledBack holds the indices of operations that were reverted,
+	// including operations that failed after some of their requests were
+	// sent.
+	RolledBack []int
+	// RollbackErrors holds the failures encountered while reverting.
+	RollbackErrors []error
+}
+
+// Error implements the error interface.
+func (e *RollbackError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%v; rolled back %d operations", e.Err, len(e.RolledBack))
+
+	if len(e.RollbackErrors) > 0 {
+		fmt.Fprintf(&b, "; rollback failed for %d operations:", len(e.RollbackErrors))
+
+		for _, err := range e.RollbackErrors {
+			fmt.Fprintf(&b, "\n  %v", err)
+		}
+	}
+
+	return b.String()
+}
+
+// Unwrap returns the original failure and any rollback failures.
+func (e *RollbackError) Unwrap() []error {
+	return append([]error{e.Err}, e.RollbackErrors...)
+}
+
+// Complete reports whether every applied operation was rolled back.
+func (e *RollbackError) Complete() bool {
+	return len(e.RollbackErrors) == 0
+}
+
+// rollback reverts the executed operations in reverse order after cause
+// made an atomic run fail, starting with the requests sent by operations
+// that failed part way. It keeps going past revert failures so that as
+// much as possible is restored.
+func (a *Applier) rollback(
+	ctx context.Context, p *plan.Plan, executed []int, cause error, opts *ApplyOptions, dir direction,
+) error {
+	rollbackErr := &RollbackError{Err: cause}
+
+	partial := opts.captures.sentPartlyOperations()
+
+	positions := make([]int, 0, len(partial))
+	for index := range partial {
+		positions = append(positions, dir.index(index))
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(positions)))
+
+	for _, i := range positions {
+		if err := a.revertPartly(ctx, i, p.Operations[i], partial[dir.index(i)], opts, dir); err != nil {
+			rollbackErr.RollbackErrors = append(rollbackErr.RollbackErrors, err)
+
+			continue
+		}
+
+		rollbackErr.RolledBack = append(rollbackErr.RolledBack, dir.index(i))
+
+		opts.events.emit(Event{Type: EventOperationRolledBack, Operation: dir.index(i)})
+	}
+
+	for k := len(executed) - 1; k >= 0; k-- {
+		i := executed[k]
+
+		if err := a.revertOperation(ctx, i, p.Operations[i], opts, dir); err != nil {
+			rollbackErr.RollbackErrors = append(rollbackErr.RollbackErrors, err)
+
+			continue
+		}
+
+		rollbackErr.RolledBack = append(rollbackErr.RolledBack, dir.index(i))
+
+		opts.events.emit(Event{Type: EventOperationRolledBack, Operation: dir.index(i)})
+
+		if err := opts.record(dir.index(i), dir.opposite()); err != nil {
+			rollbackErr.RollbackErrors = append(rollbackErr.RollbackErrors,
+				fmt.Errorf("recording rollback of operation %d: %w", dir.index(i), err))
+		}
+	}
+
+	return rollbackErr
+}
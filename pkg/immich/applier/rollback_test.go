@@ -0,0 +1,221 @@
+package applier
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+func TestApplier_AtomicRollback(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		failing          map[string]bool
+		wantRequests     []string
+		wantRolledBack   []int
+		wantRollbackErrs int
+	}{
+		{
+			name:    "rollback restores applied operations",
+			failing: map[string]bool{"PATCH /api/albums/3": true},
+			wantRequests: []string{
+				"PATCH /api/albums/1",
+				"PATCH /api/albums/2",
+				"PATCH /api/albums/3",
+				"DELETE /api/albums/2",
+				"DELETE /api/albums/1",
+			},
+			wantRolledBack: []int{1, 0},
+		},
+		{
+			name: "rollback failures are reported",
+			failing: map[string]bool{
+				"PATCH /api/albums/3":  true,
+				"DELETE /api/albums/2": true,
+			},
+			wantRequests: []string{
+				"PATCH /api/albums/1",
+				"PATCH /api/albums/2",
+				"PATCH /api/albums/3",
+				"DELETE /api/albums/2",
+				"DELETE /api/albums/1",
+			},
+			wantRolledBack:   []int{0},
+			wantRollbackErrs: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var requests []string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key := r.Method + " " + r.URL.Path
+				requests = append(requests, key)
+
+				if tt.failing[key] {
+					w.WriteHeader(http.StatusBadRequest)
+
+					return
+				}
+
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			p := &plan.Plan{Operations: make([]plan.Operation, 0, 3)}
+			for _, id := range []string{"1", "2", "3"} {
+				p.Operations = append(p.Operations, plan.Operation{
+					Apply:  []plan.Request{{Path: "/api/albums/" + id, Method: http.MethodPatch}},
+					Revert: []plan.Request{{Path: "/api/albums/" + id, Method: http.MethodDelete}},
+				})
+			}
+
+			applier := NewApplier(immich.NewClient(server.URL, "test-token"))
+
+			err := applier.Apply(p, &ApplyOptions{Atomic: true})
+			if err == nil {
+				t.Fatal("Expected Apply() to fail")
+			}
+
+			var rollbackErr *RollbackError
+			if !errors.As(err, &rollbackErr) {
+				t.Fatalf("Expected a RollbackError, got %T: %v", err, err)
+			}
+
+			if !strings.Contains(rollbackErr.Err.Error(), "operation 2") {
+				t.Errorf("Expected original failure for operation 2, got %v", rollbackErr.Err)
+			}
+
+			if !reflect.DeepEqual(rollbackErr.RolledBack, tt.wantRolledBack) {
+				t.Errorf("RolledBack = %v, want %v", rollbackErr.RolledBack, tt.wantRolledBack)
+			}
+
+			if len(rollbackErr.RollbackErrors) != tt.wantRollbackErrs {
+				t.Errorf("Expected %d rollback errors, got %v", tt.wantRollbackErrs, rollbackErr.RollbackErrors)
+			}
+
+			if rollbackErr.Complete() != (tt.wantRollbackErrs == 0) {
+				t.Errorf("Complete() = %v with %d rollback errors", rollbackErr.Complete(), tt.wantRollbackErrs)
+			}
+
+			if !reflect.DeepEqual(requests, tt.wantRequests) {
+				t.Errorf("Requests = %v, want %v", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestApplier_AtomicRollbackPartial(t *testing.T) {
+	t.Parallel()
+
+	client := immich.NewClient("http://immich.local", "test-token")
+
+	request := func(req plan.Request, err error) plan.Request {
+		t.Helper()
+
+		if err != nil {
+			t.Fatalf("Building request: %v", err)
+		}
+
+		return req
+	}
+
+	addUser := func(userID string) plan.Request {
+		return request(client.Albums.AddUsersRequest("1", immich.AlbumUserAddition{UserID: userID, Role: immich.RoleViewer}))
+	}
+
+	removeUser := func(userID string) plan.Request {
+		return request(client.Albums.RemoveUserRequest("1", userID))
+	}
+
+	tests := []struct {
+		name         string
+		revert       []plan.Request
+		wantRequests []string
+		wantComplete bool
+	}{
+		{
+			name:   "sent requests are reverted",
+			revert: []plan.Request{removeUser("u3"), removeUser("u2"), removeUser("u1")},
+			wantRequests: []string{
+				"PUT /api/albums/1/users u1",
+				"PUT /api/albums/1/users u2",
+				"PUT /api/albums/1/users u3",
+				"DELETE /api/albums/1/user/u2",
+				"DELETE /api/albums/1/user/u1",
+			},
+			wantComplete: true,
+		},
+		{
+			name:   "reverts that do not mirror the requests are not sent",
+			revert: []plan.Request{removeUser("u1"), removeUser("u2"), removeUser("u3")},
+			wantRequests: []string{
+				"PUT /api/albums/1/users u1",
+				"PUT /api/albums/1/users u2",
+				"PUT /api/albums/1/users u3",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var requests []string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key := r.Method + " " + r.URL.Path
+				if r.Method == http.MethodPut {
+					var body struct {
+						AlbumUsers []immich.AlbumUserAddition `json:"albumUsers"`
+					}
+
+					_ = json.NewDecoder(r.Body).Decode(&body)
+					key += " " + body.AlbumUsers[0].UserID
+				}
+
+				requests = append(requests, key)
+
+				if key == "PUT /api/albums/1/users u3" {
+					w.WriteHeader(http.StatusBadRequest)
+
+					return
+				}
+
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			p := &plan.Plan{Operations: []plan.Operation{{
+				Apply:  []plan.Request{addUser("u1"), addUser("u2"), addUser("u3")},
+				Revert: tt.revert,
+			}}}
+
+			err := NewApplier(immich.NewClient(server.URL, "test-token")).Apply(p, &ApplyOptions{Atomic: true})
+
+			var rollbackErr *RollbackError
+			if !errors.As(err, &rollbackErr) {
+				t.Fatalf("Expected a RollbackError, got %T: %v", err, err)
+			}
+
+			if rollbackErr.Complete() != tt.wantComplete {
+				t.Errorf("Complete() = %v, want %v: %v", rollbackErr.Complete(), tt.wantComplete, rollbackErr)
+			}
+
+			if !reflect.DeepEqual(requests, tt.wantRequests) {
+				t.Errorf("Requests = %v, want %v", requests, tt.wantRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package applier
+
+import (
+	"context"
+	"slices"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+// recordState reads the state operation i changes right before it is
+// applied and keeps the intents that restore it for the journal and for
+// rollbacks. Operations whose requests are not understood, or whose state
+// cannot be read, keep the revert of the plan.
+func (a *Applier) recordState(ctx context.Context, i int, op plan.Operation, opts *ApplyOptions, dir direction) {
+	resolved := plan.Operation{Apply: make([]plan.Request, 0, len(op.Apply))}
+
+	for _, req := range op.Apply {
+		req, err := opts.captures.resolve(req)
+		if err != nil {
+			// Sending the request reports the missing value
+			return
+		}
+
+		resolved.Apply = append(resolved.Apply, req)
+	}
+
+	intents, ok := resolved.ApplyIntents(immich.ParseRequest)
+	if !ok {
+		opts.warnf("Warning: operation %d: requests are not understood, reverting it uses the revert of the plan\n",
+			dir.index(i))
+
+		return
+	}
+
+	revert, err := a.client.ExactRevert(ctx, intents)
+	if err != nil {
+		opts.warnf("Warning: operation %d: reading the state it changes: %v, reverting it uses the revert of the plan\n",
+			dir.index(i), err)
+
+		return
+	}
+
+	opts.captures.recordRevert(dir.index(i), revert)
+}
+
+// exactReverts returns the inverted plan p in which the operations whose
+// state the journal recorded when they were applied restore that state
+// instead of running the revert of the plan.
+func exactReverts(p *plan.Plan, journal *plan.Journal, dir direction) *plan.Plan {
+	if journal == nil {
+		return p
+	}
+
+	exact := *p
+	exact.Operations = slices.Clone(p.Operations)
+
+	for i, op := range exact.Operations {
+		revert, ok := journal.Revert(dir.index(i))
+		if !ok {
+			continue
+		}
+
+		op.Intents, op.Apply, op.Revert = revert, nil, nil
+		exact.Operations[i] = op
+	}
+
+	return &exact
+}
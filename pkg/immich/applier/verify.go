@@ -0,0 +1,110 @@
+package applier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+// Unconverged is an operation whose intended state does not hold on the
+// server after it was applied.
+type Unconverged struct {
+	// Operation is the index of the operation in the plan.
+	Operation int
+	// Reasons holds one error per intended change that is not in effect.
+	Reasons []error
+}
+
+// Verification is the outcome of Verify.
+type Verification struct {
+	// Checked is the number of operations whose postconditions were
+	// checked.
+	Checked int
+	// Unconverged holds the checked operations that did not converge.
+	Unconverged []Unconverged
+	// Unchecked holds the indices of operations with requests that are not
+	// understood or that use captured values, so their intended state is
+	// unknown.
+	Unchecked []int
+}
+
+// Converged reports whether every checked operation converged.
+func (v *Verification) Converged() bool {
+	return len(v.Unconverged) == 0
+}
+
+// Verify checks that the changes made by the operations of p are in effect
+// on the server, using GET requests only. When selected is set only the
+// operations it returns true for are checked. Changes undone by a later
+// checked operation, such as an album renamed twice, are not expected to
+// hold.
+func (a *Applier) Verify(ctx context.Context, p *plan.Plan, selected func(i int) bool) (*Verification, error) {
+	intents := make(map[int][]plan.Intent, len(p.Operations))
+	verification := &Verification{}
+
+	for i, op := range p.Operations {
+		if selected != nil && !selected(i) {
+			continue
+		}
+
+		// Captured values are only known to the run that applied the plan
+		opIntents, ok := op.ApplyIntents(immich.ParseRequest)
+		if !ok || len(op.References()) > 0 {
+			verification.Unchecked = append(verification.Unchecked, i)
+
+			continue
+		}
+
+		intents[i] = opIntents
+	}
+
+	for i := range p.Operations {
+		opIntents, ok := intents[i]
+		if !ok {
+			continue
+		}
+
+		verification.Checked++
+
+		var reasons []error
+
+		for _, intent := range opIntents {
+			if undoneLater(intent, i, len(p.Operations), intents) {
+				continue
+			}
+
+			err := a.client.CheckIntent(ctx, intent)
+			if errors.Is(err, plan.ErrNotConverged) {
+				reasons = append(reasons, err)
+
+				continue
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("verifying operation %d: %w", i, explain(err))
+			}
+		}
+
+		if len(reasons) > 0 {
+			verification.Unconverged = append(verification.Unconverged, Unconverged{Operation: i, Reasons: reasons})
+		}
+	}
+
+	return verification, nil
+}
+
+// undoneLater reports whether an operation after i undoes intent.
+func undoneLater(intent plan.Intent, i, total int, intents map[int][]plan.Intent) bool {
+	for j := i + 1; j < total; j++ {
+		for _, later := range intents[j] {
+			if later.Undoes(intent) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
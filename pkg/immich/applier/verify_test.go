@@ -0,0 +1,108 @@
+package applier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+func TestApplier_Verify(t *testing.T) {
+	t.Parallel()
+
+	albums := map[string]immich.Album{
+		"1": {
+			ID:         "1",
+			Name:       "Italy 2024",
+			AlbumUsers: []immich.AlbumUser{{User: immich.User{ID: "u1"}, Role: immich.RoleViewer}},
+			Assets:     []immich.Asset{{ID: "a1"}, {ID: "a2"}},
+		},
+		"2": {ID: "2", Name: "Spain 2023"},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		album, ok := albums[strings.TrimPrefix(r.URL.Path, "/api/albums/")]
+		if r.Method != http.MethodGet || !ok {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(album)
+	}))
+	defer server.Close()
+
+	client := immich.NewClient(server.URL, "test-token")
+
+	request := func(req plan.Request, err error) plan.Request {
+		t.Helper()
+
+		if err != nil {
+			t.Fatalf("Building request: %v", err)
+		}
+
+		return req
+	}
+
+	operation := func(req plan.Request) plan.Operation {
+		return plan.Operation{Apply: []plan.Request{req}}
+	}
+
+	addUser := func(id string) plan.Operation {
+		return operation(request(client.Albums.AddUsersRequest("1",
+			immich.AlbumUserAddition{UserID: id, Role: immich.RoleViewer})))
+	}
+
+	p := &plan.Plan{Operations: []plan.Operation{
+		operation(request(client.Albums.UpdateRequest("1", immich.AlbumUpdate{Name: "Italy 2024"}))),
+		operation(request(client.Albums.UpdateRequest("2", immich.AlbumUpdate{Name: "Spain 2024"}))),
+		addUser("u1"),
+		addUser("u2"),
+		addUser("u3"),
+		operation(request(client.Albums.RemoveUserRequest("1", "u3"))),
+		operation(request(client.Albums.AddAssetsRequest("1", []string{"a1", "a3"}))),
+		operation(plan.Request{Path: "/api/tags", Method: http.MethodPost}),
+	}}
+
+	verification, err := NewApplier(client).Verify(context.Background(), p, nil)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	unconverged := make([]int, len(verification.Unconverged))
+	for k, u := range verification.Unconverged {
+		unconverged[k] = u.Operation
+
+		if !errors.Is(u.Reasons[0], plan.ErrNotConverged) {
+			t.Errorf("Expected operation %d to fail with ErrNotConverged, got %v", u.Operation, u.Reasons)
+		}
+	}
+
+	if want := []int{1, 3, 6}; !reflect.DeepEqual(unconverged, want) {
+		t.Errorf("Unconverged operations = %v, want %v", unconverged, want)
+	}
+
+	if verification.Checked != 7 || !reflect.DeepEqual(verification.Unchecked, []int{7}) {
+		t.Errorf("Checked %d operations with %v unchecked, want 7 and [7]", verification.Checked, verification.Unchecked)
+	}
+
+	if verification.Converged() {
+		t.Error("Expected verification not to converge")
+	}
+
+	if got := verification.Unconverged[2].Reasons[0].Error(); !strings.Contains(got, "1 of 2 assets are missing") {
+		t.Errorf("Unexpected reason %q", got)
+	}
+
+	verification, err = NewApplier(client).Verify(context.Background(), p, func(i int) bool { return i == 0 })
+	if err != nil || !verification.Converged() || verification.Checked != 1 {
+		t.Errorf("Expected the selected operation to converge, got %+v, %v", verification, err)
+	}
+}
@@ -9,8 +9,10 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"immich-manager/pkg/immich/types"
+	"immich-manager/pkg/plan"
 )
 
 // Client represents an Immich API client.
@@ -18,15 +20,41 @@ type Client struct {
 	serverURL string
 	token     string
 	client    *http.Client
+	retry     RetryPolicy
+
+	Albums *AlbumsService
+	Users  *UsersService
+	Search *SearchService
+	People *PeopleService
+	Server *ServerService
 }
 
-// NewClient creates a new Immich API client.
+// NewClient creates a new Immich API client using the default retry policy.
 func NewClient(serverURL, token string) *Client {
-	return &Client{
+	c := &Client{
 		serverURL: strings.TrimRight(serverURL, "/"),
 		token:     token,
 		client:    &http.Client{},
+		retry:     DefaultRetryPolicy(),
 	}
+
+	c.Albums = &AlbumsService{client: c}
+	c.Users = &UsersService{client: c}
+	c.Search = &SearchService{client: c}
+	c.People = &PeopleService{client: c}
+	c.Server = &ServerService{client: c}
+
+	return c
+}
+
+// SetRetryPolicy replaces the policy used to retry failed requests.
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// RetryPolicy returns the policy used to retry failed requests.
+func (c *Client) RetryPolicy() RetryPolicy {
+	return c.retry
 }
 
 // ServerURL returns the server URL.
@@ -49,8 +77,21 @@ func (c *Client) SetAuthHeader(req *http.Request) {
 	req.Header.Set("X-Api-Key", c.token)
 }
 
+// SetTimeout limits how long a single request attempt may take. Zero
+// means no limit.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.client.Timeout = timeout
+}
+
 // NewRequest creates a new HTTP request with the given method and path.
 func (c *Client) NewRequest(method, path string, body any) (*http.Request, error) {
+	return c.NewRequestWithContext(context.Background(), method, path, body)
+}
+
+// NewRequestWithContext creates a new HTTP request with the given method
+// and path that is bound to ctx. Cancelling ctx aborts the request,
+// including any retries performed by Do.
+func (c *Client) NewRequestWithContext(ctx context.Context, method, path string, body any) (*http.Request, error) {
 	var bodyReader io.Reader
 
 	// Handle request body - avoid sending "null" for any method when body is nil
@@ -65,7 +106,7 @@ func (c *Client) NewRequest(method, path string, body any) (*http.Request, error
 		}
 	}
 
-	req, err := http.NewRequestWithContext(context.Background(), method, c.serverURL+path, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, method, c.serverURL+path, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
@@ -79,8 +120,17 @@ func (c *Client) NewRequest(method, path string, body any) (*http.Request, error
 	return req, nil
 }
 
-// Do performs the HTTP request and decodes the response into the provided value.
+// Do performs the HTTP request and decodes the response into the provided
+// value. The request's context governs cancellation.
 func (c *Client) Do(req *http.Request, v any) error {
+	_, err := c.DoStatus(req, v)
+
+	return err
+}
+
+// DoStatus is like Do but also returns the HTTP status code of the
+// response, or zero when no response was received.
+func (c *Client) DoStatus(req *http.Request, v any) (int, error) {
 	// Save the request body for error reporting
 	var requestBodyBytes []byte
 
@@ -95,51 +145,74 @@ func (c *Client) Do(req *http.Request, v any) error {
 		req.Body = io.NopCloser(bytes.NewBuffer(requestBodyBytes))
 	}
 
-	// Execute the request
-	resp, err := c.client.Do(req)
+	// Execute the request, retrying transient failures
+	resp, respBody, err := c.doWithRetry(req, requestBodyBytes)
 	if err != nil {
-		return fmt.Errorf("performing request: %w", err)
+		return 0, err
 	}
 
-	// Read the entire response body
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("reading response body: %w", err)
+	// Check for error status codes
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, newAPIError(req, resp, requestBodyBytes, respBody)
+	}
+
+	// Reset response body for further processing
+	resp.Body = io.NopCloser(bytes.NewBuffer(respBody))
+
+	// Decode the response if needed, responses such as 204 No Content
+	// have no body to decode
+	if v != nil && len(bytes.TrimSpace(respBody)) > 0 {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return resp.StatusCode, fmt.Errorf("decoding response: %w\nResponse body: %s", err, string(respBody))
+		}
 	}
 
-	err = resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// DoWithContext is like Do but binds the request to ctx first.
+func (c *Client) DoWithContext(ctx context.Context, req *http.Request, v any) error {
+	return c.Do(req.WithContext(ctx), v)
+}
+
+// Send executes a plan request and decodes the response into v, if set.
+func (c *Client) Send(ctx context.Context, r plan.Request, v any) error {
+	req, err := c.NewRequestWithContext(ctx, r.Method, r.Path, r.Body)
 	if err != nil {
-		return fmt.Errorf("closing response body: %w", err)
+		return err
 	}
 
-	// Check for error status codes
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		// Create detailed error message with request and response information
-		var requestBodyStr string
-		if len(requestBodyBytes) > 0 {
-			requestBodyStr = string(requestBodyBytes)
-		} else {
-			requestBodyStr = "<no body>"
-		}
+	return c.Do(req, v)
+}
 
-		return fmt.Errorf("API error: %s %s\nStatus: %d %s\nRequest body: %s\nResponse body: %s",
-			req.Method, req.URL.String(),
-			resp.StatusCode, resp.Status,
-			requestBodyStr,
-			string(respBody))
+// get performs a GET request for path and decodes the response into v.
+func (c *Client) get(ctx context.Context, path string, v any) error {
+	req, err := c.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
 	}
 
-	// Reset response body for further processing
-	resp.Body = io.NopCloser(bytes.NewBuffer(respBody))
+	return c.Do(req, v)
+}
 
-	// Decode the response if needed
-	if v != nil {
-		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
-			return fmt.Errorf("decoding response: %w\nResponse body: %s", err, string(respBody))
+// newPlanRequest builds a plan request, encoding body as JSON unless it
+// is nil.
+func newPlanRequest(method, path string, body any) (plan.Request, error) {
+	req := plan.Request{
+		Path:   path,
+		Method: method,
+	}
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return plan.Request{}, fmt.Errorf("marshaling %s %s body: %w", method, path, err)
 		}
+
+		req.Body = data
 	}
 
-	return nil
+	return req, nil
 }
 
 // Album represents an Immich album.
@@ -150,3 +223,15 @@ type User = types.User
 
 // AlbumUser represents a user shared with an album.
 type AlbumUser = types.AlbumUser
+
+// Asset represents an Immich asset.
+type Asset = types.Asset
+
+// Person represents a person recognised by Immich.
+type Person = types.Person
+
+// Tag represents an Immich tag.
+type Tag = types.Tag
+
+// SharedLink represents a public link to an album or a set of assets.
+type SharedLink = types.SharedLink
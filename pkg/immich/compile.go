@@ -0,0 +1,101 @@
+package immich
+
+import (
+	"fmt"
+	"net/http"
+
+	"immich-manager/pkg/plan"
+)
+
+// pluralAlbumRoutes is the first server version that serves the album
+// endpoints under /api/albums instead of /api/album.
+var pluralAlbumRoutes = ServerVersion{Major: 1, Minor: 106}
+
+// CompileIntent returns the request that carries out intent on a server of
+// the given version. A nil version compiles for the current API.
+func CompileIntent(intent plan.Intent, version *ServerVersion) (plan.Request, error) {
+	album := "/api/albums/" + intent.AlbumID
+	if version != nil && !version.AtLeast(pluralAlbumRoutes) {
+		album = "/api/album/" + intent.AlbumID
+	}
+
+	switch intent.Kind {
+	case plan.IntentAlbumRename:
+		return newPlanRequest(http.MethodPatch, album, AlbumUpdate{Name: intent.Name})
+	case plan.IntentAlbumAddUser:
+		return newPlanRequest(http.MethodPut, album+"/users",
+			addUsersBody{AlbumUsers: []AlbumUserAddition{{Role: intent.Role, UserID: intent.UserID}}})
+	case plan.IntentAlbumRemoveUser:
+		return newPlanRequest(http.MethodDelete, album+"/user/"+intent.UserID, nil)
+	case plan.IntentAlbumAddAssets:
+		return newPlanRequest(http.MethodPut, album+"/assets", assetIDsBody{IDs: intent.AssetIDs})
+	case plan.IntentAlbumRemoveAssets:
+		return newPlanRequest(http.MethodDelete, album+"/assets", assetIDsBody{IDs: intent.AssetIDs})
+	default:
+		return plan.Request{}, fmt.Errorf("cannot compile intent of unknown kind %q", intent.Kind)
+	}
+}
+
+// BuildRequest builds the request for an intent with the current API. It
+// is the plan.RequestBuilder counterpart of ParseRequest.
+func BuildRequest(intent plan.Intent) (plan.Request, error) {
+	return CompileIntent(intent, nil)
+}
+
+// Compile returns a copy of p in which every operation given as intents
+// carries the requests that apply and revert it on a server of the given
+// version instead. Operations given as requests are copied unchanged.
+func Compile(p *plan.Plan, version *ServerVersion) (*plan.Plan, error) {
+	compiled := *p
+	compiled.Operations = make([]plan.Operation, len(p.Operations))
+
+	for i, op := range p.Operations {
+		if len(op.Intents) == 0 {
+			compiled.Operations[i] = op
+
+			continue
+		}
+
+		reverts, ok := op.RevertIntents(nil)
+		if !ok {
+			return nil, fmt.Errorf("operation %d: %w", i, plan.ErrNotInvertible)
+		}
+
+		apply, err := CompileIntents(op.Intents, version)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		revert, err := CompileIntents(reverts, version)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		compiled.Operations[i] = plan.Operation{
+			ID:            op.ID,
+			DependsOn:     op.DependsOn,
+			Preconditions: op.Preconditions,
+			Apply:         apply,
+			Revert:        revert,
+		}
+	}
+
+	return &compiled, nil
+}
+
+// CompileIntents compiles each of intents to a request for a server of the
+// given version.
+func CompileIntents(intents []plan.Intent, version *ServerVersion) ([]plan.Request, error) {
+	reqs := make([]plan.Request, 0, len(intents))
+
+	for _, intent := range intents {
+		req, err := CompileIntent(intent, version)
+		if err != nil {
+			return nil, err
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	return reqs, nil
+}
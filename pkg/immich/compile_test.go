@@ -0,0 +1,112 @@
+package immich
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"immich-manager/pkg/plan"
+)
+
+func TestCompileIntent(t *testing.T) {
+	t.Parallel()
+
+	albums := &AlbumsService{}
+	intents := []plan.Intent{
+		{Kind: plan.IntentAlbumRename, AlbumID: "a1", Name: "Italy", From: "Rome"},
+		{Kind: plan.IntentAlbumAddUser, AlbumID: "a1", UserID: "u1", Role: RoleEditor},
+		{Kind: plan.IntentAlbumRemoveUser, AlbumID: "a1", UserID: "u1", Role: RoleEditor},
+		{Kind: plan.IntentAlbumAddAssets, AlbumID: "a1", AssetIDs: []string{"x", "y"}},
+		{Kind: plan.IntentAlbumRemoveAssets, AlbumID: "a1", AssetIDs: []string{"x"}},
+	}
+
+	rename, _ := albums.UpdateRequest("a1", AlbumUpdate{Name: "Italy"})
+	addUser, _ := albums.AddUsersRequest("a1", AlbumUserAddition{Role: RoleEditor, UserID: "u1"})
+	removeUser, _ := albums.RemoveUserRequest("a1", "u1")
+	addAssets, _ := albums.AddAssetsRequest("a1", []string{"x", "y"})
+	removeAssets, _ := albums.RemoveAssetsRequest("a1", []string{"x"})
+
+	current := []plan.Request{rename, addUser, removeUser, addAssets, removeAssets}
+	legacyPaths := []string{"/api/album/a1", "/api/album/a1/users", "/api/album/a1/user/u1", "/api/album/a1/assets", "/api/album/a1/assets"}
+
+	for i, intent := range intents {
+		for _, version := range []*ServerVersion{nil, {Major: 1, Minor: 106}, {Major: 2}} {
+			got, err := CompileIntent(intent, version)
+			if err != nil {
+				t.Fatalf("CompileIntent(%s) error = %v", intent.Kind, err)
+			}
+
+			if !reflect.DeepEqual(got, current[i]) {
+				t.Errorf("CompileIntent(%s, %v) = %+v, want %+v", intent.Kind, version, got, current[i])
+			}
+		}
+
+		legacy, err := CompileIntent(intent, &ServerVersion{Major: 1, Minor: 105, Patch: 1})
+		if err != nil {
+			t.Fatalf("CompileIntent(%s) error = %v", intent.Kind, err)
+		}
+
+		if legacy.Path != legacyPaths[i] || legacy.Method != current[i].Method {
+			t.Errorf("CompileIntent(%s) for v1.105.1 = %s %s, want %s %s",
+				intent.Kind, legacy.Method, legacy.Path, current[i].Method, legacyPaths[i])
+		}
+
+		// Compiled requests are understood as the intents they came from
+		parsed, ok := ParseRequest(current[i])
+		if intent.Kind == plan.IntentAlbumRename {
+			intent.From = ""
+		}
+
+		if intent.Kind == plan.IntentAlbumRemoveUser {
+			intent.Role = ""
+		}
+
+		if !ok || !reflect.DeepEqual(parsed, []plan.Intent{intent}) {
+			t.Errorf("ParseRequest(CompileIntent(%s)) = %+v, want %+v", intent.Kind, parsed, intent)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	raw := plan.Operation{
+		Apply:  []plan.Request{{Path: "/api/people/p1", Method: "PUT"}},
+		Revert: []plan.Request{{Path: "/api/people/p1", Method: "PUT"}},
+	}
+
+	p := &plan.Plan{Operations: []plan.Operation{
+		{
+			Preconditions: []plan.Precondition{plan.AlbumNamed("a1", "Rome")},
+			Intents:       []plan.Intent{{Kind: plan.IntentAlbumRename, AlbumID: "a1", Name: "Italy", From: "Rome"}},
+		},
+		raw,
+	}}
+
+	compiled, err := Compile(p, &ServerVersion{Major: 1, Minor: 118})
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	albums := &AlbumsService{}
+	rename, _ := albums.UpdateRequest("a1", AlbumUpdate{Name: "Italy"})
+	restore, _ := albums.UpdateRequest("a1", AlbumUpdate{Name: "Rome"})
+
+	want := []plan.Operation{
+		{Preconditions: p.Operations[0].Preconditions, Apply: []plan.Request{rename}, Revert: []plan.Request{restore}},
+		raw,
+	}
+
+	if !reflect.DeepEqual(compiled.Operations, want) {
+		t.Errorf("Compile() operations = %+v, want %+v", compiled.Operations, want)
+	}
+
+	if len(p.Operations[0].Apply) != 0 {
+		t.Error("Expected Compile() to leave the plan unchanged")
+	}
+
+	p.Operations[0].Intents[0].From = ""
+	if _, err := Compile(p, nil); !errors.Is(err, plan.ErrNotInvertible) {
+		t.Errorf("Compile() of a rename without from error = %v, want ErrNotInvertible", err)
+	}
+}
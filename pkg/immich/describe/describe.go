@@ -0,0 +1,156 @@
+// Package describe renders plans as human-readable sentences.
+package describe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+// Description is a human-readable rendering of a plan.
+type Description struct {
+	Metadata   *plan.Metadata `json:"metadata,omitempty"`
+	Operations []Operation    `json:"operations"`
+}
+
+// Operation describes a single plan operation.
+type Operation struct {
+	// Number is the 1-based position of the operation in the plan.
+	Number        int      `json:"number"`
+	Preconditions []string `json:"preconditions,omitempty"`
+	Apply         []string `json:"apply"`
+	Revert        []string `json:"revert"`
+}
+
+// Plan describes every operation of p.
+func Plan(ctx context.Context, names *Names, p *plan.Plan) *Description {
+	d := &Description{
+		Metadata:   p.Metadata,
+		Operations: make([]Operation, 0, len(p.Operations)),
+	}
+
+	for i, op := range p.Operations {
+		d.Operations = append(d.Operations, Operation{
+			Number:        i + 1,
+			Preconditions: preconditions(ctx, names, op.Preconditions),
+			Apply:         Apply(ctx, names, op),
+			Revert:        Revert(ctx, names, op),
+		})
+	}
+
+	return d
+}
+
+// Apply describes what applying op does, whether it is given as
+// intents or as requests.
+func Apply(ctx context.Context, names *Names, op plan.Operation) []string {
+	if len(op.Intents) > 0 {
+		return Intents(ctx, names, op.Intents)
+	}
+
+	return Requests(ctx, names, op.Apply)
+}
+
+// Revert describes what reverting op does, whether it is given as intents
+// or as requests.
+func Revert(ctx context.Context, names *Names, op plan.Operation) []string {
+	if len(op.Intents) > 0 {
+		reverts, _ := op.RevertIntents(nil)
+
+		return Intents(ctx, names, reverts)
+	}
+
+	return Requests(ctx, names, op.Revert)
+}
+
+// Intents describes each of intents.
+func Intents(ctx context.Context, names *Names, intents []plan.Intent) []string {
+	sentences := make([]string, 0, len(intents))
+	for _, intent := range intents {
+		sentences = append(sentences, Intent(ctx, names, intent))
+	}
+
+	return sentences
+}
+
+// Requests describes each of reqs.
+func Requests(ctx context.Context, names *Names, reqs []plan.Request) []string {
+	sentences := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		sentences = append(sentences, Request(ctx, names, req))
+	}
+
+	return sentences
+}
+
+// Request describes a single request as a sentence. Requests that are not
+// recognised are described by their method and path.
+func Request(ctx context.Context, names *Names, req plan.Request) string {
+	intents, ok := immich.ParseRequest(req)
+	if !ok {
+		return req.Method + " " + req.Path
+	}
+
+	sentences := make([]string, 0, len(intents))
+	for _, intent := range intents {
+		sentences = append(sentences, Intent(ctx, names, intent))
+	}
+
+	return strings.Join(sentences, "; ")
+}
+
+// Intent describes an intent as a sentence.
+func Intent(ctx context.Context, names *Names, intent plan.Intent) string {
+	album := fmt.Sprintf("album '%s'", names.Album(ctx, intent.AlbumID))
+
+	switch intent.Kind {
+	case plan.IntentAlbumRename:
+		return fmt.Sprintf("Rename %s to '%s'", album, intent.Name)
+	case plan.IntentAlbumAddUser:
+		return fmt.Sprintf("Add %s as %s to %s", names.User(ctx, intent.UserID), intent.Role, album)
+	case plan.IntentAlbumRemoveUser:
+		return fmt.Sprintf("Remove %s from %s", names.User(ctx, intent.UserID), album)
+	case plan.IntentAlbumAddAssets:
+		return fmt.Sprintf("Add %s to %s", assets(len(intent.AssetIDs)), album)
+	case plan.IntentAlbumRemoveAssets:
+		return fmt.Sprintf("Remove %s from %s", assets(len(intent.AssetIDs)), album)
+	default:
+		return fmt.Sprintf("%s on %s", intent.Kind, album)
+	}
+}
+
+// preconditions describes the state each precondition expects.
+func preconditions(ctx context.Context, names *Names, pcs []plan.Precondition) []string {
+	sentences := make([]string, 0, len(pcs))
+
+	for _, pc := range pcs {
+		album := fmt.Sprintf("album '%s'", names.Album(ctx, pc.AlbumID))
+
+		switch pc.Kind {
+		case plan.PreconditionAlbumExists:
+			sentences = append(sentences, album+" exists")
+		case plan.PreconditionAlbumNamed:
+			sentences = append(sentences, fmt.Sprintf("album %s is named '%s'", pc.AlbumID, pc.Name))
+		case plan.PreconditionAlbumMember:
+			sentences = append(sentences, fmt.Sprintf("%s is a member of %s", names.User(ctx, pc.UserID), album))
+		case plan.PreconditionAlbumNotMember:
+			sentences = append(sentences, fmt.Sprintf("%s is not a member of %s", names.User(ctx, pc.UserID), album))
+		default:
+			sentences = append(sentences, pc.String())
+		}
+	}
+
+	return sentences
+}
+
+// assets formats an asset count.
+func assets(n int) string {
+	if n == 1 {
+		return "1 asset"
+	}
+
+	return fmt.Sprintf("%d assets", n)
+}
@@ -0,0 +1,124 @@
+package describe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/plan"
+)
+
+func testPlan(t *testing.T) *plan.Plan {
+	t.Helper()
+
+	albums := &immich.AlbumsService{}
+
+	add, err := albums.AddUsersRequest("a1", immich.AlbumUserAddition{Role: immich.RoleViewer, UserID: "u1"})
+	if err != nil {
+		t.Fatalf("AddUsersRequest() error = %v", err)
+	}
+
+	remove, err := albums.RemoveUserRequest("a1", "u1")
+	if err != nil {
+		t.Fatalf("RemoveUserRequest() error = %v", err)
+	}
+
+	p := &plan.Plan{Operations: []plan.Operation{
+		{
+			Preconditions: []plan.Precondition{plan.AlbumNotMember("a1", "u1")},
+			Apply:         []plan.Request{add},
+			Revert:        []plan.Request{remove},
+		},
+		{
+			Apply:  []plan.Request{{Method: http.MethodPost, Path: "/api/unknown"}},
+			Revert: []plan.Request{},
+		},
+	}}
+	p.SetName("a1", "Italy 2023")
+
+	return p
+}
+
+func TestPlan_RenderText(t *testing.T) {
+	t.Parallel()
+
+	// Album names come from the plan, user names from the server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/users" {
+			t.Errorf("Unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode([]immich.User{{ID: "u1", Email: "alice@example.com"}})
+	}))
+	defer server.Close()
+
+	p := testPlan(t)
+	names := NewNames(immich.NewClient(server.URL, "test-token"), p.Names)
+
+	var out bytes.Buffer
+	if err := Plan(context.Background(), names, p).Render(&out, FormatText); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"2 operations",
+		"1. Add alice@example.com as viewer to album 'Italy 2023'",
+		"Requires: alice@example.com is not a member of album 'Italy 2023'",
+		"Revert: Remove alice@example.com from album 'Italy 2023'",
+		"2. POST /api/unknown",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPlan_RenderMarkdownAndJSON(t *testing.T) {
+	t.Parallel()
+
+	p := testPlan(t)
+	p.Metadata = &plan.Metadata{Generator: "albums add-user", Args: []string{"italy", "alice@example.com"}}
+
+	d := Plan(context.Background(), NewNames(nil, p.Names), p)
+
+	var markdown bytes.Buffer
+	if err := d.Render(&markdown, FormatMarkdown); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"Plan generated by 'albums add-user italy alice@example.com'",
+		"| 1 | Add u1 as viewer to album 'Italy 2023' | Remove u1 from album 'Italy 2023' |",
+		"| 2 | POST /api/unknown | - |",
+	} {
+		if !strings.Contains(markdown.String(), want) {
+			t.Errorf("Markdown missing %q:\n%s", want, markdown.String())
+		}
+	}
+
+	var data bytes.Buffer
+	if err := d.Render(&data, FormatJSON); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	var decoded Description
+	if err := json.Unmarshal(data.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to decode JSON output: %v", err)
+	}
+
+	if len(decoded.Operations) != 2 || decoded.Operations[0].Apply[0] != "Add u1 as viewer to album 'Italy 2023'" {
+		t.Errorf("Unexpected JSON description %+v", decoded)
+	}
+
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
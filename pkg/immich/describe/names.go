@@ -0,0 +1,96 @@
+package describe
+
+import (
+	"context"
+	"sync"
+
+	"immich-manager/pkg/immich"
+)
+
+// Names resolves album and user IDs to human-readable names. Names embedded
+// in a plan are used first; the server, when available, is asked for the
+// rest. Lookups that fail leave the ID as is, since a description should
+// never stop a plan from being reviewed.
+type Names struct {
+	client *immich.Client
+
+	mu     sync.Mutex
+	names  map[string]string
+	albums bool
+	users  bool
+}
+
+// NewNames creates a resolver that starts from the given names. client may
+// be nil to resolve only from the given names.
+func NewNames(client *immich.Client, names map[string]string) *Names {
+	n := &Names{
+		client: client,
+		names:  make(map[string]string, len(names)),
+	}
+
+	for id, name := range names {
+		n.names[id] = name
+	}
+
+	return n
+}
+
+// Album returns the name of an album, or its ID if the name is unknown.
+func (n *Names) Album(ctx context.Context, id string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if name, ok := n.names[id]; ok {
+		return name
+	}
+
+	if n.client != nil && !n.albums {
+		n.albums = true
+
+		if albums, err := n.client.Albums.List(ctx, nil); err == nil {
+			for _, album := range albums {
+				n.remember(album.ID, album.Name)
+			}
+		}
+	}
+
+	if name, ok := n.names[id]; ok {
+		return name
+	}
+
+	return id
+}
+
+// User returns the email of a user, or their ID if it is unknown.
+func (n *Names) User(ctx context.Context, id string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if name, ok := n.names[id]; ok {
+		return name
+	}
+
+	if n.client != nil && !n.users {
+		n.users = true
+
+		if users, err := n.client.Users.List(ctx); err == nil {
+			for _, user := range users {
+				n.remember(user.ID, user.Email)
+			}
+		}
+	}
+
+	if name, ok := n.names[id]; ok {
+		return name
+	}
+
+	return id
+}
+
+// remember stores a name fetched from the server without overriding names
+// embedded in the plan.
+func (n *Names) remember(id, name string) {
+	if _, ok := n.names[id]; !ok {
+		n.names[id] = name
+	}
+}
@@ -0,0 +1,136 @@
+package describe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Format is an output format for a Description.
+type Format string
+
+const (
+	// FormatText renders one numbered sentence per operation.
+	FormatText Format = "text"
+	// FormatMarkdown renders a table suitable for review threads.
+	FormatMarkdown Format = "markdown"
+	// FormatJSON renders the Description as JSON.
+	FormatJSON Format = "json"
+)
+
+// ParseFormat parses an output format name.
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case FormatText, FormatMarkdown, FormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown format %q: must be one of text, markdown or json", s)
+	}
+}
+
+// Render writes the description to w in the given format.
+func (d *Description) Render(w io.Writer, format Format) error {
+	var err error
+
+	switch format {
+	case FormatText:
+		_, err = io.WriteString(w, d.text())
+	case FormatMarkdown:
+		_, err = io.WriteString(w, d.markdown())
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(d)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	if err != nil {
+		return fmt.Errorf("rendering plan: %w", err)
+	}
+
+	return nil
+}
+
+// text renders one numbered block per operation.
+func (d *Description) text() string {
+	var b strings.Builder
+
+	if summary := d.summary(); summary != "" {
+		fmt.Fprintf(&b, "%s\n", summary)
+	}
+
+	fmt.Fprintf(&b, "%d operations\n", len(d.Operations))
+
+	for _, op := range d.Operations {
+		fmt.Fprintf(&b, "\n%d. %s\n", op.Number, strings.Join(op.Apply, "\n   "))
+
+		for _, pc := range op.Preconditions {
+			fmt.Fprintf(&b, "   Requires: %s\n", pc)
+		}
+
+		for _, revert := range op.Revert {
+			fmt.Fprintf(&b, "   Revert: %s\n", revert)
+		}
+	}
+
+	return b.String()
+}
+
+// markdown renders a table of operations.
+func (d *Description) markdown() string {
+	var b strings.Builder
+
+	if summary := d.summary(); summary != "" {
+		fmt.Fprintf(&b, "%s\n\n", summary)
+	}
+
+	b.WriteString("| # | Change | Revert |\n")
+	b.WriteString("|---|--------|--------|\n")
+
+	for _, op := range d.Operations {
+		fmt.Fprintf(&b, "| %d | %s | %s |\n", op.Number, markdownCell(op.Apply), markdownCell(op.Revert))
+	}
+
+	return b.String()
+}
+
+// summary describes where the plan came from, if known.
+func (d *Description) summary() string {
+	meta := d.Metadata
+	if meta == nil {
+		return ""
+	}
+
+	parts := []string{"Plan generated by '" + strings.Join(append([]string{meta.Generator}, meta.Args...), " ") + "'"}
+
+	if meta.ServerURL != "" {
+		server := meta.ServerURL
+		if meta.ServerVersion != "" {
+			server += " (" + meta.ServerVersion + ")"
+		}
+
+		parts = append(parts, "against "+server)
+	}
+
+	if meta.User != "" {
+		parts = append(parts, "as "+meta.User)
+	}
+
+	if !meta.CreatedAt.IsZero() {
+		parts = append(parts, "at "+meta.CreatedAt.Format(time.RFC3339))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// markdownCell joins sentences into a single table cell.
+func markdownCell(sentences []string) string {
+	if len(sentences) == 0 {
+		return "-"
+	}
+
+	return strings.ReplaceAll(strings.Join(sentences, "<br>"), "|", `\|`)
+}
@@ -0,0 +1,129 @@
+package immich
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError is returned by Client.Do when the Immich API responds with a
+// non-2xx status code.
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	// Message is the error message decoded from the Immich response body.
+	// Validation errors listing several messages are joined with "; ".
+	Message string
+	// CorrelationID identifies the request in the Immich server logs.
+	CorrelationID string
+	RequestBody   []byte
+	ResponseBody  []byte
+}
+
+// errorResponse is the JSON body Immich sends with error responses.
+type errorResponse struct {
+	Message       json.RawMessage `json:"message"`
+	CorrelationID string          `json:"correlationId"`
+}
+
+// newAPIError builds an APIError from a failed response.
+func newAPIError(req *http.Request, resp *http.Response, requestBody, responseBody []byte) *APIError {
+	apiErr := &APIError{
+		Method:       req.Method,
+		URL:          req.URL.String(),
+		StatusCode:   resp.StatusCode,
+		Status:       resp.Status,
+		RequestBody:  requestBody,
+		ResponseBody: responseBody,
+	}
+
+	var body errorResponse
+	if err := json.Unmarshal(responseBody, &body); err != nil {
+		return apiErr
+	}
+
+	apiErr.CorrelationID = body.CorrelationID
+
+	// The message is a string, or a list of strings for validation errors
+	var message string
+
+	var messages []string
+
+	switch {
+	case json.Unmarshal(body.Message, &message) == nil:
+		apiErr.Message = message
+	case json.Unmarshal(body.Message, &messages) == nil:
+		apiErr.Message = strings.Join(messages, "; ")
+	}
+
+	return apiErr
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	requestBody := "<no body>"
+	if len(e.RequestBody) > 0 {
+		requestBody = string(e.RequestBody)
+	}
+
+	return fmt.Sprintf("API error: %s %s\nStatus: %d %s\nRequest body: %s\nResponse body: %s",
+		e.Method, e.URL,
+		e.StatusCode, e.Status,
+		requestBody,
+		string(e.ResponseBody))
+}
+
+// Summary returns a single line description of the error suitable for
+// reports and logs.
+func (e *APIError) Summary() string {
+	summary := fmt.Sprintf("%s %s: %d", e.Method, e.URL, e.StatusCode)
+	if e.Message != "" {
+		summary += " " + e.Message
+	}
+
+	if e.CorrelationID != "" {
+		summary += " (correlation ID " + e.CorrelationID + ")"
+	}
+
+	return summary
+}
+
+// StatusCode returns the HTTP status code of err if it is or wraps an
+// APIError, and zero otherwise.
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+
+	return 0
+}
+
+// IsBadRequest reports whether err is an API error with status 400.
+func IsBadRequest(err error) bool {
+	return StatusCode(err) == http.StatusBadRequest
+}
+
+// IsUnauthorized reports whether err is an API error with status 401.
+func IsUnauthorized(err error) bool {
+	return StatusCode(err) == http.StatusUnauthorized
+}
+
+// IsForbidden reports whether err is an API error with status 403.
+func IsForbidden(err error) bool {
+	return StatusCode(err) == http.StatusForbidden
+}
+
+// IsNotFound reports whether err is an API error with status 404.
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsConflict reports whether err is an API error with status 409.
+func IsConflict(err error) bool {
+	return StatusCode(err) == http.StatusConflict
+}
@@ -0,0 +1,114 @@
+package immich
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Do_APIError(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		statusCode    int
+		responseBody  string
+		wantMessage   string
+		wantCorrID    string
+		wantNotFound  bool
+		wantForbidden bool
+	}{
+		{
+			name:         "not found with message",
+			statusCode:   http.StatusNotFound,
+			responseBody: `{"message":"Album not found","error":"Not Found","statusCode":404,"correlationId":"abc123"}`,
+			wantMessage:  "Album not found",
+			wantCorrID:   "abc123",
+			wantNotFound: true,
+		},
+		{
+			name:          "forbidden",
+			statusCode:    http.StatusForbidden,
+			responseBody:  `{"message":"Not found or no album.share access","statusCode":403}`,
+			wantMessage:   "Not found or no album.share access",
+			wantForbidden: true,
+		},
+		{
+			name:         "validation errors are joined",
+			statusCode:   http.StatusBadRequest,
+			responseBody: `{"message":["id must be a UUID","role must be one of viewer, editor"],"statusCode":400}`,
+			wantMessage:  "id must be a UUID; role must be one of viewer, editor",
+		},
+		{
+			name:         "non JSON body",
+			statusCode:   http.StatusBadGateway,
+			responseBody: `<html>Bad Gateway</html>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tc.statusCode)
+				_, _ = w.Write([]byte(tc.responseBody))
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL, "test-token")
+			client.SetRetryPolicy(RetryPolicy{})
+
+			req, err := client.NewRequest(http.MethodGet, "/api/albums/album-1", nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			// Wrap the error as callers do to check errors.As still works
+			err = fmt.Errorf("getting album: %w", client.Do(req, nil))
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Expected an APIError, got %T", err)
+			}
+
+			if apiErr.StatusCode != tc.statusCode {
+				t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, tc.statusCode)
+			}
+
+			if apiErr.Method != http.MethodGet || apiErr.URL != server.URL+"/api/albums/album-1" {
+				t.Errorf("Unexpected request details %s %s", apiErr.Method, apiErr.URL)
+			}
+
+			if apiErr.Message != tc.wantMessage {
+				t.Errorf("Message = %q, want %q", apiErr.Message, tc.wantMessage)
+			}
+
+			if apiErr.CorrelationID != tc.wantCorrID {
+				t.Errorf("CorrelationID = %q, want %q", apiErr.CorrelationID, tc.wantCorrID)
+			}
+
+			if string(apiErr.ResponseBody) != tc.responseBody {
+				t.Errorf("ResponseBody = %q, want %q", apiErr.ResponseBody, tc.responseBody)
+			}
+
+			if IsNotFound(err) != tc.wantNotFound {
+				t.Errorf("IsNotFound() = %v, want %v", IsNotFound(err), tc.wantNotFound)
+			}
+
+			if IsForbidden(err) != tc.wantForbidden {
+				t.Errorf("IsForbidden() = %v, want %v", IsForbidden(err), tc.wantForbidden)
+			}
+
+			if StatusCode(err) != tc.statusCode {
+				t.Errorf("StatusCode() = %d, want %d", StatusCode(err), tc.statusCode)
+			}
+		})
+	}
+
+	if StatusCode(errors.New("plain error")) != 0 {
+		t.Error("Expected StatusCode of a non API error to be zero")
+	}
+}
@@ -0,0 +1,127 @@
+package immich
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"immich-manager/pkg/plan"
+)
+
+// ParseRequest recognises the album mutations built by AlbumsService and
+// returns the intents they carry out. A request that shares an album with
+// several users yields one intent per user. It reports false for requests
+// it does not understand.
+func ParseRequest(req plan.Request) ([]plan.Intent, bool) {
+	path, _, _ := strings.Cut(req.Path, "?")
+
+	rest, ok := strings.CutPrefix(path, "/api/albums/")
+	if !ok {
+		return nil, false
+	}
+
+	segments := strings.Split(rest, "/")
+	albumID := segments[0]
+
+	if albumID == "" {
+		return nil, false
+	}
+
+	switch {
+	case len(segments) == 1 && req.Method == http.MethodPatch:
+		var body AlbumUpdate
+		if !decodeBody(req.Body, &body) || body.Name == "" {
+			return nil, false
+		}
+
+		return []plan.Intent{{Kind: plan.IntentAlbumRename, AlbumID: albumID, Name: body.Name}}, true
+
+	case len(segments) == 2 && segments[1] == "users" && req.Method == http.MethodPut:
+		var body addUsersBody
+		if !decodeBody(req.Body, &body) || len(body.AlbumUsers) == 0 {
+			return nil, false
+		}
+
+		intents := make([]plan.Intent, 0, len(body.AlbumUsers))
+		for _, user := range body.AlbumUsers {
+			intents = append(intents, plan.Intent{
+				Kind: plan.IntentAlbumAddUser, AlbumID: albumID, UserID: user.UserID, Role: user.Role,
+			})
+		}
+
+		return intents, true
+
+	case len(segments) == 3 && segments[1] == "user" && req.Method == http.MethodDelete:
+		return []plan.Intent{{Kind: plan.IntentAlbumRemoveUser, AlbumID: albumID, UserID: segments[2]}}, true
+
+	case len(segments) == 2 && segments[1] == "assets":
+		var body assetIDsBody
+		if !decodeBody(req.Body, &body) {
+			return nil, false
+		}
+
+		switch req.Method {
+		case http.MethodPut:
+			return []plan.Intent{{Kind: plan.IntentAlbumAddAssets, AlbumID: albumID, AssetIDs: body.IDs}}, true
+		case http.MethodDelete:
+			return []plan.Intent{{Kind: plan.IntentAlbumRemoveAssets, AlbumID: albumID, AssetIDs: body.IDs}}, true
+		}
+	}
+
+	return nil, false
+}
+
+// decodeBody decodes a JSON request body into v, reporting success.
+func decodeBody(body json.RawMessage, v any) bool {
+	if len(body) == 0 {
+		return false
+	}
+
+	return json.Unmarshal(body, v) == nil
+}
+
+// OperationAlbums returns the IDs of the albums an operation reads or
+// changes, taken from its preconditions, intents and request paths, in
+// order of first appearance.
+func OperationAlbums(op plan.Operation) []string {
+	seen := make(map[string]bool)
+
+	var ids []string
+
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, pc := range op.Preconditions {
+		add(pc.AlbumID)
+	}
+
+	for _, intent := range op.Intents {
+		add(intent.AlbumID)
+	}
+
+	for _, reqs := range [][]plan.Request{op.Apply, op.Revert} {
+		for _, req := range reqs {
+			add(AlbumID(req.Path))
+		}
+	}
+
+	return ids
+}
+
+// AlbumID returns the album ID in an album request path, if any.
+func AlbumID(path string) string {
+	for _, prefix := range []string{"/api/albums/", "/api/album/"} {
+		if rest, ok := strings.CutPrefix(path, prefix); ok {
+			id, _, _ := strings.Cut(rest, "/")
+			id, _, _ = strings.Cut(id, "?")
+
+			return id
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,69 @@
+package immich
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"immich-manager/pkg/plan"
+)
+
+func TestParseRequest(t *testing.T) {
+	t.Parallel()
+
+	albums := &AlbumsService{}
+
+	rename, _ := albums.UpdateRequest("a1", AlbumUpdate{Name: "Italy"})
+	addUser, _ := albums.AddUsersRequest("a1", AlbumUserAddition{Role: RoleEditor, UserID: "u1"})
+	removeUser, _ := albums.RemoveUserRequest("a1", "u1")
+	addAssets, _ := albums.AddAssetsRequest("a1", []string{"x", "y"})
+	removeAssets, _ := albums.RemoveAssetsRequest("a1", []string{"x"})
+
+	tests := []struct {
+		name string
+		req  plan.Request
+		want []plan.Intent
+	}{
+		{"rename", rename, []plan.Intent{{Kind: plan.IntentAlbumRename, AlbumID: "a1", Name: "Italy"}}},
+		{"add user", addUser, []plan.Intent{{Kind: plan.IntentAlbumAddUser, AlbumID: "a1", UserID: "u1", Role: RoleEditor}}},
+		{"remove user", removeUser, []plan.Intent{{Kind: plan.IntentAlbumRemoveUser, AlbumID: "a1", UserID: "u1"}}},
+		{"add assets", addAssets, []plan.Intent{{Kind: plan.IntentAlbumAddAssets, AlbumID: "a1", AssetIDs: []string{"x", "y"}}}},
+		{"remove assets", removeAssets, []plan.Intent{{Kind: plan.IntentAlbumRemoveAssets, AlbumID: "a1", AssetIDs: []string{"x"}}}},
+		{"unknown", plan.Request{Method: http.MethodPost, Path: "/api/albums"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := ParseRequest(tt.req)
+			if ok != (tt.want != nil) {
+				t.Fatalf("ParseRequest() ok = %v", ok)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRequest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlbumID(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"/api/albums/abc":                 "abc",
+		"/api/albums/abc/assets":          "abc",
+		"/api/albums/abc/user/def":        "abc",
+		"/api/album/abc/users":            "abc",
+		"/api/albums/abc?withoutAssets=1": "abc",
+		"/api/albums":                     "",
+		"/api/users/me":                   "",
+	}
+
+	for path, want := range tests {
+		if got := AlbumID(path); got != want {
+			t.Errorf("AlbumID(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
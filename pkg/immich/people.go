@@ -0,0 +1,24 @@
+package immich
+
+import (
+	"context"
+	"fmt"
+)
+
+// PeopleService provides access to the people endpoints.
+type PeopleService struct {
+	client *Client
+}
+
+// List returns the people recognised in the authenticated user's library.
+func (s *PeopleService) List(ctx context.Context) ([]Person, error) {
+	var response struct {
+		People []Person `json:"people"`
+	}
+
+	if err := s.client.get(ctx, "/api/people", &response); err != nil {
+		return nil, fmt.Errorf("listing people: %w", err)
+	}
+
+	return response.People, nil
+}
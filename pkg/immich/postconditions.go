@@ -0,0 +1,91 @@
+package immich
+
+import (
+	"context"
+	"fmt"
+
+	"immich-manager/pkg/plan"
+)
+
+// CheckIntent verifies that the change an intent describes is in effect on
+// the server. It returns an error wrapping plan.ErrNotConverged when it is
+// not, and other errors when the state could not be determined.
+func (c *Client) CheckIntent(ctx context.Context, intent plan.Intent) error {
+	var (
+		album *Album
+		err   error
+	)
+
+	switch intent.Kind {
+	case plan.IntentAlbumAddAssets, plan.IntentAlbumRemoveAssets:
+		album, err = c.Albums.GetWithAssets(ctx, intent.AlbumID)
+	case plan.IntentAlbumRename, plan.IntentAlbumAddUser, plan.IntentAlbumRemoveUser:
+		album, err = c.Albums.Get(ctx, intent.AlbumID)
+	default:
+		return fmt.Errorf("unknown intent kind %q", intent.Kind)
+	}
+
+	if err != nil {
+		// Immich answers 400 for albums that are missing or not accessible
+		if IsNotFound(err) || IsBadRequest(err) || IsForbidden(err) {
+			return fmt.Errorf("%w: album %s does not exist or is not accessible", plan.ErrNotConverged, intent.AlbumID)
+		}
+
+		return err
+	}
+
+	switch intent.Kind {
+	case plan.IntentAlbumRename:
+		if album.Name != intent.Name {
+			return fmt.Errorf("%w: album %s is named '%s', expected '%s'",
+				plan.ErrNotConverged, intent.AlbumID, album.Name, intent.Name)
+		}
+	case plan.IntentAlbumAddUser:
+		member, ok := album.Member(intent.UserID)
+		if !ok {
+			return fmt.Errorf("%w: user %s is not a member of album '%s'", plan.ErrNotConverged, intent.UserID, album.Name)
+		}
+
+		if intent.Role != "" && member.Role != intent.Role {
+			return fmt.Errorf("%w: user %s is %s of album '%s', expected %s",
+				plan.ErrNotConverged, intent.UserID, member.Role, album.Name, intent.Role)
+		}
+	case plan.IntentAlbumRemoveUser:
+		if _, ok := album.Member(intent.UserID); ok {
+			return fmt.Errorf("%w: user %s is still a member of album '%s'", plan.ErrNotConverged, intent.UserID, album.Name)
+		}
+	case plan.IntentAlbumAddAssets, plan.IntentAlbumRemoveAssets:
+		return checkAssets(album, intent)
+	}
+
+	return nil
+}
+
+// checkAssets verifies that the assets of an asset intent were added to or
+// removed from album.
+func checkAssets(album *Album, intent plan.Intent) error {
+	present := make(map[string]bool, len(album.Assets))
+	for _, asset := range album.Assets {
+		present[asset.ID] = true
+	}
+
+	want := intent.Kind == plan.IntentAlbumAddAssets
+	wrong := 0
+
+	for _, id := range intent.AssetIDs {
+		if present[id] != want {
+			wrong++
+		}
+	}
+
+	switch {
+	case wrong == 0:
+		return nil
+	case want:
+		return fmt.Errorf("%w: %d of %d assets are missing from album '%s'",
+			plan.ErrNotConverged, wrong, len(intent.AssetIDs), album.Name)
+	default:
+		return fmt.Errorf("%w: %d of %d assets are still in album '%s'",
+			plan.ErrNotConverged, wrong, len(intent.AssetIDs), album.Name)
+	}
+}
@@ -0,0 +1,48 @@
+package immich
+
+import (
+	"context"
+	"fmt"
+
+	"immich-manager/pkg/plan"
+)
+
+// CheckPrecondition verifies a plan precondition against the server. It
+// returns an error wrapping plan.ErrDrift when the precondition no longer
+// holds, and other errors when the state could not be determined.
+func (c *Client) CheckPrecondition(ctx context.Context, pc plan.Precondition) error {
+	switch pc.Kind {
+	case plan.PreconditionAlbumExists, plan.PreconditionAlbumNamed,
+		plan.PreconditionAlbumMember, plan.PreconditionAlbumNotMember:
+	default:
+		return fmt.Errorf("unknown precondition kind %q", pc.Kind)
+	}
+
+	album, err := c.Albums.Get(ctx, pc.AlbumID)
+	if err != nil {
+		// Immich answers 400 for albums that are missing or not accessible
+		if IsNotFound(err) || IsBadRequest(err) || IsForbidden(err) {
+			return fmt.Errorf("%w: album %s no longer exists or is not accessible", plan.ErrDrift, pc.AlbumID)
+		}
+
+		return err
+	}
+
+	switch pc.Kind {
+	case plan.PreconditionAlbumNamed:
+		if album.Name != pc.Name {
+			return fmt.Errorf("%w: album %s is named '%s', expected '%s'", plan.ErrDrift, pc.AlbumID, album.Name, pc.Name)
+		}
+	case plan.PreconditionAlbumMember:
+		if _, ok := album.Member(pc.UserID); !ok {
+			return fmt.Errorf("%w: user %s is no longer a member of album '%s'", plan.ErrDrift, pc.UserID, album.Name)
+		}
+	case plan.PreconditionAlbumNotMember:
+		if _, ok := album.Member(pc.UserID); ok {
+			return fmt.Errorf("%w: user %s is already a member of album '%s'", plan.ErrDrift, pc.UserID, album.Name)
+		}
+	case plan.PreconditionAlbumExists:
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Format is an output format for a Report.
+type Format string
+
+const (
+	// FormatJSON renders the Report as JSON.
+	FormatJSON Format = "json"
+	// FormatJUnit renders one JUnit test case per request so that CI
+	// dashboards show failures.
+	FormatJUnit Format = "junit"
+	// FormatMarkdown renders a table suitable for job summaries.
+	FormatMarkdown Format = "markdown"
+)
+
+// ParseFormat parses an output format name.
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case FormatJSON, FormatJUnit, FormatMarkdown:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown report format %q: must be one of json, junit or markdown", s)
+	}
+}
+
+// FormatFor picks a format from the extension of a report file: JUnit for
+// .xml, Markdown for .md and JSON otherwise.
+func FormatFor(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml":
+		return FormatJUnit
+	case ".md", ".markdown":
+		return FormatMarkdown
+	default:
+		return FormatJSON
+	}
+}
+
+// Render writes the report to w in the given format.
+func (r *Report) Render(w io.Writer, format Format) error {
+	var err error
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(r)
+	case FormatJUnit:
+		if _, err = io.WriteString(w, xml.Header); err == nil {
+			encoder := xml.NewEncoder(w)
+			encoder.Indent("", "  ")
+			err = encoder.Encode(r.junit())
+		}
+
+		if err == nil {
+			_, err = io.WriteString(w, "\n")
+		}
+	case FormatMarkdown:
+		_, err = io.WriteString(w, r.markdown())
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+
+	if err != nil {
+		return fmt.Errorf("rendering report: %w", err)
+	}
+
+	return nil
+}
+
+// junitSuites is the root element of a JUnit XML report.
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+// junitSuite holds the test cases of a run.
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr,omitempty"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+// junitCase is a request, or an operation that sent no failing request.
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+// junitMessage describes a failed or skipped test case.
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junit converts the report to JUnit test cases. Each request is a test
+// case. Operations that were skipped, or failed without a failing request,
+// get a test case of their own.
+func (r *Report) junit() junitSuites {
+	suite := junitSuite{
+		Name: "immich-manager " + string(r.Action),
+		Time: seconds(r.DurationMS),
+	}
+
+	if !r.Started.IsZero() {
+		suite.Timestamp = r.Started.Format(time.RFC3339)
+	}
+
+	for _, op := range r.Operations {
+		classname := fmt.Sprintf("%s.operation %d", r.Action, op.Operation+1)
+		requestFailed := false
+
+		for _, req := range op.Requests {
+			c := junitCase{
+				Name:      fmt.Sprintf("%d. %s %s", req.Request+1, req.Method, req.Path),
+				Classname: classname,
+				Time:      seconds(req.DurationMS),
+			}
+
+			if req.Error != "" {
+				requestFailed = true
+				c.Failure = &junitMessage{Message: firstLine(req.Error), Text: req.Error}
+			}
+
+			suite.Cases = append(suite.Cases, c)
+		}
+
+		c := junitCase{Name: fmt.Sprintf("operation %d", op.Operation+1), Classname: classname, Time: seconds(op.DurationMS)}
+
+		switch {
+		case op.Status == StatusSkipped:
+			c.Skipped = &junitMessage{Message: "skipped by the drift policy"}
+		case op.Status == StatusFailed && !requestFailed:
+			c.Failure = &junitMessage{Message: firstLine(op.Error), Text: op.Error}
+		default:
+			continue
+		}
+
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	for _, c := range suite.Cases {
+		suite.Tests++
+
+		if c.Failure != nil {
+			suite.Failures++
+		}
+
+		if c.Skipped != nil {
+			suite.Skipped++
+		}
+	}
+
+	return junitSuites{Suites: []junitSuite{suite}}
+}
+
+// markdown renders a summary followed by one table row per request.
+func (r *Report) markdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Plan %s report\n\n", r.Action)
+	fmt.Fprintf(&b, "%d operations: %d succeeded, %d failed, %d skipped",
+		r.Total, r.Succeeded, r.Failed, r.Skipped)
+
+	if !r.Started.IsZero() {
+		fmt.Fprintf(&b, " (started %s, took %s)", r.Started.Format(time.RFC3339), duration(r.DurationMS))
+	}
+
+	b.WriteString(".\n")
+
+	if r.Error != "" {
+		fmt.Fprintf(&b, "\n**Error:** %s\n", markdownCell(r.Error))
+	}
+
+	b.WriteString("\n| Operation | Outcome | Request | Status | Duration | Error |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+
+	for _, op := range r.Operations {
+		if len(op.Requests) == 0 {
+			fmt.Fprintf(&b, "| %d | %s | - | - | %s | %s |\n",
+				op.Operation+1, op.Status, duration(op.DurationMS), markdownCell(op.Error))
+
+			continue
+		}
+
+		for _, req := range op.Requests {
+			status := "-"
+			if req.Status != 0 {
+				status = fmt.Sprint(req.Status)
+			}
+
+			fmt.Fprintf(&b, "| %d | %s | `%s %s` | %s | %s | %s |\n",
+				op.Operation+1, op.Status, req.Method, req.Path, status, duration(req.DurationMS),
+				markdownCell(req.Error))
+		}
+	}
+
+	return b.String()
+}
+
+// markdownCell makes text safe to use in a table cell.
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", "\\|")
+
+	return strings.ReplaceAll(strings.TrimSpace(text), "\n", "<br>")
+}
+
+// firstLine returns the first line of text.
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+
+	return line
+}
+
+// seconds formats milliseconds as seconds for JUnit.
+func seconds(ms float64) string {
+	return fmt.Sprintf("%.3f", ms/1000)
+}
+
+// duration formats milliseconds for people.
+func duration(ms float64) string {
+	return time.Duration(ms * float64(time.Millisecond)).Round(time.Millisecond).String()
+}
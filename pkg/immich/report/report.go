@@ -0,0 +1,139 @@
+// Package report records every request sent while a plan is applied or
+// reverted, and renders the record as JSON, JUnit XML or Markdown.
+package report
+
+import (
+	"time"
+
+	"immich-manager/pkg/immich/applier"
+	"immich-manager/pkg/plan"
+)
+
+// Status is the outcome of an operation.
+type Status string
+
+const (
+	// StatusStarted marks an operation that had not finished when the
+	// run ended.
+	StatusStarted Status = "started"
+	// StatusSucceeded marks an operation whose requests all completed.
+	StatusSucceeded Status = "succeeded"
+	// StatusFailed marks an operation that failed.
+	StatusFailed Status = "failed"
+	// StatusSkipped marks an operation skipped by the drift policy.
+	StatusSkipped Status = "skipped"
+	// StatusRolledBack marks an operation undone by an atomic rollback.
+	StatusRolledBack Status = "rolledBack"
+)
+
+// Request is a request sent for an operation.
+type Request struct {
+	// Request is the index of the request within the operation.
+	Request    int     `json:"request"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status,omitempty"`
+	DurationMS float64 `json:"durationMs"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Operation is the outcome of an operation and the requests sent for it,
+// including any sent to roll it back.
+type Operation struct {
+	// Operation is the index of the operation in the plan.
+	Operation  int       `json:"operation"`
+	Status     Status    `json:"status"`
+	DurationMS float64   `json:"durationMs"`
+	Error      string    `json:"error,omitempty"`
+	Requests   []Request `json:"requests"`
+}
+
+// Report is the record of a run. It is an applier.Observer that is filled
+// in from the events of the run.
+type Report struct {
+	Action     plan.Action  `json:"action"`
+	Started    time.Time    `json:"started"`
+	Finished   time.Time    `json:"finished"`
+	DurationMS float64      `json:"durationMs"`
+	Error      string       `json:"error,omitempty"`
+	Total      int          `json:"total"`
+	Succeeded  int          `json:"succeeded"`
+	Failed     int          `json:"failed"`
+	Skipped    int          `json:"skipped"`
+	Operations []*Operation `json:"operations"`
+
+	// byIndex finds operations by their index in the plan.
+	byIndex map[int]*Operation
+}
+
+// New returns an empty report.
+func New() *Report {
+	return &Report{
+		Operations: []*Operation{},
+		byIndex:    make(map[int]*Operation),
+	}
+}
+
+// Observe implements applier.Observer.
+func (r *Report) Observe(event applier.Event) {
+	r.Total = event.Total
+	r.Succeeded = event.Succeeded
+	r.Failed = event.Failed
+	r.Skipped = event.Skipped
+
+	switch event.Type {
+	case applier.EventStarted:
+		r.Action = event.Action
+		r.Started = event.Time
+	case applier.EventFinished:
+		r.Finished = event.Time
+		r.DurationMS = milliseconds(event.Duration)
+		r.Error = event.Error
+	case applier.EventOperationStarted:
+		r.operation(event.Operation)
+	case applier.EventRequest:
+		op := r.operation(event.Operation)
+		op.Requests = append(op.Requests, Request{
+			Request:    event.Request,
+			Method:     event.Method,
+			Path:       event.Path,
+			Status:     event.Status,
+			DurationMS: milliseconds(event.Duration),
+			Error:      event.Error,
+		})
+	case applier.EventOperationSucceeded:
+		r.finish(event, StatusSucceeded)
+	case applier.EventOperationFailed:
+		r.finish(event, StatusFailed)
+	case applier.EventOperationSkipped:
+		r.finish(event, StatusSkipped)
+	case applier.EventOperationRolledBack:
+		r.operation(event.Operation).Status = StatusRolledBack
+	}
+}
+
+// operation returns the entry for operation i, adding it if needed.
+func (r *Report) operation(i int) *Operation {
+	if op, ok := r.byIndex[i]; ok {
+		return op
+	}
+
+	op := &Operation{Operation: i, Status: StatusStarted, Requests: []Request{}}
+	r.byIndex[i] = op
+	r.Operations = append(r.Operations, op)
+
+	return op
+}
+
+// finish records the outcome of an operation.
+func (r *Report) finish(event applier.Event, status Status) {
+	op := r.operation(event.Operation)
+	op.Status = status
+	op.DurationMS = milliseconds(event.Duration)
+	op.Error = event.Error
+}
+
+// milliseconds converts d to fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,153 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"immich-manager/pkg/immich"
+	"immich-manager/pkg/immich/applier"
+	"immich-manager/pkg/plan"
+)
+
+// runReport applies a plan of three renames, the second of which fails,
+// and returns the report of the run.
+func runReport(t *testing.T) *Report {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/albums/2" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"message":"Not found or no album.update access"}`))
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p := &plan.Plan{}
+	for _, id := range []string{"1", "2", "3"} {
+		p.Operations = append(p.Operations, plan.Operation{
+			Apply:  []plan.Request{{Path: "/api/albums/" + id, Method: http.MethodPatch}},
+			Revert: []plan.Request{{Path: "/api/albums/" + id, Method: http.MethodPatch}},
+		})
+	}
+
+	record := New()
+	opts := &applier.ApplyOptions{Observer: record, ContinueOnError: true}
+
+	if err := applier.NewApplier(immich.NewClient(server.URL, "test-token")).Apply(p, opts); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	return record
+}
+
+func TestReport_JSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := runReport(t).Render(&buf, FormatJSON); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+
+	if decoded.Action != plan.ActionApply || decoded.Total != 3 || decoded.Succeeded != 2 || decoded.Failed != 1 {
+		t.Errorf("Unexpected summary %+v", decoded)
+	}
+
+	if len(decoded.Operations) != 3 {
+		t.Fatalf("Expected 3 operations, got %d", len(decoded.Operations))
+	}
+
+	failed := decoded.Operations[1]
+	if failed.Operation != 1 || failed.Status != StatusFailed || len(failed.Requests) != 1 {
+		t.Fatalf("Unexpected failed operation %+v", failed)
+	}
+
+	req := failed.Requests[0]
+	if req.Status != http.StatusBadRequest || req.Method != http.MethodPatch || req.Error == "" {
+		t.Errorf("Unexpected failed request %+v", req)
+	}
+
+	if ok := decoded.Operations[0].Requests[0]; ok.Status != http.StatusOK || ok.Error != "" {
+		t.Errorf("Unexpected successful request %+v", ok)
+	}
+}
+
+func TestReport_JUnit(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := runReport(t).Render(&buf, FormatJUnit); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	var suites junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("Failed to decode JUnit report: %v\n%s", err, buf.String())
+	}
+
+	suite := suites.Suites[0]
+	if suite.Name != "immich-manager apply" || suite.Tests != 3 || suite.Failures != 1 {
+		t.Errorf("Unexpected suite %+v", suite)
+	}
+
+	failure := suite.Cases[1].Failure
+	if failure == nil || !strings.Contains(failure.Message, "operation 1") {
+		t.Errorf("Expected the second request to fail, got %+v", suite.Cases[1])
+	}
+}
+
+func TestReport_Markdown(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := runReport(t).Render(&buf, FormatMarkdown); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	got := buf.String()
+
+	for _, want := range []string{
+		"# Plan apply report",
+		"3 operations: 2 succeeded, 1 failed, 0 skipped",
+		"| 1 | succeeded | `PATCH /api/albums/1` | 200 |",
+		"| 2 | failed | `PATCH /api/albums/2` | 400 |",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected markdown to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]Format{
+		"report.xml":  FormatJUnit,
+		"report.md":   FormatMarkdown,
+		"report.json": FormatJSON,
+		"report":      FormatJSON,
+	}
+
+	for path, want := range tests {
+		if got := FormatFor(path); got != want {
+			t.Errorf("FormatFor(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	if _, err := ParseFormat("html"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,226 @@
+package immich
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how the client retries requests that fail with a
+// transient error.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt. Zero
+	// disables retries.
+	MaxRetries int
+	// InitialBackoff is the wait before the first retry. It doubles on
+	// every further retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponential backoff.
+	MaxBackoff time.Duration
+	// MaxRetryAfter caps the wait a Retry-After header sent by the server
+	// asks for. Zero caps it at MaxBackoff.
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used by new clients.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:     3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		MaxRetryAfter:  2 * time.Minute,
+	}
+}
+
+// backoff returns the jittered wait before the given retry, counting from
+// zero. The wait is picked uniformly between half and all of the
+// exponential delay so that concurrent clients spread out.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.InitialBackoff
+	for range retry {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			delay = p.MaxBackoff
+
+			break
+		}
+	}
+
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+
+	//nolint: gosec
+	return half + rand.N(delay-half+1)
+}
+
+// retryAfterLimit returns the longest wait honoured from a Retry-After
+// header.
+func (p RetryPolicy) retryAfterLimit() time.Duration {
+	if p.MaxRetryAfter > 0 {
+		return p.MaxRetryAfter
+	}
+
+	return p.MaxBackoff
+}
+
+// idempotentMethods lists the methods that can safely be sent twice.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// addUsersRoute matches the route that shares an album with users. It is
+// sent with PUT, but the server rejects users the album is already shared
+// with, so a repeat of a request whose reply was lost fails.
+var addUsersRoute = regexp.MustCompile(`/albums?/[^/]+/users$`)
+
+// idempotent reports whether req can safely be sent twice.
+func idempotent(req *http.Request) bool {
+	if req.Method == http.MethodPut && addUsersRoute.MatchString(req.URL.Path) {
+		return false
+	}
+
+	return idempotentMethods[req.Method]
+}
+
+// retryableStatus reports whether a response status is worth retrying for
+// req. Only requests that are harmless to repeat are retried: a 429
+// usually means the request was not processed, but a proxy in front of the
+// server may send one after the server has acted on it.
+func retryableStatus(req *http.Request, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusRequestTimeout,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent(req)
+	default:
+		return false
+	}
+}
+
+// retryAfter parses the Retry-After header, which may hold either a number
+// of seconds or an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+
+	return 0, false
+}
+
+// doWithRetry sends req, retrying transient failures according to the
+// client's retry policy, and returns the final response with its body
+// already read.
+func (c *Client) doWithRetry(req *http.Request, body []byte) (*http.Response, []byte, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		canRetry := attempt < c.retry.MaxRetries
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			if canRetry && idempotent(req) && req.Context().Err() == nil {
+				if err := c.wait(req, c.retry.backoff(attempt)); err != nil {
+					return nil, nil, err
+				}
+
+				continue
+			}
+
+			return nil, nil, fmt.Errorf("performing request: %w", err)
+		}
+
+		// Read the entire response body
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading response body: %w", err)
+		}
+
+		err = resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("closing response body: %w", err)
+		}
+
+		if canRetry && retryableStatus(req, resp.StatusCode) {
+			delay, ok := retryAfter(resp)
+			if ok {
+				delay = min(delay, c.retry.retryAfterLimit())
+			} else {
+				delay = c.retry.backoff(attempt)
+			}
+
+			if err := c.wait(req, delay); err != nil {
+				return nil, nil, err
+			}
+
+			continue
+		}
+
+		return resp, respBody, nil
+	}
+}
+
+// retryStopKey is the context key of the context that stops retries, set
+// by WithRetryStop.
+type retryStopKey struct{}
+
+// WithRetryStop returns a copy of ctx whose requests are no longer retried
+// once stop is done: waiting to retry ends early with the error of stop.
+// Requests that must not be cancelled while in flight, as those of an
+// operation being applied, can so still be interrupted between attempts.
+func WithRetryStop(ctx, stop context.Context) context.Context {
+	return context.WithValue(ctx, retryStopKey{}, stop)
+}
+
+// wait sleeps before a retry, returning early if the request is cancelled
+// or its retries are stopped.
+func (*Client) wait(req *http.Request, delay time.Duration) error {
+	var stopped <-chan struct{}
+
+	stop, ok := req.Context().Value(retryStopKey{}).(context.Context)
+	if ok {
+		if err := stop.Err(); err != nil {
+			return fmt.Errorf("waiting to retry request: %w", err)
+		}
+
+		stopped = stop.Done()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return fmt.Errorf("waiting to retry request: %w", req.Context().Err())
+	case <-stopped:
+		return fmt.Errorf("waiting to retry request: %w", stop.Err())
+	}
+}
@@ -0,0 +1,361 @@
+package immich
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func fastRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:     3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		MaxRetryAfter:  2 * time.Second,
+	}
+}
+
+func TestClient_Do_Retry(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		method       string
+		statuses     []int
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{
+			name:         "502 on GET is retried",
+			method:       http.MethodGet,
+			statuses:     []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusOK},
+			wantAttempts: 3,
+		},
+		{
+			name:         "429 on PUT is retried",
+			method:       http.MethodPut,
+			statuses:     []int{http.StatusTooManyRequests, http.StatusOK},
+			wantAttempts: 2,
+		},
+		{
+			name:         "429 on POST is not retried",
+			method:       http.MethodPost,
+			statuses:     []int{http.StatusTooManyRequests, http.StatusOK},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "503 on PATCH is not retried",
+			method:       http.MethodPatch,
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusOK},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "400 is not retried",
+			method:       http.MethodPut,
+			statuses:     []int{http.StatusBadRequest, http.StatusOK},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:   "gives up after max retries",
+			method: http.MethodDelete,
+			statuses: []int{
+				http.StatusGatewayTimeout, http.StatusGatewayTimeout,
+				http.StatusGatewayTimeout, http.StatusGatewayTimeout,
+				http.StatusOK,
+			},
+			wantAttempts: 4,
+			wantErr:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var attempts atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+
+				// Every attempt must carry the full request body
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != `{"ids":["a"]}` {
+					t.Errorf("Attempt %d got body %q", n, body)
+				}
+
+				w.WriteHeader(tc.statuses[n-1])
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL, "test-token")
+			client.SetRetryPolicy(fastRetryPolicy())
+
+			req, err := client.NewRequest(tc.method, "/api/albums/1/assets", map[string][]string{"ids": {"a"}})
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			err = client.Do(req, nil)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Do() error = %v, wantErr %v", err, tc.wantErr)
+			}
+
+			if got := attempts.Load(); got != tc.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tc.wantAttempts, got)
+			}
+		})
+	}
+}
+
+func TestClient_Do_RetryAfter(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+
+	var firstAttempt, secondAttempt time.Time
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if attempts.Add(1) == 1 {
+			firstAttempt = time.Now()
+
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+
+			return
+		}
+
+		secondAttempt = time.Now()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+	client.SetRetryPolicy(fastRetryPolicy())
+
+	req, err := client.NewRequest(http.MethodGet, "/api/albums", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	if err := client.Do(req, nil); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	// Retry-After takes precedence over the much shorter backoff
+	if waited := secondAttempt.Sub(firstAttempt); waited < time.Second {
+		t.Errorf("Expected to wait at least 1s before retrying, waited %v", waited)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{
+		MaxRetries:     10,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	}
+
+	for retry := range 10 {
+		want := min(policy.InitialBackoff<<retry, policy.MaxBackoff)
+
+		got := policy.backoff(retry)
+		if got < want/2 || got > want {
+			t.Errorf("backoff(%d) = %v, want between %v and %v", retry, got, want/2, want)
+		}
+	}
+}
+
+func TestClient_Do_RetryCancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// Ask for a long wait, then cancel while the client is waiting
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		cancel()
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+
+	req, err := client.NewRequestWithContext(ctx, http.MethodGet, "/api/albums", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	start := time.Now()
+
+	err = client.Do(req, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	if time.Since(start) > 10*time.Second {
+		t.Error("Expected cancellation to interrupt the retry wait")
+	}
+}
+
+func TestClient_Do_RetryAfterLimit(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	policy := fastRetryPolicy()
+	policy.MaxRetryAfter = 10 * time.Millisecond
+
+	client := NewClient(server.URL, "test-token")
+	client.SetRetryPolicy(policy)
+
+	req, err := client.NewRequest(http.MethodGet, "/api/albums", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	start := time.Now()
+
+	if err := client.Do(req, nil); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if waited := time.Since(start); waited > 10*time.Second {
+		t.Errorf("Expected the Retry-After wait to be capped, waited %v", waited)
+	}
+}
+
+func TestClient_Do_RetryStop(t *testing.T) {
+	t.Parallel()
+
+	stop, cancel := context.WithCancel(context.Background())
+
+	var attempts atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+
+		// Ask for a long wait, then stop retries while the client is waiting
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		cancel()
+	}))
+	defer server.Close()
+
+	policy := fastRetryPolicy()
+	policy.MaxRetryAfter = time.Minute
+
+	client := NewClient(server.URL, "test-token")
+	client.SetRetryPolicy(policy)
+
+	// The request itself cannot be cancelled, only its retries
+	ctx := WithRetryStop(context.WithoutCancel(stop), stop)
+
+	req, err := client.NewRequestWithContext(ctx, http.MethodGet, "/api/albums", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	start := time.Now()
+
+	err = client.Do(req, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	if time.Since(start) > 10*time.Second || attempts.Load() != 1 {
+		t.Errorf("Expected stopping retries to interrupt the wait after 1 attempt, got %d", attempts.Load())
+	}
+}
+
+func TestClient_Do_AddUsersNotRetried(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		path         string
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{
+			name:         "adding album users is not repeated",
+			path:         "/api/albums/1/users",
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "adding users on the legacy route is not repeated",
+			path:         "/api/album/1/users",
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "other PUT requests are retried",
+			path:         "/api/albums/1/assets",
+			wantAttempts: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var attempts atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if attempts.Add(1) > 1 {
+					return
+				}
+
+				// Drop the connection as if the reply had been lost
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Errorf("Hijack() error = %v", err)
+
+					return
+				}
+
+				_ = conn.Close()
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL, "test-token")
+			client.SetRetryPolicy(fastRetryPolicy())
+
+			req, err := client.NewRequest(http.MethodPut, tc.path, map[string][]string{"ids": {"a"}})
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			err = client.Do(req, nil)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Do() error = %v, wantErr %v", err, tc.wantErr)
+			}
+
+			if got := attempts.Load(); got != tc.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tc.wantAttempts, got)
+			}
+		})
+	}
+}
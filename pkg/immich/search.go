@@ -0,0 +1,68 @@
+package immich
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// SearchService provides access to the search endpoints.
+type SearchService struct {
+	client *Client
+}
+
+// MetadataSearchRequest is the request body for a metadata search. Page is
+// a string because Immich returns the next page as one and accepts it back
+// unchanged.
+type MetadataSearchRequest struct {
+	Page      string   `json:"page"`
+	PersonIDs []string `json:"personIds"`
+}
+
+// MetadataSearchResponse is a single page of metadata search results.
+type MetadataSearchResponse struct {
+	Assets struct {
+		Items    []Asset `json:"items"`
+		NextPage *string `json:"nextPage"`
+	} `json:"assets"`
+}
+
+// Metadata returns one page of assets matching a metadata search.
+func (s *SearchService) Metadata(ctx context.Context, search MetadataSearchRequest) (*MetadataSearchResponse, error) {
+	req, err := s.client.NewRequestWithContext(ctx, http.MethodPost, "/api/search/metadata", search)
+	if err != nil {
+		return nil, fmt.Errorf("creating search request: %w", err)
+	}
+
+	var response MetadataSearchResponse
+	if err := s.client.Do(req, &response); err != nil {
+		return nil, fmt.Errorf("searching metadata: %w", err)
+	}
+
+	return &response, nil
+}
+
+// MetadataAll follows the pagination of a metadata search and returns the
+// assets from every page.
+func (s *SearchService) MetadataAll(ctx context.Context, search MetadataSearchRequest) ([]Asset, error) {
+	var assets []Asset
+
+	if search.Page == "" {
+		search.Page = "1"
+	}
+
+	for {
+		response, err := s.Metadata(ctx, search)
+		if err != nil {
+			return nil, fmt.Errorf("page %s: %w", search.Page, err)
+		}
+
+		assets = append(assets, response.Assets.Items...)
+
+		if response.Assets.NextPage == nil {
+			return assets, nil
+		}
+
+		search.Page = *response.Assets.NextPage
+	}
+}
@@ -0,0 +1,48 @@
+package immich
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSearchService_MetadataAll(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var search MetadataSearchRequest
+		if err := json.NewDecoder(r.Body).Decode(&search); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		if len(search.PersonIDs) != 1 || search.PersonIDs[0] != "person-1" {
+			t.Errorf("Unexpected person IDs %v", search.PersonIDs)
+		}
+
+		switch search.Page {
+		case "1":
+			_, _ = w.Write([]byte(`{"assets":{"items":[{"id":"a"},{"id":"b"}],"nextPage":"2"}}`))
+		case "2":
+			_, _ = w.Write([]byte(`{"assets":{"items":[{"id":"c"}],"nextPage":null}}`))
+		default:
+			t.Errorf("Unexpected page %q", search.Page)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+
+	assets, err := client.Search.MetadataAll(context.Background(), MetadataSearchRequest{PersonIDs: []string{"person-1"}})
+	if err != nil {
+		t.Fatalf("MetadataAll() error = %v", err)
+	}
+
+	if len(assets) != 3 || assets[2].ID != "c" {
+		t.Errorf("Unexpected assets %+v", assets)
+	}
+}
@@ -0,0 +1,54 @@
+package immich
+
+import (
+	"context"
+	"fmt"
+)
+
+// ServerService provides access to the server information endpoints.
+type ServerService struct {
+	client *Client
+}
+
+// ServerVersion is the version of the Immich server.
+type ServerVersion struct {
+	Major int `json:"major"`
+	Minor int `json:"minor"`
+	Patch int `json:"patch"`
+}
+
+// String formats the version as "v<major>.<minor>.<patch>".
+func (v ServerVersion) String() string {
+	return fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast reports whether v is other or a later version.
+func (v ServerVersion) AtLeast(other ServerVersion) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+
+	if v.Minor != other.Minor {
+		return v.Minor > other.Minor
+	}
+
+	return v.Patch >= other.Patch
+}
+
+// Version returns the version of the server. Servers older than v1.106
+// only serve it under /api/server-info, which is tried when the current
+// route is not found.
+func (s *ServerService) Version(ctx context.Context) (*ServerVersion, error) {
+	var version ServerVersion
+
+	err := s.client.get(ctx, "/api/server/version", &version)
+	if IsNotFound(err) {
+		err = s.client.get(ctx, "/api/server-info/version", &version)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("getting server version: %w", err)
+	}
+
+	return &version, nil
+}
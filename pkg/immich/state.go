@@ -0,0 +1,147 @@
+package immich
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"immich-manager/pkg/plan"
+)
+
+// albumState is what intents can change about an album, as read from the
+// server and updated by the intents carried out since.
+type albumState struct {
+	name string
+	// roles maps the IDs of the users the album is shared with to their
+	// roles.
+	roles map[string]string
+	// assets holds the IDs of the album's assets. It is nil until the
+	// assets have been read.
+	assets map[string]bool
+}
+
+// ExactRevert reads the albums intents change and returns the intents that
+// restore their current state once intents are carried out: the name an
+// album has now, the role a removed user has now, and only the users and
+// assets that carrying out intents actually adds or removes. The result is
+// empty when intents change nothing.
+func (c *Client) ExactRevert(ctx context.Context, intents []plan.Intent) ([]plan.Intent, error) {
+	states := make(map[string]*albumState)
+	reverts := make([]plan.Intent, 0, len(intents))
+
+	for _, intent := range intents {
+		var withAssets bool
+
+		switch intent.Kind {
+		case plan.IntentAlbumAddAssets, plan.IntentAlbumRemoveAssets:
+			withAssets = true
+		case plan.IntentAlbumRename, plan.IntentAlbumAddUser, plan.IntentAlbumRemoveUser:
+		default:
+			return nil, fmt.Errorf("unknown intent kind %q", intent.Kind)
+		}
+
+		state, err := c.albumState(ctx, states, intent.AlbumID, withAssets)
+		if err != nil {
+			return nil, err
+		}
+
+		if revert, changed := state.carryOut(intent); changed {
+			reverts = append(reverts, revert)
+		}
+	}
+
+	slices.Reverse(reverts)
+
+	return reverts, nil
+}
+
+// albumState returns the state of an album, reading it from the server
+// the first time it is needed.
+func (c *Client) albumState(
+	ctx context.Context, states map[string]*albumState, id string, withAssets bool,
+) (*albumState, error) {
+	state, known := states[id]
+	if known && (!withAssets || state.assets != nil) {
+		return state, nil
+	}
+
+	get := c.Albums.Get
+	if withAssets {
+		get = c.Albums.GetWithAssets
+	}
+
+	album, err := get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !known {
+		state = &albumState{name: album.Name, roles: make(map[string]string, len(album.AlbumUsers))}
+		for _, member := range album.AlbumUsers {
+			state.roles[member.User.ID] = member.Role
+		}
+
+		states[id] = state
+	}
+
+	if withAssets {
+		state.assets = make(map[string]bool, len(album.Assets))
+		for _, asset := range album.Assets {
+			state.assets[asset.ID] = true
+		}
+	}
+
+	return state, nil
+}
+
+// carryOut updates the state as intent would and returns the intent that
+// undoes the change, or false when intent changes nothing.
+func (s *albumState) carryOut(intent plan.Intent) (plan.Intent, bool) {
+	revert := plan.Intent{AlbumID: intent.AlbumID, UserID: intent.UserID}
+
+	switch intent.Kind {
+	case plan.IntentAlbumRename:
+		if s.name == intent.Name {
+			return plan.Intent{}, false
+		}
+
+		revert.Kind, revert.Name, revert.From = plan.IntentAlbumRename, s.name, intent.Name
+		s.name = intent.Name
+	case plan.IntentAlbumAddUser:
+		// Sharing again does not change the role of a member
+		if _, ok := s.roles[intent.UserID]; ok {
+			return plan.Intent{}, false
+		}
+
+		revert.Kind, revert.Role = plan.IntentAlbumRemoveUser, intent.Role
+		s.roles[intent.UserID] = intent.Role
+	case plan.IntentAlbumRemoveUser:
+		role, ok := s.roles[intent.UserID]
+		if !ok {
+			return plan.Intent{}, false
+		}
+
+		revert.Kind, revert.Role = plan.IntentAlbumAddUser, role
+		delete(s.roles, intent.UserID)
+	case plan.IntentAlbumAddAssets, plan.IntentAlbumRemoveAssets:
+		adding := intent.Kind == plan.IntentAlbumAddAssets
+
+		revert.Kind = plan.IntentAlbumAddAssets
+		if adding {
+			revert.Kind = plan.IntentAlbumRemoveAssets
+		}
+
+		for _, id := range intent.AssetIDs {
+			if s.assets[id] != adding {
+				s.assets[id] = adding
+				revert.AssetIDs = append(revert.AssetIDs, id)
+			}
+		}
+
+		if len(revert.AssetIDs) == 0 {
+			return plan.Intent{}, false
+		}
+	}
+
+	return revert, true
+}
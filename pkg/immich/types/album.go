@@ -1,9 +1,38 @@
 // Package types provides Immich API data types.
 package types
 
+import "time"
+
 // Album represents an Immich album.
 type Album struct {
-	ID         string      `json:"id"`
-	Name       string      `json:"albumName"`
-	AlbumUsers []AlbumUser `json:"albumUsers"`
+	ID                         string      `json:"id"`
+	Name                       string      `json:"albumName"`
+	Description                string      `json:"description"`
+	OwnerID                    string      `json:"ownerId"`
+	Owner                      User        `json:"owner"`
+	AlbumUsers                 []AlbumUser `json:"albumUsers"`
+	Shared                     bool        `json:"shared"`
+	HasSharedLink              bool        `json:"hasSharedLink"`
+	AssetCount                 int         `json:"assetCount"`
+	Assets                     []Asset     `json:"assets,omitempty"`
+	AlbumThumbnailAssetID      *string     `json:"albumThumbnailAssetId"`
+	StartDate                  *time.Time  `json:"startDate,omitempty"`
+	EndDate                    *time.Time  `json:"endDate,omitempty"`
+	Order                      string      `json:"order,omitempty"`
+	IsActivityEnabled          bool        `json:"isActivityEnabled"`
+	CreatedAt                  time.Time   `json:"createdAt"`
+	UpdatedAt                  time.Time   `json:"updatedAt"`
+	LastModifiedAssetTimestamp *time.Time  `json:"lastModifiedAssetTimestamp,omitempty"`
+}
+
+// Member returns the album user entry for userID, if the user is shared
+// with the album.
+func (a *Album) Member(userID string) (AlbumUser, bool) {
+	for _, albumUser := range a.AlbumUsers {
+		if albumUser.User.ID == userID {
+			return albumUser, true
+		}
+	}
+
+	return AlbumUser{}, false
 }
@@ -0,0 +1,83 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// albumResponse is an album as returned by GET /api/albums/{id}.
+const albumResponse = `{
+  "id": "b9c2e8a4-53a6-4d4e-9a3b-2f1d0f3b7c11",
+  "albumName": "Italy 2023",
+  "description": "Summer trip",
+  "ownerId": "0d6c0c3f-1a4e-4c8e-8c4b-6a2e8f9d1e22",
+  "owner": {
+    "id": "0d6c0c3f-1a4e-4c8e-8c4b-6a2e8f9d1e22",
+    "email": "owner@example.com",
+    "name": "Owner",
+    "profileImagePath": "",
+    "avatarColor": "primary"
+  },
+  "albumUsers": [
+    {
+      "user": {"id": "5f1e2d3c-4b5a-4697-8a8b-9c0d1e2f3a44", "email": "alice@example.com", "name": "Alice"},
+      "role": "editor"
+    }
+  ],
+  "shared": true,
+  "hasSharedLink": false,
+  "assetCount": 2,
+  "assets": [{"id": "a1", "type": "IMAGE"}, {"id": "a2", "type": "VIDEO"}],
+  "albumThumbnailAssetId": "a1",
+  "startDate": "2023-07-01T10:00:00.000Z",
+  "endDate": "2023-07-14T18:30:00.000Z",
+  "order": "desc",
+  "isActivityEnabled": true,
+  "createdAt": "2023-07-15T08:00:00.000Z",
+  "updatedAt": "2023-07-16T08:00:00.000Z",
+  "lastModifiedAssetTimestamp": "2023-07-14T18:30:00.000Z"
+}`
+
+func TestAlbum_Decode(t *testing.T) {
+	t.Parallel()
+
+	var album Album
+	if err := json.Unmarshal([]byte(albumResponse), &album); err != nil {
+		t.Fatalf("Failed to decode album: %v", err)
+	}
+
+	if album.Name != "Italy 2023" || album.Description != "Summer trip" {
+		t.Errorf("Unexpected name or description: %q %q", album.Name, album.Description)
+	}
+
+	if album.Owner.Email != "owner@example.com" || album.OwnerID != album.Owner.ID {
+		t.Errorf("Unexpected owner: %+v", album.Owner)
+	}
+
+	if !album.Shared || album.AssetCount != 2 || len(album.Assets) != 2 || album.Assets[1].Type != AssetTypeVideo {
+		t.Errorf("Unexpected sharing or assets: %+v", album)
+	}
+
+	if album.AlbumThumbnailAssetID == nil || *album.AlbumThumbnailAssetID != "a1" {
+		t.Errorf("Unexpected thumbnail: %v", album.AlbumThumbnailAssetID)
+	}
+
+	wantStart := time.Date(2023, 7, 1, 10, 0, 0, 0, time.UTC)
+	if album.StartDate == nil || !album.StartDate.Equal(wantStart) {
+		t.Errorf("StartDate = %v, want %v", album.StartDate, wantStart)
+	}
+
+	if album.Order != "desc" || !album.IsActivityEnabled {
+		t.Errorf("Unexpected order or activity flag: %q %v", album.Order, album.IsActivityEnabled)
+	}
+
+	member, ok := album.Member("5f1e2d3c-4b5a-4697-8a8b-9c0d1e2f3a44")
+	if !ok || member.Role != "editor" || member.User.Email != "alice@example.com" {
+		t.Errorf("Member() = %+v, %v", member, ok)
+	}
+
+	if _, ok := album.Member("unknown"); ok {
+		t.Error("Expected unknown user not to be a member")
+	}
+}
@@ -0,0 +1,29 @@
+// Package types provides Immich API data types.
+package types
+
+import "time"
+
+// Asset types reported by Immich.
+const (
+	AssetTypeImage = "IMAGE"
+	AssetTypeVideo = "VIDEO"
+)
+
+// Asset represents an Immich asset.
+type Asset struct {
+	ID               string     `json:"id"`
+	Type             string     `json:"type,omitempty"`
+	OwnerID          string     `json:"ownerId,omitempty"`
+	OriginalFileName string     `json:"originalFileName,omitempty"`
+	OriginalPath     string     `json:"originalPath,omitempty"`
+	Checksum         string     `json:"checksum,omitempty"`
+	Duration         string     `json:"duration,omitempty"`
+	IsFavorite       bool       `json:"isFavorite,omitempty"`
+	IsArchived       bool       `json:"isArchived,omitempty"`
+	IsTrashed        bool       `json:"isTrashed,omitempty"`
+	FileCreatedAt    *time.Time `json:"fileCreatedAt,omitempty"`
+	FileModifiedAt   *time.Time `json:"fileModifiedAt,omitempty"`
+	LocalDateTime    *time.Time `json:"localDateTime,omitempty"`
+	People           []Person   `json:"people,omitempty"`
+	Tags             []Tag      `json:"tags,omitempty"`
+}
@@ -0,0 +1,15 @@
+// Package types provides Immich API data types.
+package types
+
+import "time"
+
+// Person represents a person recognised by Immich.
+type Person struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	BirthDate     *string    `json:"birthDate,omitempty"`
+	ThumbnailPath string     `json:"thumbnailPath,omitempty"`
+	IsHidden      bool       `json:"isHidden"`
+	IsFavorite    bool       `json:"isFavorite,omitempty"`
+	UpdatedAt     *time.Time `json:"updatedAt,omitempty"`
+}
@@ -0,0 +1,26 @@
+// Package types provides Immich API data types.
+package types
+
+import "time"
+
+// Shared link types reported by Immich.
+const (
+	SharedLinkTypeAlbum      = "ALBUM"
+	SharedLinkTypeIndividual = "INDIVIDUAL"
+)
+
+// SharedLink represents a public link to an album or a set of assets.
+type SharedLink struct {
+	ID            string     `json:"id"`
+	Key           string     `json:"key"`
+	Type          string     `json:"type"`
+	Description   *string    `json:"description,omitempty"`
+	UserID        string     `json:"userId"`
+	Album         *Album     `json:"album,omitempty"`
+	Assets        []Asset    `json:"assets,omitempty"`
+	AllowUpload   bool       `json:"allowUpload"`
+	AllowDownload bool       `json:"allowDownload"`
+	ShowMetadata  bool       `json:"showMetadata"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+}
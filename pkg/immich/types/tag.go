@@ -0,0 +1,16 @@
+// Package types provides Immich API data types.
+package types
+
+import "time"
+
+// Tag represents an Immich tag. Value holds the full hierarchical path of
+// the tag, such as "Places/Italy".
+type Tag struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Value     string     `json:"value"`
+	Color     string     `json:"color,omitempty"`
+	ParentID  string     `json:"parentId,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
@@ -3,15 +3,15 @@ package types
 
 // User represents an Immich user.
 type User struct {
-	ID    string `json:"id"`
-	Email string `json:"email"`
-	Name  string `json:"name"`
+	ID               string `json:"id"`
+	Email            string `json:"email"`
+	Name             string `json:"name"`
+	ProfileImagePath string `json:"profileImagePath,omitempty"`
+	AvatarColor      string `json:"avatarColor,omitempty"`
 }
 
 // AlbumUser represents a user shared with an album.
 type AlbumUser struct {
-	User struct {
-		ID string `json:"id"`
-	} `json:"user"`
+	User User   `json:"user"`
 	Role string `json:"role"`
 }
@@ -0,0 +1,59 @@
+package immich
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// UsersService provides access to the user endpoints.
+type UsersService struct {
+	client *Client
+}
+
+// List returns all users on the server.
+func (s *UsersService) List(ctx context.Context) ([]User, error) {
+	var users []User
+	if err := s.client.get(ctx, "/api/users", &users); err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+
+	return users, nil
+}
+
+// Get returns a single user.
+func (s *UsersService) Get(ctx context.Context, id string) (*User, error) {
+	var user User
+	if err := s.client.get(ctx, "/api/users/"+id, &user); err != nil {
+		return nil, fmt.Errorf("getting user %s: %w", id, err)
+	}
+
+	return &user, nil
+}
+
+// Me returns the user the client is authenticated as.
+func (s *UsersService) Me(ctx context.Context) (*User, error) {
+	var user User
+	if err := s.client.get(ctx, "/api/users/me", &user); err != nil {
+		return nil, fmt.Errorf("getting authenticated user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// FindByEmail returns the user with the given email address, compared
+// case-insensitively.
+func (s *UsersService) FindByEmail(ctx context.Context, email string) (*User, error) {
+	users, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, user := range users {
+		if strings.EqualFold(user.Email, email) {
+			return &users[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no user found with email '%s'", email)
+}
@@ -0,0 +1,112 @@
+package immich
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"immich-manager/pkg/plan"
+)
+
+func TestUsersService_FindByEmail(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode([]User{
+			{ID: "u1", Email: "alice@example.com", Name: "Alice"},
+			{ID: "u2", Email: "bob@example.com", Name: "Bob"},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+
+	user, err := client.Users.FindByEmail(context.Background(), "BOB@example.com")
+	if err != nil {
+		t.Fatalf("FindByEmail() error = %v", err)
+	}
+
+	if user.ID != "u2" {
+		t.Errorf("Expected user u2, got %s", user.ID)
+	}
+
+	_, err = client.Users.FindByEmail(context.Background(), "carol@example.com")
+	if err == nil || !strings.Contains(err.Error(), "no user found with email 'carol@example.com'") {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
+func TestUsersService_MeAndServerVersion(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/users/me":
+			_ = json.NewEncoder(w).Encode(User{ID: "u1", Email: "alice@example.com"})
+		case "/api/server/version":
+			_ = json.NewEncoder(w).Encode(ServerVersion{Major: 1, Minor: 118, Patch: 2})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+
+	user, err := client.Users.Me(context.Background())
+	if err != nil {
+		t.Fatalf("Me() error = %v", err)
+	}
+
+	if user.Email != "alice@example.com" {
+		t.Errorf("Expected alice@example.com, got %s", user.Email)
+	}
+
+	version, err := client.Server.Version(context.Background())
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+
+	if version.String() != "v1.118.2" {
+		t.Errorf("Expected v1.118.2, got %s", version)
+	}
+}
+
+func TestServerService_VersionLegacyRoute(t *testing.T) {
+	t.Parallel()
+
+	// Servers older than v1.106 only serve the version under server-info
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/server-info/version" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(ServerVersion{Major: 1, Minor: 105, Patch: 1})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token")
+
+	version, err := client.Server.Version(context.Background())
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+
+	if version.String() != "v1.105.1" {
+		t.Errorf("Expected v1.105.1, got %s", version)
+	}
+
+	req, err := CompileIntent(plan.Intent{Kind: plan.IntentAlbumRename, AlbumID: "a1", Name: "Italy"}, version)
+	if err != nil {
+		t.Fatalf("CompileIntent() error = %v", err)
+	}
+
+	if req.Path != "/api/album/a1" {
+		t.Errorf("CompileIntent() for %s = %s, want the legacy album route", version, req.Path)
+	}
+}
@@ -0,0 +1,254 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// RequestBuilder builds the request that carries out an intent. It is the
+// counterpart of RequestParser.
+type RequestBuilder func(Intent) (Request, error)
+
+// Batch merges small operations adding or removing assets of the same
+// album with Compact, then splits operations with more than size assets
+// with Chunk. It returns the number of operations merged away and the
+// number of operations split.
+func (p *Plan) Batch(size int, parse RequestParser, build RequestBuilder) (int, int, error) {
+	merged, err := p.Compact(size, parse, build)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	split, err := p.Chunk(size, parse, build)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return merged, split, nil
+}
+
+// Chunk splits every operation that adds or removes more than size assets
+// of an album into operations of at most size assets, each reverted by
+// the inverse of its own change, so that no request grows too large and a
+// failure only affects one chunk. Asset IDs are sorted before splitting,
+// so the chunks do not depend on the order the IDs were listed in. It
+// returns the number of operations split. Metadata and signatures are
+// left as they are, so the plan is reported as modified until the caller
+// stamps it again.
+func (p *Plan) Chunk(size int, parse RequestParser, build RequestBuilder) (int, error) {
+	if size < 1 {
+		return 0, fmt.Errorf("batch size must be at least 1, got %d", size)
+	}
+
+	operations := make([]Operation, 0, len(p.Operations))
+	split := 0
+
+	for i, op := range p.Operations {
+		intent, ok := assetIntent(op, parse)
+		if !ok || len(intent.AssetIDs) <= size {
+			operations = append(operations, op)
+
+			continue
+		}
+
+		assetIDs := slices.Sorted(slices.Values(intent.AssetIDs))
+
+		for start := 0; start < len(assetIDs); start += size {
+			chunk := intent
+			chunk.AssetIDs = assetIDs[start:min(start+size, len(assetIDs))]
+
+			chunkOp, err := assetOperation(op, chunk, build)
+			if err != nil {
+				return 0, fmt.Errorf("operation %d: %w", i, err)
+			}
+
+			operations = append(operations, chunkOp)
+		}
+
+		split++
+	}
+
+	p.Operations = operations
+
+	return split, nil
+}
+
+// Compact merges operations that add, or that remove, assets of the same
+// album into operations of at most size assets. An operation is only
+// merged into an earlier one when no operation in between touches the
+// album, so the changes to each album keep their order. Operations with
+// an ID, whose responses others may reference, are never merged. It
+// returns the number of operations merged away. Like Chunk, it leaves
+// metadata and signatures as they are.
+func (p *Plan) Compact(size int, parse RequestParser, build RequestBuilder) (int, error) {
+	if size < 1 {
+		return 0, fmt.Errorf("batch size must be at least 1, got %d", size)
+	}
+
+	operations := make([]Operation, 0, len(p.Operations))
+	// Asset intents of the operations that others may be merged into, by
+	// position in operations
+	groups := make(map[int]Intent)
+	// Positions of the groups still open for merging, by compaction key
+	open := make(map[string]int)
+	changed := make(map[int]bool)
+	merged := 0
+
+	for _, op := range p.Operations {
+		intent, ok := assetIntent(op, parse)
+		key := compactionKey(op, intent)
+
+		if j, found := open[key]; ok && found {
+			if assetIDs := appendMissing(groups[j].AssetIDs, intent.AssetIDs); len(assetIDs) <= size {
+				group := groups[j]
+				group.AssetIDs = assetIDs
+				groups[j] = group
+				changed[j] = true
+				merged++
+
+				continue
+			}
+		}
+
+		closeGroups(open, groups, op, parse)
+
+		if ok {
+			intent.AssetIDs = append([]string(nil), intent.AssetIDs...)
+			groups[len(operations)] = intent
+			open[key] = len(operations)
+		}
+
+		operations = append(operations, op)
+	}
+
+	for j := range changed {
+		op, err := assetOperation(operations[j], groups[j], build)
+		if err != nil {
+			return 0, fmt.Errorf("merging operations: %w", err)
+		}
+
+		operations[j] = op
+	}
+
+	p.Operations = operations
+
+	return merged, nil
+}
+
+// assetIntent returns the change of an operation that only adds or only
+// removes assets of one album and is reverted by exactly the inverse.
+func assetIntent(op Operation, parse RequestParser) (Intent, bool) {
+	if op.ID != "" {
+		return Intent{}, false
+	}
+
+	intents, ok := op.ApplyIntents(parse)
+	if !ok || len(intents) != 1 {
+		return Intent{}, false
+	}
+
+	intent := intents[0]
+	if intent.Kind != IntentAlbumAddAssets && intent.Kind != IntentAlbumRemoveAssets {
+		return Intent{}, false
+	}
+
+	reverts, ok := op.RevertIntents(parse)
+	if !ok || len(reverts) != 1 || !reverts[0].Undoes(intent) {
+		return Intent{}, false
+	}
+
+	return intent, true
+}
+
+// assetOperation returns an operation carrying out intent in the same form
+// as op, as an intent or as requests built by build, with the
+// preconditions and dependencies of op.
+func assetOperation(op Operation, intent Intent, build RequestBuilder) (Operation, error) {
+	result := Operation{DependsOn: op.DependsOn, Preconditions: op.Preconditions}
+
+	if len(op.Intents) > 0 {
+		result.Intents = []Intent{intent}
+
+		return result, nil
+	}
+
+	inverse, err := intent.Inverse()
+	if err != nil {
+		return Operation{}, err
+	}
+
+	apply, err := build(intent)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	revert, err := build(inverse)
+	if err != nil {
+		return Operation{}, err
+	}
+
+	result.Apply = []Request{apply}
+	result.Revert = []Request{revert}
+
+	return result, nil
+}
+
+// compactionKey identifies the operations an asset operation can be merged
+// with: those making the same kind of change to the same album, in the
+// same form, with the same preconditions and dependencies.
+func compactionKey(op Operation, intent Intent) string {
+	data, _ := json.Marshal(struct {
+		Kind          IntentKind     `json:"kind"`
+		AlbumID       string         `json:"albumId"`
+		Intents       bool           `json:"intents"`
+		Preconditions []Precondition `json:"preconditions"`
+		DependsOn     []string       `json:"dependsOn"`
+	}{intent.Kind, intent.AlbumID, len(op.Intents) > 0, op.Preconditions, op.DependsOn})
+
+	return string(data)
+}
+
+// closeGroups stops merging into the groups of the albums op touches. When
+// the albums of op are unknown, every group is closed.
+func closeGroups(open map[string]int, groups map[int]Intent, op Operation, parse RequestParser) {
+	applyIntents, applyKnown := op.ApplyIntents(parse)
+	revertIntents, revertKnown := op.RevertIntents(parse)
+
+	touched := make(map[string]bool)
+
+	for _, intent := range append(append([]Intent(nil), applyIntents...), revertIntents...) {
+		touched[intent.AlbumID] = true
+	}
+
+	for _, pc := range op.Preconditions {
+		touched[pc.AlbumID] = true
+	}
+
+	for key, j := range open {
+		if !applyKnown || !revertKnown || touched[groups[j].AlbumID] {
+			delete(open, key)
+		}
+	}
+}
+
+// appendMissing returns ids followed by the IDs of add it does not hold
+// yet, leaving ids unchanged.
+func appendMissing(ids, add []string) []string {
+	result := append(make([]string, 0, len(ids)+len(add)), ids...)
+
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+
+	for _, id := range add {
+		if !seen[id] {
+			seen[id] = true
+
+			result = append(result, id)
+		}
+	}
+
+	return result
+}
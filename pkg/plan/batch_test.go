@@ -0,0 +1,189 @@
+package plan
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const otherAlbumUUID = "2a1f4c6e-8b3d-4e7a-9c5f-1d2e3f4a5b6c"
+
+// parseAssets understands the album asset requests, standing in for the
+// Immich request parser.
+func parseAssets(req Request) ([]Intent, bool) {
+	albumID, ok := strings.CutSuffix(strings.TrimPrefix(req.Path, "/api/albums/"), "/assets")
+	if !ok {
+		return nil, false
+	}
+
+	var body struct {
+		IDs []string `json:"ids"`
+	}
+
+	if json.Unmarshal(req.Body, &body) != nil {
+		return nil, false
+	}
+
+	kind := IntentAlbumAddAssets
+	if req.Method == http.MethodDelete {
+		kind = IntentAlbumRemoveAssets
+	}
+
+	return []Intent{{Kind: kind, AlbumID: albumID, AssetIDs: body.IDs}}, true
+}
+
+// buildAssets builds the album asset requests parseAssets understands.
+func buildAssets(intent Intent) (Request, error) {
+	method := http.MethodPut
+	if intent.Kind == IntentAlbumRemoveAssets {
+		method = http.MethodDelete
+	}
+
+	body, err := json.Marshal(map[string][]string{"ids": intent.AssetIDs})
+
+	return Request{Path: "/api/albums/" + intent.AlbumID + "/assets", Method: method, Body: body}, err
+}
+
+// assetsOperation returns an operation given as requests that adds or
+// removes assets of an album.
+func assetsOperation(kind IntentKind, albumID string, assetIDs ...string) Operation {
+	intent := Intent{Kind: kind, AlbumID: albumID, AssetIDs: assetIDs}
+	inverse, _ := intent.Inverse()
+	apply, _ := buildAssets(intent)
+	revert, _ := buildAssets(inverse)
+
+	return Operation{
+		Preconditions: []Precondition{AlbumExists(albumID)},
+		Apply:         []Request{apply},
+		Revert:        []Request{revert},
+	}
+}
+
+// assetCounts returns the number of assets each operation of p changes,
+// checking that each one is reverted by the inverse of its change.
+func assetCounts(t *testing.T, p *Plan) []int {
+	t.Helper()
+
+	counts := make([]int, len(p.Operations))
+
+	for i, op := range p.Operations {
+		intents, ok := op.ApplyIntents(parseAssets)
+		if !ok {
+			continue
+		}
+
+		for _, intent := range intents {
+			counts[i] += len(intent.AssetIDs)
+		}
+
+		if reverts, _ := op.RevertIntents(parseAssets); len(reverts) != 1 || !reverts[0].Undoes(intents[0]) {
+			t.Errorf("Operation %d is not reverted by the inverse of its change: %+v", i, op)
+		}
+	}
+
+	return counts
+}
+
+func TestPlan_Chunk(t *testing.T) {
+	t.Parallel()
+
+	assets := []string{"a1", "a2", "a3", "a4", "a5"}
+	p := &Plan{
+		Metadata: &Metadata{Generator: "albums smart"},
+		Operations: []Operation{
+			assetsOperation(IntentAlbumAddAssets, albumUUID, assets...),
+			{Intents: []Intent{{Kind: IntentAlbumRemoveAssets, AlbumID: otherAlbumUUID, AssetIDs: assets}}},
+			addUserOperation(userUUID),
+		},
+	}
+
+	split, err := p.Chunk(2, parseAssets, buildAssets)
+	if err != nil {
+		t.Fatalf("Chunk() error = %v", err)
+	}
+
+	if split != 2 {
+		t.Errorf("Chunk() split %d operations, want 2", split)
+	}
+
+	if got, want := assetCounts(t, p), []int{2, 2, 1, 2, 2, 1, 0}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Assets per operation = %v, want %v", got, want)
+	}
+
+	if p.Operations[2].Revert[0].Method != http.MethodDelete || len(p.Operations[2].Preconditions) != 1 {
+		t.Errorf("Chunk does not keep its revert and preconditions: %+v", p.Operations[2])
+	}
+
+	if len(p.Operations[4].Intents) != 1 || p.Operations[4].Intents[0].AssetIDs[0] != "a3" {
+		t.Errorf("Chunk of an intent operation = %+v, want it to stay an intent", p.Operations[4])
+	}
+
+	if p.Metadata.Hash != "" {
+		t.Errorf("Chunk() set the hash to %s, want the metadata left for the caller to stamp", p.Metadata.Hash)
+	}
+}
+
+func TestPlan_ChunkSorted(t *testing.T) {
+	t.Parallel()
+
+	chunks := func(assetIDs ...string) []Operation {
+		t.Helper()
+
+		p := &Plan{Operations: []Operation{assetsOperation(IntentAlbumAddAssets, albumUUID, assetIDs...)}}
+		if _, err := p.Chunk(2, parseAssets, buildAssets); err != nil {
+			t.Fatalf("Chunk() error = %v", err)
+		}
+
+		return p.Operations
+	}
+
+	got, want := chunks("c", "a", "b"), chunks("b", "c", "a")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Chunks depend on the order of the asset IDs: %+v and %+v", got, want)
+	}
+
+	if intents, _ := got[0].ApplyIntents(parseAssets); !reflect.DeepEqual(intents[0].AssetIDs, []string{"a", "b"}) {
+		t.Errorf("First chunk = %v, want [a b]", intents[0].AssetIDs)
+	}
+}
+
+func TestPlan_Compact(t *testing.T) {
+	t.Parallel()
+
+	p := &Plan{Operations: []Operation{
+		assetsOperation(IntentAlbumAddAssets, albumUUID, "a1", "a2"),
+		assetsOperation(IntentAlbumAddAssets, otherAlbumUUID, "b1"),
+		assetsOperation(IntentAlbumAddAssets, albumUUID, "a2", "a3"),
+		assetsOperation(IntentAlbumAddAssets, albumUUID, "a4", "a5"),
+		// Removing assets keeps later additions to the album in order
+		assetsOperation(IntentAlbumRemoveAssets, albumUUID, "a6"),
+		assetsOperation(IntentAlbumAddAssets, albumUUID, "a7"),
+		assetsOperation(IntentAlbumAddAssets, otherAlbumUUID, "b2"),
+	}}
+
+	merged, err := p.Compact(3, parseAssets, buildAssets)
+	if err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+
+	if merged != 2 {
+		t.Errorf("Compact() merged %d operations, want 2", merged)
+	}
+
+	want := [][]string{{"a1", "a2", "a3"}, {"b1", "b2"}, {"a4", "a5"}, {"a6"}, {"a7"}}
+
+	if len(p.Operations) != len(want) {
+		t.Fatalf("Compact() left %d operations, want %d", len(p.Operations), len(want))
+	}
+
+	for i, op := range p.Operations {
+		intents, _ := op.ApplyIntents(parseAssets)
+		if !reflect.DeepEqual(intents[0].AssetIDs, want[i]) {
+			t.Errorf("Operation %d changes assets %v, want %v", i, intents[0].AssetIDs, want[i])
+		}
+	}
+
+	assetCounts(t, p)
+}
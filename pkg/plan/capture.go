@@ -0,0 +1,327 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNotCaptured is returned when a placeholder refers to a value that
+// has not been captured, for example because the operation it comes from
+// has not been applied.
+var ErrNotCaptured = errors.New("value has not been captured")
+
+var (
+	// placeholderPattern matches references to values captured from the
+	// response of another operation, such as ${create_album.id}.
+	placeholderPattern = regexp.MustCompile(`\$\{([A-Za-z0-9_-]+)\.([A-Za-z0-9_]+(?:\.[A-Za-z0-9_]+)*)\}`)
+	// operationIDPattern matches the IDs operations may declare.
+	operationIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// Reference is a placeholder for a field of the response to the last
+// apply request of the operation with the given ID.
+type Reference struct {
+	Operation string
+	// Field is the name of the field, with dots selecting nested objects.
+	Field string
+}
+
+// String formats the reference as a placeholder.
+func (r Reference) String() string {
+	return Placeholder(r.Operation, r.Field)
+}
+
+// Placeholder returns the placeholder for a field of the response of the
+// operation with the given ID, for use in request paths, bodies and
+// intents.
+func Placeholder(operation, field string) string {
+	return "${" + operation + "." + field + "}"
+}
+
+// isPlaceholder reports whether s consists of a single placeholder.
+func isPlaceholder(s string) bool {
+	match := placeholderPattern.FindStringIndex(s)
+
+	return match != nil && match[0] == 0 && match[1] == len(s)
+}
+
+// References returns the placeholders used by the intents and requests of
+// op, in order of first appearance.
+func (op Operation) References() []Reference {
+	data, err := json.Marshal(Operation{Intents: op.Intents, Apply: op.Apply, Revert: op.Revert})
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[Reference]bool)
+
+	var refs []Reference
+
+	for _, match := range placeholderPattern.FindAllStringSubmatch(string(data), -1) {
+		ref := Reference{Operation: match[1], Field: match[2]}
+		if !seen[ref] {
+			seen[ref] = true
+
+			refs = append(refs, ref)
+		}
+	}
+
+	return refs
+}
+
+// Dependencies returns the IDs of the operations that must be applied
+// before op: those it lists in DependsOn and those whose values it
+// references. Reverts may reference values captured from the operation's
+// own response, which are not dependencies.
+func (op Operation) Dependencies() []string {
+	seen := make(map[string]bool)
+
+	var ids []string
+
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range op.DependsOn {
+		add(id)
+	}
+
+	for _, ref := range op.References() {
+		if ref.Operation != op.ID {
+			add(ref.Operation)
+		}
+	}
+
+	return ids
+}
+
+// CapturedFields returns, for each operation ID, the response fields that
+// operations of p reference, sorted by name.
+func (p *Plan) CapturedFields() map[string][]string {
+	fields := make(map[string][]string)
+	seen := make(map[Reference]bool)
+
+	for _, op := range p.Operations {
+		for _, ref := range op.References() {
+			if !seen[ref] {
+				seen[ref] = true
+
+				fields[ref.Operation] = append(fields[ref.Operation], ref.Field)
+			}
+		}
+	}
+
+	for _, names := range fields {
+		sort.Strings(names)
+	}
+
+	return fields
+}
+
+// Captures maps operation IDs to the values captured from their responses
+// by field name.
+type Captures map[string]map[string]string
+
+// Resolve returns req with its placeholders replaced by captured values.
+// Values are escaped for use in the path and inside JSON strings of the
+// body.
+func (c Captures) Resolve(req Request) (Request, error) {
+	return c.resolve(req, false)
+}
+
+// Fill returns op with the placeholders for values of the operations in c
+// replaced by the captured values, and without its dependencies on those
+// operations, so that it can be applied without them. Placeholders for
+// values of other operations are left in place.
+func (c Captures) Fill(op Operation) (Operation, error) {
+	others := make(Captures, len(c))
+
+	for id, values := range c {
+		if id != op.ID {
+			others[id] = values
+		}
+	}
+
+	return others.fill(op)
+}
+
+// fill returns op with the placeholders for values of the operations in c
+// replaced, including those for values of op itself, and without its
+// dependencies on those operations.
+func (c Captures) fill(op Operation) (Operation, error) {
+	filled := op
+	filled.DependsOn = nil
+
+	for _, id := range op.DependsOn {
+		if _, ok := c[id]; !ok {
+			filled.DependsOn = append(filled.DependsOn, id)
+		}
+	}
+
+	if len(op.Intents) > 0 {
+		data, err := json.Marshal(op.Intents)
+		if err != nil {
+			return Operation{}, fmt.Errorf("encoding intents: %w", err)
+		}
+
+		replaced, err := c.replace(string(data), jsonEscape, true)
+		if err != nil {
+			return Operation{}, err
+		}
+
+		filled.Intents = nil
+		if err := json.Unmarshal([]byte(replaced), &filled.Intents); err != nil {
+			return Operation{}, fmt.Errorf("decoding intents: %w", err)
+		}
+	}
+
+	var err error
+	if filled.Apply, err = c.resolveAll(op.Apply); err != nil {
+		return Operation{}, err
+	}
+
+	if filled.Revert, err = c.resolveAll(op.Revert); err != nil {
+		return Operation{}, err
+	}
+
+	return filled, nil
+}
+
+// resolveAll resolves the placeholders of reqs for values of the
+// operations in c, leaving those of other operations in place.
+func (c Captures) resolveAll(reqs []Request) ([]Request, error) {
+	if reqs == nil {
+		return nil, nil
+	}
+
+	resolved := make([]Request, len(reqs))
+
+	for k, req := range reqs {
+		var err error
+		if resolved[k], err = c.resolve(req, true); err != nil {
+			return nil, err
+		}
+	}
+
+	return resolved, nil
+}
+
+// resolve replaces the placeholders of req. When partial is set,
+// placeholders for values of operations not in c are left in place.
+func (c Captures) resolve(req Request, partial bool) (Request, error) {
+	path, err := c.replace(req.Path, url.PathEscape, partial)
+	if err != nil {
+		return Request{}, err
+	}
+
+	body, err := c.replace(string(req.Body), jsonEscape, partial)
+	if err != nil {
+		return Request{}, err
+	}
+
+	req.Path = path
+	if req.Body != nil {
+		req.Body = json.RawMessage(body)
+	}
+
+	return req, nil
+}
+
+// replace substitutes the placeholders in s with escaped captured values.
+// When partial is set, placeholders for values of operations not in c are
+// left in place.
+func (c Captures) replace(s string, escape func(string) string, partial bool) (string, error) {
+	var missing error
+
+	replaced := placeholderPattern.ReplaceAllStringFunc(s, func(placeholder string) string {
+		match := placeholderPattern.FindStringSubmatch(placeholder)
+
+		if _, ok := c[match[1]]; !ok && partial {
+			return placeholder
+		}
+
+		value, ok := c[match[1]][match[2]]
+		if !ok {
+			if missing == nil {
+				missing = fmt.Errorf("%w: %s", ErrNotCaptured, placeholder)
+			}
+
+			return placeholder
+		}
+
+		return escape(value)
+	})
+
+	return replaced, missing
+}
+
+// jsonEscape escapes s for use inside a JSON string.
+func jsonEscape(s string) string {
+	quoted, _ := json.Marshal(s)
+
+	return string(quoted[1 : len(quoted)-1])
+}
+
+// Capture returns the values of fields in a JSON response. Dotted fields
+// select nested objects. Values must be strings, numbers or booleans.
+func Capture(response []byte, fields []string) (map[string]string, error) {
+	var document any
+
+	decoder := json.NewDecoder(bytes.NewReader(response))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	values := make(map[string]string, len(fields))
+
+	for _, field := range fields {
+		value, err := lookup(document, field)
+		if err != nil {
+			return values, err
+		}
+
+		values[field] = value
+	}
+
+	return values, nil
+}
+
+// lookup returns the value of a dotted field of a decoded JSON document.
+func lookup(document any, field string) (string, error) {
+	value := document
+
+	for _, name := range strings.Split(field, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("response has no field %q", field)
+		}
+
+		if value, ok = object[name]; !ok {
+			return "", fmt.Errorf("response has no field %q", field)
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	default:
+		return "", fmt.Errorf("response field %q is not a string, number or boolean", field)
+	}
+}
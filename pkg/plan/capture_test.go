@@ -0,0 +1,157 @@
+package plan
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// createAlbumOperation returns an operation creating an album with the
+// given ID, which deletes the album it created when reverted.
+func createAlbumOperation(id string) Operation {
+	return Operation{
+		ID:     id,
+		Apply:  []Request{{Path: "/api/albums", Method: http.MethodPost, Body: []byte(`{"albumName":"New"}`)}},
+		Revert: []Request{{Path: "/api/albums/" + Placeholder(id, "id"), Method: http.MethodDelete}},
+	}
+}
+
+func TestOperation_Dependencies(t *testing.T) {
+	t.Parallel()
+
+	albumID := Placeholder("create_album", "id")
+	op := Operation{
+		DependsOn: []string{"setup"},
+		Apply:     []Request{addUserRequest(albumID, userUUID)},
+		Revert:    []Request{removeUserRequest(albumID, Placeholder("owner", "user.id"))},
+	}
+
+	wantRefs := []Reference{{Operation: "create_album", Field: "id"}, {Operation: "owner", Field: "user.id"}}
+	if got := op.References(); !reflect.DeepEqual(got, wantRefs) {
+		t.Errorf("References() = %v, want %v", got, wantRefs)
+	}
+
+	if got := op.Dependencies(); !reflect.DeepEqual(got, []string{"setup", "create_album", "owner"}) {
+		t.Errorf("Dependencies() = %v", got)
+	}
+
+	if got := createAlbumOperation("create_album").Dependencies(); len(got) != 0 {
+		t.Errorf("Dependencies() of an operation reverted with its own values = %v, want none", got)
+	}
+}
+
+func TestCaptures_Resolve(t *testing.T) {
+	t.Parallel()
+
+	values, err := Capture([]byte(`{"id":"a1","owner":{"id":"u1","name":"Jane \"J\" Doe"},"count":3}`),
+		[]string{"id", "owner.id", "owner.name", "count"})
+	if err != nil {
+		t.Fatalf("Capture() error = %v", err)
+	}
+
+	captures := Captures{"create_album": values}
+	req := Request{
+		Path:   "/api/albums/" + Placeholder("create_album", "id") + "/users",
+		Method: http.MethodPut,
+		Body:   []byte(`{"userId":"${create_album.owner.id}","note":"${create_album.owner.name} ${create_album.count}"}`),
+	}
+
+	resolved, err := captures.Resolve(req)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	if resolved.Path != "/api/albums/a1/users" {
+		t.Errorf("Resolve() path = %s", resolved.Path)
+	}
+
+	if want := `{"userId":"u1","note":"Jane \"J\" Doe 3"}`; string(resolved.Body) != want {
+		t.Errorf("Resolve() body = %s, want %s", resolved.Body, want)
+	}
+
+	if _, err := (Captures{}).Resolve(req); !errors.Is(err, ErrNotCaptured) {
+		t.Errorf("Resolve() without captured values error = %v, want ErrNotCaptured", err)
+	}
+
+	for _, field := range []string{"missing", "owner", "id.nested"} {
+		if _, err := Capture([]byte(`{"id":"a1","owner":{"id":"u1"}}`), []string{field}); err == nil {
+			t.Errorf("Capture(%q) succeeded, want an error", field)
+		}
+	}
+}
+
+func TestValidate_Dependencies(t *testing.T) {
+	t.Parallel()
+
+	albumID := Placeholder("create_album", "id")
+	addUser := Operation{
+		Apply:  []Request{addUserRequest(albumID, userUUID)},
+		Revert: []Request{removeUserRequest(albumID, userUUID)},
+	}
+
+	tests := []struct {
+		name string
+		ops  []Operation
+		want []string
+	}{
+		{
+			name: "valid",
+			ops:  []Operation{createAlbumOperation("create_album"), addUser},
+		},
+		{
+			name: "dependency comes later",
+			ops:  []Operation{addUser, createAlbumOperation("create_album")},
+			want: []string{`operation 1: depends on operation "create_album", which does not come before it`},
+		},
+		{
+			name: "unknown and duplicate IDs",
+			ops: []Operation{
+				createAlbumOperation("create_album"),
+				createAlbumOperation("create_album"),
+				{ID: "bad id", DependsOn: []string{"missing"}, Apply: addUser.Apply, Revert: addUser.Revert},
+			},
+			want: []string{
+				`operation 2: id "create_album" is already used by operation 1`,
+				`operation 3: id "bad id" may only contain`,
+				`operation 3: depends on unknown operation "missing"`,
+			},
+		},
+		{
+			name: "apply uses its own response",
+			ops: []Operation{{
+				ID:     "create_album",
+				Apply:  addUser.Apply,
+				Revert: addUser.Revert,
+			}},
+			want: []string{"operation 1: ${create_album.id} is only captured once the apply requests have completed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			issues := Validate(&Plan{Operations: tt.ops}, parseUsers)
+
+			var errs []string
+
+			for _, issue := range issues {
+				if issue.Severity == SeverityError {
+					errs = append(errs, issue.String())
+				}
+			}
+
+			if len(errs) != len(tt.want) {
+				t.Fatalf("Validate() errors = %q, want %q", errs, tt.want)
+			}
+
+			for k, want := range tt.want {
+				if !strings.Contains(errs[k], want) {
+					t.Errorf("Validate() error %d = %q, want it to contain %q", k, errs[k], want)
+				}
+			}
+		})
+	}
+}
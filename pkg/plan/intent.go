@@ -0,0 +1,306 @@
+package plan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotConverged is returned when the server does not match the state
+	// an applied operation intended.
+	ErrNotConverged = errors.New("server state has not converged")
+	// ErrNotInvertible is returned for intents that lack the information
+	// needed to undo them.
+	ErrNotInvertible = errors.New("intent cannot be inverted")
+)
+
+// IntentKind identifies the change an Intent describes.
+type IntentKind string
+
+const (
+	// IntentAlbumRename renames an album.
+	IntentAlbumRename IntentKind = "album.rename"
+	// IntentAlbumAddUser shares an album with a user.
+	IntentAlbumAddUser IntentKind = "album.addUser"
+	// IntentAlbumRemoveUser stops sharing an album with a user.
+	IntentAlbumRemoveUser IntentKind = "album.removeUser"
+	// IntentAlbumAddAssets adds assets to an album.
+	IntentAlbumAddAssets IntentKind = "album.addAssets"
+	// IntentAlbumRemoveAssets removes assets from an album.
+	IntentAlbumRemoveAssets IntentKind = "album.removeAssets"
+)
+
+// Intent is the meaning of a request, independent of the API route used to
+// carry it out. Only the fields relevant to Kind are set.
+type Intent struct {
+	Kind    IntentKind `json:"kind"`
+	AlbumID string     `json:"albumId"`
+	Name    string     `json:"name,omitempty"`
+	// From is the name an album.rename intent replaces.
+	From   string `json:"from,omitempty"`
+	UserID string `json:"userId,omitempty"`
+	// Role is the role an album.addUser intent grants, or the role an
+	// album.removeUser intent takes away.
+	Role     string   `json:"role,omitempty"`
+	AssetIDs []string `json:"assetIds,omitempty"`
+}
+
+// Inverse returns the intent that undoes i. Renames need From and removed
+// users need Role to be inverted.
+func (i Intent) Inverse() (Intent, error) {
+	inverse := Intent{AlbumID: i.AlbumID, UserID: i.UserID, Role: i.Role, AssetIDs: i.AssetIDs}
+
+	switch i.Kind {
+	case IntentAlbumRename:
+		if i.From == "" {
+			return Intent{}, fmt.Errorf("%w: %s of album %s has no from name", ErrNotInvertible, i.Kind, i.AlbumID)
+		}
+
+		inverse.Kind, inverse.Name, inverse.From = IntentAlbumRename, i.From, i.Name
+	case IntentAlbumAddUser:
+		inverse.Kind = IntentAlbumRemoveUser
+	case IntentAlbumRemoveUser:
+		if i.Role == "" {
+			return Intent{}, fmt.Errorf("%w: %s of album %s has no role to restore", ErrNotInvertible, i.Kind, i.AlbumID)
+		}
+
+		inverse.Kind = IntentAlbumAddUser
+	case IntentAlbumAddAssets:
+		inverse.Kind = IntentAlbumRemoveAssets
+	case IntentAlbumRemoveAssets:
+		inverse.Kind = IntentAlbumAddAssets
+	default:
+		return Intent{}, fmt.Errorf("%w: unknown kind %q", ErrNotInvertible, i.Kind)
+	}
+
+	return inverse, nil
+}
+
+// ApplyIntents returns what applying op does: its intents, or the intents
+// parse recognises in its apply requests. It reports false if any request
+// was not recognised.
+func (op Operation) ApplyIntents(parse RequestParser) ([]Intent, bool) {
+	if len(op.Intents) > 0 {
+		return op.Intents, true
+	}
+
+	return parseRequests(op.Apply, parse)
+}
+
+// RevertIntents returns what reverting op does: the inverses of its
+// intents in reverse order, or the intents parse recognises in its revert
+// requests. It reports false if any intent could not be inverted or any
+// request was not recognised.
+func (op Operation) RevertIntents(parse RequestParser) ([]Intent, bool) {
+	if len(op.Intents) == 0 {
+		return parseRequests(op.Revert, parse)
+	}
+
+	inverses := make([]Intent, 0, len(op.Intents))
+
+	for i := len(op.Intents) - 1; i >= 0; i-- {
+		inverse, err := op.Intents[i].Inverse()
+		if err != nil {
+			return inverses, false
+		}
+
+		inverses = append(inverses, inverse)
+	}
+
+	return inverses, true
+}
+
+// PartialRevert returns the revert requests that undo the first sent apply
+// requests of op, for an operation that failed part way. The revert
+// requests must mirror the apply requests, each undoing the apply request
+// in the mirrored position as parse understands them; otherwise it reports
+// false.
+func (op Operation) PartialRevert(sent int, parse RequestParser) ([]Request, bool) {
+	n := len(op.Apply)
+	if sent > n || len(op.Revert) != n {
+		return nil, false
+	}
+
+	for k := range sent {
+		applied, ok := parseRequests(op.Apply[k:k+1], parse)
+		if !ok || len(applied) != 1 {
+			return nil, false
+		}
+
+		reverted, ok := parseRequests(op.Revert[n-1-k:n-k], parse)
+		if !ok || len(reverted) != 1 || !reverted[0].Undoes(applied[0]) {
+			return nil, false
+		}
+	}
+
+	return op.Revert[n-sent:], true
+}
+
+// parseRequests returns the intents parse recognises in reqs, and false if
+// parse is nil or any request was not recognised.
+func parseRequests(reqs []Request, parse RequestParser) ([]Intent, bool) {
+	if parse == nil {
+		return nil, false
+	}
+
+	var intents []Intent
+
+	known := true
+
+	for _, req := range reqs {
+		parsed, ok := parse(req)
+		if !ok {
+			known = false
+
+			continue
+		}
+
+		intents = append(intents, parsed...)
+	}
+
+	return intents, known
+}
+
+// Undoes reports whether i reverses the effect of other.
+func (i Intent) Undoes(other Intent) bool {
+	if i.AlbumID != other.AlbumID {
+		return false
+	}
+
+	switch other.Kind {
+	case IntentAlbumRename:
+		return i.Kind == IntentAlbumRename && i.Name != other.Name
+	case IntentAlbumAddUser:
+		return i.Kind == IntentAlbumRemoveUser && i.UserID == other.UserID
+	case IntentAlbumRemoveUser:
+		return i.Kind == IntentAlbumAddUser && i.UserID == other.UserID
+	case IntentAlbumAddAssets:
+		return i.Kind == IntentAlbumRemoveAssets && sameIDs(i.AssetIDs, other.AssetIDs)
+	case IntentAlbumRemoveAssets:
+		return i.Kind == IntentAlbumAddAssets && sameIDs(i.AssetIDs, other.AssetIDs)
+	default:
+		return false
+	}
+}
+
+// sameIDs reports whether a and b hold the same set of IDs.
+func sameIDs(a, b []string) bool {
+	set := make(map[string]bool, len(a))
+	for _, id := range a {
+		set[id] = true
+	}
+
+	for _, id := range b {
+		if !set[id] {
+			return false
+		}
+	}
+
+	other := make(map[string]bool, len(b))
+	for _, id := range b {
+		other[id] = true
+	}
+
+	return len(set) == len(other)
+}
+
+// Lift rewrites the operations of p that are given as requests as intents
+// wherever parse recognises every request and the inverses of the intents
+// are exactly the revert requests, and returns the number of operations
+// rewritten. Like Chunk, it leaves metadata and signatures as they are.
+func (p *Plan) Lift(parse RequestParser) int {
+	lifted := 0
+
+	for i, op := range p.Operations {
+		if intents, ok := liftOperation(op, parse); ok {
+			p.Operations[i] = Operation{
+				ID:            op.ID,
+				DependsOn:     op.DependsOn,
+				Preconditions: op.Preconditions,
+				Intents:       intents,
+			}
+			lifted++
+		}
+	}
+
+	return lifted
+}
+
+// liftOperation returns the intents equivalent to op, taking the names and
+// roles their inverses restore from its revert requests.
+func liftOperation(op Operation, parse RequestParser) ([]Intent, bool) {
+	if len(op.Intents) > 0 {
+		return nil, false
+	}
+
+	intents, applyKnown := op.ApplyIntents(parse)
+	reverts, revertKnown := op.RevertIntents(parse)
+
+	if !applyKnown || !revertKnown || len(intents) == 0 {
+		return nil, false
+	}
+
+	intents = append([]Intent(nil), intents...)
+
+	for i, intent := range intents {
+		for _, revert := range reverts {
+			if revert.AlbumID != intent.AlbumID {
+				continue
+			}
+
+			switch {
+			case intent.Kind == IntentAlbumRename && revert.Kind == IntentAlbumRename:
+				intents[i].From = revert.Name
+			case intent.Kind == IntentAlbumRemoveUser && revert.Kind == IntentAlbumAddUser &&
+				revert.UserID == intent.UserID:
+				intents[i].Role = revert.Role
+			}
+		}
+	}
+
+	inverses, ok := Operation{Intents: intents}.RevertIntents(nil)
+	if !ok || !sameIntents(inverses, reverts) {
+		return nil, false
+	}
+
+	return intents, true
+}
+
+// sameIntents reports whether a and b hold the same intents in any order.
+func sameIntents(a, b []Intent) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	count := make(map[string]int, len(a))
+
+	for _, intent := range a {
+		count[intentKey(intent)]++
+	}
+
+	for _, intent := range b {
+		key := intentKey(intent)
+		if count[key] == 0 {
+			return false
+		}
+
+		count[key]--
+	}
+
+	return true
+}
+
+// intentKey identifies an intent by its fields, ignoring the name a rename
+// replaces and the role a removal takes away, which requests do not carry.
+func intentKey(intent Intent) string {
+	intent.From = ""
+
+	if intent.Kind == IntentAlbumRemoveUser {
+		intent.Role = ""
+	}
+
+	data, _ := json.Marshal(intent)
+
+	return string(data)
+}
@@ -0,0 +1,205 @@
+package plan
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIntent_Inverse(t *testing.T) {
+	t.Parallel()
+
+	intents := []Intent{
+		{Kind: IntentAlbumRename, AlbumID: albumUUID, Name: "Italy 2024", From: "Italy"},
+		{Kind: IntentAlbumAddUser, AlbumID: albumUUID, UserID: userUUID, Role: "editor"},
+		{Kind: IntentAlbumRemoveUser, AlbumID: albumUUID, UserID: userUUID, Role: "viewer"},
+		{Kind: IntentAlbumAddAssets, AlbumID: albumUUID, AssetIDs: []string{"a", "b"}},
+		{Kind: IntentAlbumRemoveAssets, AlbumID: albumUUID, AssetIDs: []string{"a"}},
+	}
+
+	for _, intent := range intents {
+		inverse, err := intent.Inverse()
+		if err != nil {
+			t.Fatalf("%s: Inverse() error = %v", intent.Kind, err)
+		}
+
+		if !inverse.Undoes(intent) {
+			t.Errorf("%s: inverse %+v does not undo the intent", intent.Kind, inverse)
+		}
+
+		twice, err := inverse.Inverse()
+		if err != nil || !reflect.DeepEqual(twice, intent) {
+			t.Errorf("%s: inverting twice = %+v, %v; want the intent", intent.Kind, twice, err)
+		}
+	}
+
+	for _, intent := range []Intent{
+		{Kind: IntentAlbumRename, AlbumID: albumUUID, Name: "Italy 2024"},
+		{Kind: IntentAlbumRemoveUser, AlbumID: albumUUID, UserID: userUUID},
+	} {
+		if _, err := intent.Inverse(); !errors.Is(err, ErrNotInvertible) {
+			t.Errorf("%s without the state to restore: Inverse() error = %v, want ErrNotInvertible", intent.Kind, err)
+		}
+	}
+}
+
+func TestOperation_RevertIntents(t *testing.T) {
+	t.Parallel()
+
+	op := Operation{Intents: []Intent{
+		{Kind: IntentAlbumAddUser, AlbumID: albumUUID, UserID: userUUID, Role: "viewer"},
+		{Kind: IntentAlbumRename, AlbumID: albumUUID, Name: "new", From: "old"},
+	}}
+
+	got, ok := op.RevertIntents(nil)
+	if !ok {
+		t.Fatal("RevertIntents() reported intents that cannot be inverted")
+	}
+
+	want := []Intent{
+		{Kind: IntentAlbumRename, AlbumID: albumUUID, Name: "old", From: "new"},
+		{Kind: IntentAlbumRemoveUser, AlbumID: albumUUID, UserID: userUUID, Role: "viewer"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RevertIntents() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPlan_Lift(t *testing.T) {
+	t.Parallel()
+
+	unknown := Request{Path: "/api/people/" + userUUID, Method: "PUT"}
+
+	p := &Plan{Operations: []Operation{
+		{
+			Preconditions: []Precondition{AlbumNotMember(albumUUID, userUUID)},
+			Apply:         []Request{addUserRequest(albumUUID, userUUID)},
+			Revert:        []Request{removeUserRequest(albumUUID, userUUID)},
+		},
+		{
+			Apply:  []Request{removeUserRequest(albumUUID, otherUserUUID)},
+			Revert: []Request{addUserRequest(albumUUID, otherUserUUID)},
+		},
+		// The revert does not undo the apply, so it must stay as it is
+		{
+			Apply:  []Request{addUserRequest(albumUUID, userUUID)},
+			Revert: []Request{addUserRequest(albumUUID, userUUID)},
+		},
+		{Apply: []Request{unknown}, Revert: []Request{unknown}},
+	}}
+
+	if err := p.Stamp(Metadata{Generator: "albums add-user"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// parseUsers does not report roles, so add them as Immich would
+	parse := func(req Request) ([]Intent, bool) {
+		intents, ok := parseUsers(req)
+		for i := range intents {
+			if intents[i].Kind == IntentAlbumAddUser {
+				intents[i].Role = "viewer"
+			}
+		}
+
+		return intents, ok
+	}
+
+	if lifted := p.Lift(parse); lifted != 2 {
+		t.Errorf("Lift() = %d, want 2", lifted)
+	}
+
+	want := []Operation{
+		{
+			Preconditions: []Precondition{AlbumNotMember(albumUUID, userUUID)},
+			Intents:       []Intent{{Kind: IntentAlbumAddUser, AlbumID: albumUUID, UserID: userUUID, Role: "viewer"}},
+		},
+		{
+			Intents: []Intent{{Kind: IntentAlbumRemoveUser, AlbumID: albumUUID, UserID: otherUserUUID, Role: "viewer"}},
+		},
+	}
+
+	if !reflect.DeepEqual(p.Operations[:2], want) {
+		t.Errorf("Lifted operations = %+v, want %+v", p.Operations[:2], want)
+	}
+
+	if len(p.Operations[2].Apply) == 0 || len(p.Operations[3].Apply) == 0 {
+		t.Error("Expected operations that cannot be lifted to keep their requests")
+	}
+
+	if modified, err := p.Modified(); err != nil || !modified {
+		t.Errorf("Modified() = %v, %v; want the plan to be modified until it is stamped again", modified, err)
+	}
+}
+
+func TestPlan_LiftDependencies(t *testing.T) {
+	t.Parallel()
+
+	albumID := Placeholder("create_album", "id")
+
+	p := &Plan{Operations: []Operation{
+		createAlbumOperation("create_album"),
+		{
+			ID:        "share",
+			DependsOn: []string{"create_album"},
+			Apply:     []Request{addUserRequest(albumID, userUUID)},
+			Revert:    []Request{removeUserRequest(albumID, userUUID)},
+		},
+	}}
+
+	parse := func(req Request) ([]Intent, bool) {
+		intents, ok := parseUsers(req)
+		for i := range intents {
+			intents[i].Role = "viewer"
+		}
+
+		return intents, ok
+	}
+
+	if lifted := p.Lift(parse); lifted != 1 {
+		t.Fatalf("Lift() = %d, want 1 operation lifted", lifted)
+	}
+
+	want := Operation{
+		ID:        "share",
+		DependsOn: []string{"create_album"},
+		Intents:   []Intent{{Kind: IntentAlbumAddUser, AlbumID: albumID, UserID: userUUID, Role: "viewer"}},
+	}
+	if !reflect.DeepEqual(p.Operations[1], want) {
+		t.Errorf("Lifted operation = %+v, want %+v", p.Operations[1], want)
+	}
+
+	for _, issue := range Validate(p, parse) {
+		if issue.Severity == SeverityError {
+			t.Errorf("Validate() of the lifted plan reported %v", issue)
+		}
+	}
+}
+
+func TestInvert_Intents(t *testing.T) {
+	t.Parallel()
+
+	p := &Plan{Operations: []Operation{{
+		Preconditions: []Precondition{AlbumNamed(albumUUID, "old")},
+		Intents:       []Intent{{Kind: IntentAlbumRename, AlbumID: albumUUID, Name: "new", From: "old"}},
+	}}}
+
+	inverted, err := Invert(p, nil)
+	if err != nil {
+		t.Fatalf("Invert() error = %v", err)
+	}
+
+	want := Operation{
+		Preconditions: []Precondition{AlbumNamed(albumUUID, "new")},
+		Intents:       []Intent{{Kind: IntentAlbumRename, AlbumID: albumUUID, Name: "old", From: "new"}},
+	}
+
+	if !reflect.DeepEqual(inverted.Operations, []Operation{want}) {
+		t.Errorf("Invert() operations = %+v, want %+v", inverted.Operations, []Operation{want})
+	}
+
+	p.Operations[0].Intents[0].From = ""
+	if _, err := Invert(p, nil); !errors.Is(err, ErrNotInvertible) {
+		t.Errorf("Invert() of a rename without from error = %v, want ErrNotInvertible", err)
+	}
+}
@@ -0,0 +1,209 @@
+package plan
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Invert returns a plan that undoes p. Its operations are those of p in
+// reverse order with their apply and revert requests swapped, or their
+// intents replaced by the inverses, so that applying the inverted plan is
+// the same as reverting p. Operations keep their IDs and dependencies,
+// so placeholders in the reverts still refer to the values captured when
+// p was applied. The inverted plan is meant to be executed as a revert of
+// p; use Undo for a plan that can be applied on its own.
+//
+// Preconditions are translated to the state p leaves behind: membership
+// checks are flipped and name checks take the name p renames the album
+// to, which parse is used to find. Name checks that cannot be translated
+// are dropped.
+func Invert(p *Plan, parse RequestParser) (*Plan, error) {
+	operations, err := invertOperations(p, parse)
+	if err != nil {
+		return nil, err
+	}
+
+	return inverted(p, operations)
+}
+
+// Undo returns a plan that undoes p once it has been applied, which can
+// be validated and applied like any other plan. Its operations are those
+// of Invert, with the placeholders filled in from captured, the values
+// captured when p was applied, and the dependencies reversed: an
+// operation that others depended on now depends on them, as they must be
+// undone first. Operations that gain dependents are given an ID when they
+// have none. It returns ErrNotCaptured when a placeholder has no value.
+func Undo(p *Plan, captured Captures, parse RequestParser) (*Plan, error) {
+	operations, err := invertOperations(p, parse)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(p.Operations)
+
+	for k, op := range operations {
+		filled, err := captured.fill(op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", n-k, err)
+		}
+
+		if refs := filled.References(); len(refs) > 0 {
+			return nil, fmt.Errorf("operation %d: %w: %s", n-k, ErrNotCaptured, refs[0])
+		}
+
+		filled.DependsOn = nil
+		operations[k] = filled
+	}
+
+	ids := make(map[string]int, n)
+
+	for i, op := range p.Operations {
+		if op.ID != "" {
+			ids[op.ID] = i
+		}
+	}
+
+	for j, op := range p.Operations {
+		for _, id := range op.Dependencies() {
+			i, ok := ids[id]
+			if !ok {
+				continue
+			}
+
+			// Operation j depended on operation i, so the undo of i
+			// depends on the undo of j
+			dependent := &operations[n-1-j]
+			if dependent.ID == "" {
+				dependent.ID = unusedID(ids, j)
+				ids[dependent.ID] = j
+			}
+
+			dependency := &operations[n-1-i]
+			dependency.DependsOn = append(dependency.DependsOn, dependent.ID)
+		}
+	}
+
+	undo, err := inverted(p, operations)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, name := range p.Names {
+		if value, err := captured.replace(id, func(s string) string { return s }, true); err == nil && value != id {
+			undo.SetName(value, name)
+		}
+	}
+
+	return undo, nil
+}
+
+// unusedID returns an operation ID for the operation at index i that is
+// not in ids.
+func unusedID(ids map[string]int, i int) string {
+	id := "operation_" + strconv.Itoa(i+1)
+
+	for {
+		if _, taken := ids[id]; !taken {
+			return id
+		}
+
+		id = "_" + id
+	}
+}
+
+// invertOperations returns the operations of p in reverse order, each
+// undoing the original operation.
+func invertOperations(p *Plan, parse RequestParser) ([]Operation, error) {
+	operations := make([]Operation, 0, len(p.Operations))
+
+	for i := len(p.Operations) - 1; i >= 0; i-- {
+		op := p.Operations[i]
+
+		invertedOp := Operation{
+			ID:            op.ID,
+			DependsOn:     op.DependsOn,
+			Preconditions: invertPreconditions(op, parse),
+			Apply:         op.Revert,
+			Revert:        op.Apply,
+		}
+
+		if len(op.Intents) > 0 {
+			intents, ok := op.RevertIntents(nil)
+			if !ok {
+				return nil, fmt.Errorf("operation %d: %w", i, ErrNotInvertible)
+			}
+
+			invertedOp.Intents = intents
+		}
+
+		operations = append(operations, invertedOp)
+	}
+
+	return operations, nil
+}
+
+// inverted returns the plan of operations undoing p, with the names of p
+// and its metadata attributed to the "invert" generator.
+func inverted(p *Plan, operations []Operation) (*Plan, error) {
+	result := &Plan{
+		Version:    p.Version,
+		Operations: operations,
+	}
+
+	for id, name := range p.Names {
+		result.SetName(id, name)
+	}
+
+	if p.Metadata != nil {
+		meta := *p.Metadata
+		meta.Generator = "invert"
+		meta.Args = append([]string{p.Metadata.Generator}, p.Metadata.Args...)
+
+		if err := result.Stamp(meta); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// invertPreconditions returns the preconditions that hold once op has
+// been applied.
+func invertPreconditions(op Operation, parse RequestParser) []Precondition {
+	if len(op.Preconditions) == 0 {
+		return nil
+	}
+
+	inverted := make([]Precondition, 0, len(op.Preconditions))
+
+	for _, pc := range op.Preconditions {
+		switch pc.Kind {
+		case PreconditionAlbumExists:
+			inverted = append(inverted, pc)
+		case PreconditionAlbumMember:
+			inverted = append(inverted, AlbumNotMember(pc.AlbumID, pc.UserID))
+		case PreconditionAlbumNotMember:
+			inverted = append(inverted, AlbumMember(pc.AlbumID, pc.UserID))
+		case PreconditionAlbumNamed:
+			if name, ok := renamedTo(op, pc.AlbumID, parse); ok {
+				inverted = append(inverted, AlbumNamed(pc.AlbumID, name))
+			}
+		}
+	}
+
+	return inverted
+}
+
+// renamedTo returns the name op gives to an album, if it renames it.
+func renamedTo(op Operation, albumID string, parse RequestParser) (string, bool) {
+	name, found := "", false
+
+	intents, _ := op.ApplyIntents(parse)
+	for _, intent := range intents {
+		if intent.Kind == IntentAlbumRename && intent.AlbumID == albumID {
+			name, found = intent.Name, true
+		}
+	}
+
+	return name, found
+}
@@ -0,0 +1,173 @@
+package plan
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInvert(t *testing.T) {
+	t.Parallel()
+
+	rename := Request{Path: "/api/albums/" + albumUUID, Method: http.MethodPatch}
+	restore := Request{Path: "/api/albums/" + albumUUID, Method: http.MethodPut}
+
+	p := &Plan{
+		Names: map[string]string{albumUUID: "Italy"},
+		Operations: []Operation{
+			{
+				Preconditions: []Precondition{AlbumNotMember(albumUUID, userUUID)},
+				Apply:         []Request{addUserRequest(albumUUID, userUUID)},
+				Revert:        []Request{removeUserRequest(albumUUID, userUUID)},
+			},
+			{
+				Preconditions: []Precondition{AlbumNamed(albumUUID, "Italy"), AlbumExists(albumUUID)},
+				Apply:         []Request{rename},
+				Revert:        []Request{restore},
+			},
+		},
+	}
+
+	parse := func(req Request) ([]Intent, bool) {
+		if req.Method == http.MethodPatch {
+			return []Intent{{Kind: IntentAlbumRename, AlbumID: albumUUID, Name: "Italy 2024"}}, true
+		}
+
+		return parseUsers(req)
+	}
+
+	inverted, err := Invert(p, parse)
+	if err != nil {
+		t.Fatalf("Invert() error = %v", err)
+	}
+
+	want := []Operation{
+		{
+			Preconditions: []Precondition{AlbumNamed(albumUUID, "Italy 2024"), AlbumExists(albumUUID)},
+			Apply:         []Request{restore},
+			Revert:        []Request{rename},
+		},
+		{
+			Preconditions: []Precondition{AlbumMember(albumUUID, userUUID)},
+			Apply:         []Request{removeUserRequest(albumUUID, userUUID)},
+			Revert:        []Request{addUserRequest(albumUUID, userUUID)},
+		},
+	}
+
+	if !reflect.DeepEqual(inverted.Operations, want) {
+		t.Errorf("Invert() operations = %+v, want %+v", inverted.Operations, want)
+	}
+
+	if inverted.Names[albumUUID] != "Italy" {
+		t.Errorf("Expected names to be kept, got %v", inverted.Names)
+	}
+
+	// Inverting twice gives back the original requests
+	twice, err := Invert(inverted, parse)
+	if err != nil {
+		t.Fatalf("Invert() error = %v", err)
+	}
+
+	for i := range p.Operations {
+		if !reflect.DeepEqual(twice.Operations[i].Apply, p.Operations[i].Apply) {
+			t.Errorf("Operation %d apply = %+v, want %+v", i, twice.Operations[i].Apply, p.Operations[i].Apply)
+		}
+	}
+}
+
+func TestInvert_Metadata(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	p := &Plan{Operations: []Operation{{
+		Apply:  []Request{addUserRequest(albumUUID, userUUID)},
+		Revert: []Request{removeUserRequest(albumUUID, userUUID)},
+	}}}
+
+	if err := p.Stamp(Metadata{Generator: "albums add-user", Args: []string{"italy"}, CreatedAt: created}); err != nil {
+		t.Fatalf("Stamp() error = %v", err)
+	}
+
+	inverted, err := Invert(p, nil)
+	if err != nil {
+		t.Fatalf("Invert() error = %v", err)
+	}
+
+	meta := inverted.Metadata
+	if meta.Generator != "invert" || !reflect.DeepEqual(meta.Args, []string{"albums add-user", "italy"}) {
+		t.Errorf("Unexpected generator %q %v", meta.Generator, meta.Args)
+	}
+
+	if !meta.CreatedAt.Equal(created) || meta.Hash == p.Metadata.Hash {
+		t.Errorf("Expected the creation time to be kept and the hash to be recomputed, got %+v", meta)
+	}
+
+	if modified, _ := inverted.Modified(); modified {
+		t.Error("Expected the inverted plan not to be reported as modified")
+	}
+}
+
+func TestUndo(t *testing.T) {
+	t.Parallel()
+
+	created := Placeholder("create", "id")
+	p := &Plan{
+		Names: map[string]string{created: "New"},
+		Operations: []Operation{
+			createAlbumOperation("create"),
+			{
+				ID:     "share",
+				Apply:  []Request{addUserRequest(created, userUUID)},
+				Revert: []Request{removeUserRequest(created, userUUID)},
+			},
+			{
+				DependsOn: []string{"share"},
+				Apply:     []Request{addUserRequest(albumUUID, userUUID)},
+				Revert:    []Request{removeUserRequest(albumUUID, userUUID)},
+			},
+		},
+	}
+
+	if _, err := Undo(p, Captures{}, parseUsers); !errors.Is(err, ErrNotCaptured) {
+		t.Errorf("Undo() without captured values error = %v, want ErrNotCaptured", err)
+	}
+
+	undo, err := Undo(p, Captures{"create": {"id": albumUUID}}, parseUsers)
+	if err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+
+	want := []Operation{
+		{
+			ID:     "operation_3",
+			Apply:  []Request{removeUserRequest(albumUUID, userUUID)},
+			Revert: []Request{addUserRequest(albumUUID, userUUID)},
+		},
+		{
+			ID:        "share",
+			DependsOn: []string{"operation_3"},
+			Apply:     []Request{removeUserRequest(albumUUID, userUUID)},
+			Revert:    []Request{addUserRequest(albumUUID, userUUID)},
+		},
+		{
+			ID:        "create",
+			DependsOn: []string{"share"},
+			Apply:     []Request{{Path: "/api/albums/" + albumUUID, Method: http.MethodDelete}},
+			Revert:    p.Operations[0].Apply,
+		},
+	}
+
+	if !reflect.DeepEqual(undo.Operations, want) {
+		t.Errorf("Undo() operations = %+v, want %+v", undo.Operations, want)
+	}
+
+	if undo.Names[albumUUID] != "New" {
+		t.Errorf("Expected the name of the created album to be kept, got %v", undo.Names)
+	}
+
+	if issues := Validate(undo, parseUsers); HasErrors(issues) {
+		t.Errorf("Validate() of the undo plan = %v", issues)
+	}
+}
@@ -0,0 +1,342 @@
+package plan
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrJournalMismatch is returned when a journal was written for a plan with
+// different operations.
+var ErrJournalMismatch = errors.New("journal belongs to a different plan")
+
+// Action identifies the direction in which an operation was executed.
+type Action string
+
+const (
+	// ActionApply marks an operation whose apply requests completed.
+	ActionApply Action = "apply"
+	// ActionRevert marks an operation whose revert requests completed.
+	ActionRevert Action = "revert"
+)
+
+// JournalEntry records the completion of a single plan operation.
+type JournalEntry struct {
+	Operation int       `json:"operation"`
+	Action    Action    `json:"action"`
+	Time      time.Time `json:"time"`
+	// Captures holds the response values other operations reference, as
+	// captured when the operation was applied.
+	Captures map[string]string `json:"captures,omitempty"`
+	// Revert, when Exact is set, holds the intents that restore the state
+	// the server was in right before the operation was applied. Reverts
+	// use them instead of the revert of the plan. It is empty when
+	// applying the operation changed nothing.
+	Revert []Intent `json:"revert,omitempty"`
+	Exact  bool     `json:"exact,omitempty"`
+}
+
+// journalHeader is the first line of a journal, tying it to the plan it
+// was written for. Journals written before the header was introduced have
+// none.
+type journalHeader struct {
+	PlanHash string `json:"planHash"`
+}
+
+// Journal is an append-only record of the operations of a plan that have
+// been executed. It is stored as JSON Lines so that each completed
+// operation is durable as soon as it has been recorded. A Journal is safe
+// for concurrent use.
+type Journal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	// hash is the content hash of the plan the journal is for, if known.
+	hash    string
+	entries []JournalEntry
+}
+
+// JournalPath returns the default journal location for a plan file.
+func JournalPath(planPath string) string {
+	return planPath + ".journal"
+}
+
+// OpenJournal loads the journal at path. A missing file is treated as an
+// empty journal and is only created once the first entry is recorded.
+func OpenJournal(path string) (*Journal, error) {
+	j := &Journal{path: path}
+
+	//nolint: gosec
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return j, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("opening journal: %w", err)
+	}
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry struct {
+			JournalEntry
+			journalHeader
+		}
+
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			_ = f.Close()
+
+			return nil, fmt.Errorf("decoding journal line %d: %w", line, err)
+		}
+
+		if entry.PlanHash != "" && entry.Action == "" {
+			j.hash = entry.PlanHash
+
+			continue
+		}
+
+		j.entries = append(j.entries, entry.JournalEntry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		_ = f.Close()
+
+		return nil, fmt.Errorf("reading journal: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("closing journal: %w", err)
+	}
+
+	return j, nil
+}
+
+// Bind ties the journal to p. It returns ErrJournalMismatch when the
+// journal was written for a plan with different operations, whose
+// operation numbers cannot be trusted for p. A new journal records the
+// hash of p in its first line; journals without one are accepted.
+func (j *Journal) Bind(p *Plan) error {
+	hash, err := p.Hash()
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.hash != "" && j.hash != hash {
+		return fmt.Errorf("%w: %s was written for a plan with hash %s, not %s", ErrJournalMismatch, j.path, j.hash, hash)
+	}
+
+	if j.hash == "" && len(j.entries) == 0 {
+		j.hash = hash
+	}
+
+	return nil
+}
+
+// Path returns the location of the journal file.
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Entries returns all recorded entries in the order they were written.
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.entries
+}
+
+// Record appends an entry to the journal and syncs it to disk.
+func (j *Journal) Record(entry JournalEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		//nolint: gosec
+		f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("opening journal for writing: %w", err)
+		}
+
+		j.file = f
+
+		if err := j.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding journal entry: %w", err)
+	}
+
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing journal entry: %w", err)
+	}
+
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("syncing journal: %w", err)
+	}
+
+	j.entries = append(j.entries, entry)
+
+	return nil
+}
+
+// writeHeader writes the hash of the plan as the first line of a journal
+// file that is still empty.
+func (j *Journal) writeHeader() error {
+	if j.hash == "" {
+		return nil
+	}
+
+	info, err := j.file.Stat()
+	if err != nil {
+		return fmt.Errorf("checking journal: %w", err)
+	}
+
+	if info.Size() > 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(journalHeader{PlanHash: j.hash})
+	if err != nil {
+		return fmt.Errorf("encoding journal header: %w", err)
+	}
+
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing journal header: %w", err)
+	}
+
+	return nil
+}
+
+// Applied reports whether an operation is currently applied, that is
+// whether its most recent entry is an apply.
+func (j *Journal) Applied(operation int) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		if j.entries[i].Operation == operation {
+			return j.entries[i].Action == ActionApply
+		}
+	}
+
+	return false
+}
+
+// Captured returns the values captured when an operation was applied, or
+// nil when it is not currently applied.
+func (j *Journal) Captured(operation int) map[string]string {
+	entry, ok := j.current(operation)
+	if !ok {
+		return nil
+	}
+
+	return entry.Captures
+}
+
+// Captures returns the values captured from the operations of p with an
+// ID that are currently applied.
+func (j *Journal) Captures(p *Plan) Captures {
+	captured := make(Captures)
+
+	for i, op := range p.Operations {
+		if values := j.Captured(i); values != nil && op.ID != "" {
+			captured[op.ID] = values
+		}
+	}
+
+	return captured
+}
+
+// Revert returns the intents recorded to restore the state the server was
+// in before an operation was applied. It reports false when the operation
+// is not currently applied or no state was recorded.
+func (j *Journal) Revert(operation int) ([]Intent, bool) {
+	entry, ok := j.current(operation)
+	if !ok || !entry.Exact {
+		return nil, false
+	}
+
+	return entry.Revert, true
+}
+
+// current returns the entry that applied an operation, if it is currently
+// applied.
+func (j *Journal) current(operation int) (JournalEntry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		if j.entries[i].Operation == operation {
+			return j.entries[i], j.entries[i].Action == ActionApply
+		}
+	}
+
+	return JournalEntry{}, false
+}
+
+// AppliedOperations returns the indices of all currently applied
+// operations in ascending order.
+func (j *Journal) AppliedOperations() []int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	state := make(map[int]Action)
+	for _, entry := range j.entries {
+		state[entry.Operation] = entry.Action
+	}
+
+	applied := make([]int, 0, len(state))
+
+	for op, action := range state {
+		if action == ActionApply {
+			applied = append(applied, op)
+		}
+	}
+
+	sort.Ints(applied)
+
+	return applied
+}
+
+// Close releases the journal file if it was opened for writing.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+
+	err := j.file.Close()
+	j.file = nil
+
+	if err != nil {
+		return fmt.Errorf("closing journal: %w", err)
+	}
+
+	return nil
+}
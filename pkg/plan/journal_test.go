@@ -0,0 +1,121 @@
+package plan
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJournal_RecordAndReload(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "plan.json.journal")
+
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+
+	if len(journal.Entries()) != 0 {
+		t.Errorf("Expected empty journal, got %d entries", len(journal.Entries()))
+	}
+
+	restore := []Intent{{Kind: IntentAlbumAddUser, AlbumID: albumUUID, UserID: userUUID, Role: "editor"}}
+	entries := []JournalEntry{
+		{Operation: 0, Action: ActionApply, Revert: restore, Exact: true},
+		{Operation: 1, Action: ActionApply, Exact: true},
+		{Operation: 2, Action: ActionApply, Exact: true},
+		{Operation: 1, Action: ActionRevert},
+	}
+
+	for _, entry := range entries {
+		if err := journal.Record(entry); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	if err := journal.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	reloaded, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal() reload error = %v", err)
+	}
+
+	if len(reloaded.Entries()) != len(entries) {
+		t.Fatalf("Expected %d entries after reload, got %d", len(entries), len(reloaded.Entries()))
+	}
+
+	if reloaded.Entries()[0].Time.IsZero() {
+		t.Error("Expected recorded entries to be timestamped")
+	}
+
+	want := []int{0, 2}
+	if got := reloaded.AppliedOperations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("AppliedOperations() = %v, want %v", got, want)
+	}
+
+	if reloaded.Applied(1) {
+		t.Error("Expected operation 1 to be reverted")
+	}
+
+	if reloaded.Applied(3) {
+		t.Error("Expected operation 3 to never have been applied")
+	}
+
+	if got, ok := reloaded.Revert(0); !ok || !reflect.DeepEqual(got, restore) {
+		t.Errorf("Revert(0) = %v, %v; want the recorded intents", got, ok)
+	}
+
+	if got, ok := reloaded.Revert(2); !ok || len(got) != 0 {
+		t.Errorf("Revert(2) = %v, %v; want an empty recorded revert", got, ok)
+	}
+
+	if _, ok := reloaded.Revert(1); ok {
+		t.Error("Expected no recorded revert for a reverted operation")
+	}
+}
+
+func TestJournal_BindOtherPlan(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "plan.json.journal")
+	original := &Plan{Operations: []Operation{assetsOperation(IntentAlbumAddAssets, albumUUID, "a1")}}
+	regenerated := &Plan{Operations: []Operation{assetsOperation(IntentAlbumRemoveAssets, albumUUID, "a1")}}
+
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+
+	if err := journal.Bind(original); err != nil {
+		t.Fatalf("Bind() error = %v", err)
+	}
+
+	if err := journal.Record(JournalEntry{Operation: 0, Action: ActionApply}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	if err := journal.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	reloaded, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal() reload error = %v", err)
+	}
+
+	if len(reloaded.Entries()) != 1 {
+		t.Fatalf("Expected the header not to be an entry, got %d entries", len(reloaded.Entries()))
+	}
+
+	if err := reloaded.Bind(original); err != nil {
+		t.Errorf("Bind() to the same plan error = %v", err)
+	}
+
+	if err := reloaded.Bind(regenerated); !errors.Is(err, ErrJournalMismatch) {
+		t.Errorf("Bind() to another plan error = %v, want %v", err, ErrJournalMismatch)
+	}
+}
@@ -0,0 +1,210 @@
+package plan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMixedServers is returned when merging plans generated for different
+// servers.
+var ErrMixedServers = errors.New("plans target different servers")
+
+// OperationRef identifies an operation of one of the plans being merged.
+type OperationRef struct {
+	// Plan is the index of the plan in the merged list.
+	Plan int `json:"plan"`
+	// Operation is the index of the operation within that plan.
+	Operation int `json:"operation"`
+}
+
+// Conflict describes two operations whose effects contradict each other.
+type Conflict struct {
+	First  OperationRef `json:"first"`
+	Second OperationRef `json:"second"`
+	Reason string       `json:"reason"`
+}
+
+// mergedIntent is an intent together with the operation it came from.
+type mergedIntent struct {
+	ref    OperationRef
+	intent Intent
+}
+
+// Merge concatenates the operations of plans in order, dropping operations
+// that are exact duplicates of an earlier one, and reports operations that
+// contradict each other. Operation IDs must be unique across the plans.
+// parse is used to understand requests; requests it does not recognise
+// are never reported as conflicting.
+//
+// When the inputs carry metadata the result is stamped with generator
+// "merge", the common server and the creation time of the oldest input,
+// so a merged plan is never considered fresher than its parts.
+func Merge(plans []*Plan, parse RequestParser) (*Plan, []Conflict, error) {
+	merged := &Plan{Operations: make([]Operation, 0)}
+	seen := make(map[string]bool)
+	ids := make(map[string]bool)
+	byAlbum := make(map[string][]mergedIntent)
+
+	var conflicts []Conflict
+
+	for pi, p := range plans {
+		for id, name := range p.Names {
+			merged.SetName(id, name)
+		}
+
+		for oi, op := range p.Operations {
+			key, err := operationKey(op)
+			if err != nil {
+				return nil, nil, fmt.Errorf("plan %d operation %d: %w", pi, oi, err)
+			}
+
+			if seen[key] {
+				continue
+			}
+
+			seen[key] = true
+
+			if op.ID != "" {
+				if ids[op.ID] {
+					return nil, nil, fmt.Errorf("plan %d operation %d: id %q is already used by another operation",
+						pi, oi, op.ID)
+				}
+
+				ids[op.ID] = true
+			}
+
+			merged.Operations = append(merged.Operations, op)
+
+			ref := OperationRef{Plan: pi, Operation: oi}
+
+			intents, _ := op.ApplyIntents(parse)
+			for _, intent := range intents {
+				for _, other := range byAlbum[intent.AlbumID] {
+					if reason, ok := conflicting(other.intent, intent); ok {
+						conflicts = append(conflicts, Conflict{First: other.ref, Second: ref, Reason: reason})
+					}
+				}
+
+				byAlbum[intent.AlbumID] = append(byAlbum[intent.AlbumID], mergedIntent{ref: ref, intent: intent})
+			}
+		}
+	}
+
+	meta, err := mergeMetadata(plans)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if meta != nil {
+		if err := merged.Stamp(*meta); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return merged, conflicts, nil
+}
+
+// operationKey identifies an operation by its ID, dependencies, intents and
+// requests, ignoring how request bodies are formatted.
+func operationKey(op Operation) (string, error) {
+	data, err := json.Marshal(struct {
+		ID        string    `json:"id"`
+		DependsOn []string  `json:"dependsOn"`
+		Intents   []Intent  `json:"intents"`
+		Apply     []Request `json:"apply"`
+		Revert    []Request `json:"revert"`
+	}{op.ID, op.DependsOn, op.Intents, op.Apply, op.Revert})
+	if err != nil {
+		return "", fmt.Errorf("encoding operation: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// conflicting reports whether two intents on the same album contradict
+// each other, and why.
+func conflicting(a, b Intent) (string, bool) {
+	switch {
+	case a.Kind == IntentAlbumRename && b.Kind == IntentAlbumRename && a.Name != b.Name:
+		return fmt.Sprintf("album %s is renamed to both '%s' and '%s'", a.AlbumID, a.Name, b.Name), true
+
+	case a.Kind == IntentAlbumAddUser && b.Kind == IntentAlbumAddUser && a.UserID == b.UserID && a.Role != b.Role:
+		return fmt.Sprintf("user %s is added to album %s as both %s and %s", a.UserID, a.AlbumID, a.Role, b.Role), true
+
+	case b.Undoes(a) && (a.Kind == IntentAlbumAddUser || a.Kind == IntentAlbumRemoveUser):
+		return fmt.Sprintf("user %s is both added to and removed from album %s", a.UserID, a.AlbumID), true
+
+	case (a.Kind == IntentAlbumAddAssets && b.Kind == IntentAlbumRemoveAssets) ||
+		(a.Kind == IntentAlbumRemoveAssets && b.Kind == IntentAlbumAddAssets):
+		if overlap := countShared(a.AssetIDs, b.AssetIDs); overlap > 0 {
+			return fmt.Sprintf("%d assets are both added to and removed from album %s", overlap, a.AlbumID), true
+		}
+	}
+
+	return "", false
+}
+
+// countShared returns how many IDs appear in both a and b.
+func countShared(a, b []string) int {
+	set := make(map[string]bool, len(a))
+	for _, id := range a {
+		set[id] = true
+	}
+
+	count := 0
+
+	for _, id := range b {
+		if set[id] {
+			count++
+
+			delete(set, id)
+		}
+	}
+
+	return count
+}
+
+// mergeMetadata combines the metadata of plans. It returns nil when none of
+// them has metadata and ErrMixedServers when they target different servers.
+func mergeMetadata(plans []*Plan) (*Metadata, error) {
+	var merged *Metadata
+
+	for _, p := range plans {
+		meta := p.Metadata
+		if meta == nil {
+			continue
+		}
+
+		if merged == nil {
+			merged = &Metadata{
+				Generator:     "merge",
+				ServerURL:     meta.ServerURL,
+				ServerVersion: meta.ServerVersion,
+				User:          meta.User,
+				CreatedAt:     meta.CreatedAt,
+			}
+
+			continue
+		}
+
+		if !strings.EqualFold(normalizeServerURL(meta.ServerURL), normalizeServerURL(merged.ServerURL)) {
+			return nil, fmt.Errorf("%w: %s and %s", ErrMixedServers, merged.ServerURL, meta.ServerURL)
+		}
+
+		if meta.ServerVersion != merged.ServerVersion {
+			merged.ServerVersion = ""
+		}
+
+		if meta.User != merged.User {
+			merged.User = ""
+		}
+
+		if meta.CreatedAt.Before(merged.CreatedAt) {
+			merged.CreatedAt = meta.CreatedAt
+		}
+	}
+
+	return merged, nil
+}
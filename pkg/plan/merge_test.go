@@ -0,0 +1,131 @@
+package plan
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const otherUserUUID = "3f2a1b0c-9d8e-4f7a-8b6c-5d4e3f2a1b0c"
+
+func addUserOperation(userID string) Operation {
+	return Operation{
+		Apply:  []Request{addUserRequest(albumUUID, userID)},
+		Revert: []Request{removeUserRequest(albumUUID, userID)},
+	}
+}
+
+func removeUserOperation(userID string) Operation {
+	return Operation{
+		Apply:  []Request{removeUserRequest(albumUUID, userID)},
+		Revert: []Request{addUserRequest(albumUUID, userID)},
+	}
+}
+
+func TestMerge_Deduplicates(t *testing.T) {
+	t.Parallel()
+
+	a := &Plan{Names: map[string]string{albumUUID: "Italy"}, Operations: []Operation{addUserOperation(userUUID)}}
+	b := &Plan{Operations: []Operation{addUserOperation(userUUID), addUserOperation(otherUserUUID)}}
+
+	merged, conflicts, err := Merge([]*Plan{a, b}, parseUsers)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	if len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %+v", conflicts)
+	}
+
+	if len(merged.Operations) != 2 {
+		t.Errorf("Expected the duplicate operation to be dropped, got %d operations", len(merged.Operations))
+	}
+
+	if merged.Names[albumUUID] != "Italy" {
+		t.Errorf("Expected names to be merged, got %v", merged.Names)
+	}
+
+	if merged.Metadata != nil {
+		t.Errorf("Expected no metadata when no input has any, got %+v", merged.Metadata)
+	}
+}
+
+func TestMerge_Conflicts(t *testing.T) {
+	t.Parallel()
+
+	a := &Plan{Operations: []Operation{addUserOperation(userUUID)}}
+	b := &Plan{Operations: []Operation{addUserOperation(otherUserUUID), removeUserOperation(userUUID)}}
+
+	_, conflicts, err := Merge([]*Plan{a, b}, parseUsers)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	if len(conflicts) != 1 {
+		t.Fatalf("Expected 1 conflict, got %+v", conflicts)
+	}
+
+	conflict := conflicts[0]
+	if conflict.First != (OperationRef{Plan: 0, Operation: 0}) || conflict.Second != (OperationRef{Plan: 1, Operation: 1}) {
+		t.Errorf("Unexpected conflicting operations %+v", conflict)
+	}
+
+	if !strings.Contains(conflict.Reason, "both added to and removed from album") {
+		t.Errorf("Unexpected reason %q", conflict.Reason)
+	}
+}
+
+func TestConflicting(t *testing.T) {
+	t.Parallel()
+
+	rename := func(name string) Intent { return Intent{Kind: IntentAlbumRename, AlbumID: "a", Name: name} }
+	assets := func(kind IntentKind, ids ...string) Intent { return Intent{Kind: kind, AlbumID: "a", AssetIDs: ids} }
+
+	tests := []struct {
+		a, b Intent
+		want bool
+	}{
+		{rename("x"), rename("y"), true},
+		{rename("x"), rename("x"), false},
+		{assets(IntentAlbumAddAssets, "1", "2"), assets(IntentAlbumRemoveAssets, "2"), true},
+		{assets(IntentAlbumAddAssets, "1"), assets(IntentAlbumRemoveAssets, "2"), false},
+		{
+			Intent{Kind: IntentAlbumAddUser, AlbumID: "a", UserID: "u", Role: "viewer"},
+			Intent{Kind: IntentAlbumAddUser, AlbumID: "a", UserID: "u", Role: "editor"},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		if _, got := conflicting(tt.a, tt.b); got != tt.want {
+			t.Errorf("conflicting(%+v, %+v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMerge_Metadata(t *testing.T) {
+	t.Parallel()
+
+	older := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	a := &Plan{Metadata: &Metadata{ServerURL: "https://photos.example.com", User: "a@example.com", CreatedAt: newer}}
+	b := &Plan{Metadata: &Metadata{ServerURL: "https://photos.example.com/", User: "b@example.com", CreatedAt: older}}
+
+	merged, _, err := Merge([]*Plan{a, b}, parseUsers)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	meta := merged.Metadata
+	if meta == nil || meta.Generator != "merge" || !meta.CreatedAt.Equal(older) || meta.User != "" || meta.Hash == "" {
+		t.Errorf("Unexpected merged metadata %+v", meta)
+	}
+
+	c := &Plan{Metadata: &Metadata{ServerURL: "https://other.example.com", CreatedAt: older}}
+
+	if _, _, err := Merge([]*Plan{a, c}, parseUsers); !errors.Is(err, ErrMixedServers) {
+		t.Errorf("Expected ErrMixedServers, got %v", err)
+	}
+}
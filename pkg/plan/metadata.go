@@ -0,0 +1,121 @@
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FormatVersion is the version of the plan format written by this tool.
+// Plans without a version predate the metadata header.
+const FormatVersion = 1
+
+var (
+	// ErrServerMismatch is returned when a plan targets another server.
+	ErrServerMismatch = errors.New("plan was generated for a different server")
+	// ErrPlanTooOld is returned when a plan is older than allowed.
+	ErrPlanTooOld = errors.New("plan is too old")
+)
+
+// Metadata records where a plan came from.
+type Metadata struct {
+	// Generator is the name of the command that produced the plan, such as
+	// "albums add-user".
+	Generator string `json:"generator"`
+	// Args are the arguments the generator was run with.
+	Args          []string  `json:"args,omitempty"`
+	ServerURL     string    `json:"serverUrl"`
+	ServerVersion string    `json:"serverVersion,omitempty"`
+	User          string    `json:"user,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	// Hash is the content hash of the operations at generation time.
+	Hash string `json:"hash"`
+}
+
+// Stamp attaches metadata to the plan, setting the format version and
+// computing the content hash.
+func (p *Plan) Stamp(meta Metadata) error {
+	hash, err := p.Hash()
+	if err != nil {
+		return err
+	}
+
+	if meta.CreatedAt.IsZero() {
+		meta.CreatedAt = time.Now().UTC()
+	}
+
+	meta.Hash = hash
+	p.Version = FormatVersion
+	p.Metadata = &meta
+
+	return nil
+}
+
+// Hash returns a content hash of the plan's operations. JSON bodies are
+// compacted first, so reformatting a plan file does not change its hash.
+func (p *Plan) Hash() (string, error) {
+	data, err := json.Marshal(p.Operations)
+	if err != nil {
+		return "", fmt.Errorf("encoding operations: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// Modified reports whether the operations changed since the plan was
+// stamped. Plans without metadata are never considered modified.
+func (p *Plan) Modified() (bool, error) {
+	if p.Metadata == nil || p.Metadata.Hash == "" {
+		return false, nil
+	}
+
+	hash, err := p.Hash()
+	if err != nil {
+		return false, err
+	}
+
+	return hash != p.Metadata.Hash, nil
+}
+
+// CheckServer returns ErrServerMismatch if the plan was generated against
+// a server other than serverURL. Plans without metadata pass.
+func (p *Plan) CheckServer(serverURL string) error {
+	if p.Metadata == nil || p.Metadata.ServerURL == "" {
+		return nil
+	}
+
+	if !strings.EqualFold(normalizeServerURL(p.Metadata.ServerURL), normalizeServerURL(serverURL)) {
+		return fmt.Errorf("%w: plan targets %s, current server is %s",
+			ErrServerMismatch, p.Metadata.ServerURL, serverURL)
+	}
+
+	return nil
+}
+
+// CheckAge returns ErrPlanTooOld if the plan was created more than maxAge
+// before now. A zero maxAge and plans without metadata pass.
+func (p *Plan) CheckAge(maxAge time.Duration, now time.Time) error {
+	if maxAge <= 0 || p.Metadata == nil || p.Metadata.CreatedAt.IsZero() {
+		return nil
+	}
+
+	age := now.Sub(p.Metadata.CreatedAt)
+	if age > maxAge {
+		return fmt.Errorf("%w: created %s ago, maximum age is %s",
+			ErrPlanTooOld, age.Round(time.Second), maxAge)
+	}
+
+	return nil
+}
+
+// normalizeServerURL strips the parts of a server URL that do not change
+// which server it points at.
+func normalizeServerURL(serverURL string) string {
+	return strings.TrimRight(strings.TrimSpace(serverURL), "/")
+}
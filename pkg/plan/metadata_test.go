@@ -0,0 +1,133 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPlan() *Plan {
+	return &Plan{
+		Operations: []Operation{
+			{
+				Apply: []Request{
+					{Path: "/api/albums/1", Method: http.MethodPatch, Body: json.RawMessage(`{"albumName": "new"}`)},
+				},
+				Revert: []Request{
+					{Path: "/api/albums/1", Method: http.MethodPatch, Body: json.RawMessage(`{"albumName": "old"}`)},
+				},
+			},
+		},
+	}
+}
+
+func TestPlan_StampRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	p := testPlan()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := p.Stamp(Metadata{
+		Generator: "albums replace",
+		Args:      []string{"old", "new"},
+		ServerURL: "https://photos.example.com",
+		CreatedAt: created,
+	}); err != nil {
+		t.Fatalf("Stamp() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(p); err != nil {
+		t.Fatalf("Failed to encode plan: %v", err)
+	}
+
+	loaded, err := LoadFromReader(&buf)
+	if err != nil {
+		t.Fatalf("LoadFromReader() error = %v", err)
+	}
+
+	if loaded.Version != FormatVersion {
+		t.Errorf("Expected version %d, got %d", FormatVersion, loaded.Version)
+	}
+
+	if loaded.Metadata == nil || loaded.Metadata.Generator != "albums replace" || !loaded.Metadata.CreatedAt.Equal(created) {
+		t.Fatalf("Unexpected metadata %+v", loaded.Metadata)
+	}
+
+	if !strings.HasPrefix(loaded.Metadata.Hash, "sha256:") {
+		t.Errorf("Expected a sha256 hash, got %q", loaded.Metadata.Hash)
+	}
+
+	modified, err := loaded.Modified()
+	if err != nil {
+		t.Fatalf("Modified() error = %v", err)
+	}
+
+	if modified {
+		t.Error("Expected an untouched plan not to be modified")
+	}
+
+	loaded.Operations[0].Apply[0].Body = json.RawMessage(`{"albumName": "edited"}`)
+
+	if modified, _ := loaded.Modified(); !modified {
+		t.Error("Expected an edited plan to be modified")
+	}
+}
+
+func TestLoadFromReader_Compatibility(t *testing.T) {
+	t.Parallel()
+
+	legacy := `{"operations": [{"apply": [{"path": "/api/albums/1", "method": "PATCH"}], "revert": []}]}`
+
+	p, err := LoadFromReader(strings.NewReader(legacy))
+	if err != nil {
+		t.Fatalf("LoadFromReader() error = %v", err)
+	}
+
+	if p.Version != 0 || p.Metadata != nil || len(p.Operations) != 1 {
+		t.Errorf("Unexpected legacy plan %+v", p)
+	}
+
+	if err := p.CheckServer("https://other.example.com"); err != nil {
+		t.Errorf("Expected legacy plans to pass the server check, got %v", err)
+	}
+
+	_, err = LoadFromReader(strings.NewReader(`{"version": 99, "operations": []}`))
+	if err == nil || !strings.Contains(err.Error(), "newer than the supported version") {
+		t.Errorf("Expected an unsupported version error, got %v", err)
+	}
+}
+
+func TestPlan_CheckServerAndAge(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	p := &Plan{Metadata: &Metadata{
+		ServerURL: "https://photos.example.com/",
+		CreatedAt: now.Add(-48 * time.Hour),
+	}}
+
+	if err := p.CheckServer("HTTPS://photos.example.com"); err != nil {
+		t.Errorf("Expected equivalent URLs to match, got %v", err)
+	}
+
+	if err := p.CheckServer("https://other.example.com"); !errors.Is(err, ErrServerMismatch) {
+		t.Errorf("Expected ErrServerMismatch, got %v", err)
+	}
+
+	if err := p.CheckAge(0, now); err != nil {
+		t.Errorf("Expected a zero max age to disable the check, got %v", err)
+	}
+
+	if err := p.CheckAge(72*time.Hour, now); err != nil {
+		t.Errorf("Expected plan within max age to pass, got %v", err)
+	}
+
+	if err := p.CheckAge(24*time.Hour, now); !errors.Is(err, ErrPlanTooOld) {
+		t.Errorf("Expected ErrPlanTooOld, got %v", err)
+	}
+}
@@ -2,16 +2,39 @@
 package plan
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 )
 
-// Operation represents a set of API operations to be performed.
+// ErrNoChanges is returned by generators when the server is already in the
+// state the plan would bring it to.
+var ErrNoChanges = errors.New("no changes needed")
+
+// Operation represents a set of API operations to be performed. It is
+// given either as intents, which are compiled to requests for the server
+// when the plan is applied and reverted through their inverses, or as raw
+// apply and revert requests for changes that have no intent.
+//
+// Operations that need a value only known once another operation has been
+// applied, such as the ID of an album it creates, declare an ID on that
+// operation and reference the value with a placeholder like
+// ${create_album.id} in their requests or intents.
 type Operation struct {
-	Apply  []Request `json:"apply"`
-	Revert []Request `json:"revert"`
+	// ID names the operation so that others can depend on it.
+	ID string `json:"id,omitempty"`
+	// DependsOn lists the IDs of earlier operations that must be applied
+	// first. Operations whose values are referenced need not be listed.
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Preconditions must hold before the operation is applied, otherwise
+	// the server has drifted since the plan was generated.
+	Preconditions []Precondition `json:"preconditions,omitempty"`
+	Intents       []Intent       `json:"intents,omitempty"`
+	Apply         []Request      `json:"apply,omitempty"`
+	Revert        []Request      `json:"revert,omitempty"`
 }
 
 // Request represents a single API request.
@@ -23,7 +46,23 @@ type Request struct {
 
 // Plan represents a series of operations to be performed.
 type Plan struct {
-	Operations []Operation `json:"operations"`
+	Version  int       `json:"version,omitempty"`
+	Metadata *Metadata `json:"metadata,omitempty"`
+	// Names maps IDs used by the operations to human-readable names, such
+	// as album names and user emails, so the plan can be reviewed.
+	Names      map[string]string `json:"names,omitempty"`
+	Operations []Operation       `json:"operations"`
+	// Signatures are made by the people who reviewed the plan.
+	Signatures []Signature `json:"signatures,omitempty"`
+}
+
+// SetName records a human-readable name for an ID used in the plan.
+func (p *Plan) SetName(id, name string) {
+	if p.Names == nil {
+		p.Names = make(map[string]string)
+	}
+
+	p.Names[id] = name
 }
 
 // Generator is an interface for types that can generate plans.
@@ -31,6 +70,13 @@ type Generator interface {
 	Generate() (*Plan, error)
 }
 
+// ContextGenerator is a Generator whose requests can be cancelled through
+// a context.
+type ContextGenerator interface {
+	Generator
+	GenerateContext(ctx context.Context) (*Plan, error)
+}
+
 // Applier is an interface for types that can apply plans.
 type Applier interface {
 	Apply(plan *Plan) error
@@ -44,11 +90,10 @@ func (p *Plan) Save(path string) error {
 		return fmt.Errorf("creating plan file: %w", err)
 	}
 
-	encoder := json.NewEncoder(f)
-	encoder.SetIndent("", "  ")
+	if err := p.Write(f); err != nil {
+		_ = f.Close()
 
-	if err := encoder.Encode(p); err != nil {
-		return fmt.Errorf("encoding plan: %w", err)
+		return err
 	}
 
 	err = f.Close()
@@ -59,6 +104,18 @@ func (p *Plan) Save(path string) error {
 	return nil
 }
 
+// Write encodes a plan as indented JSON to w.
+func (p *Plan) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(p); err != nil {
+		return fmt.Errorf("encoding plan: %w", err)
+	}
+
+	return nil
+}
+
 // Load reads a plan from a file.
 func Load(path string) (*Plan, error) {
 	//nolint: gosec
@@ -80,12 +137,18 @@ func Load(path string) (*Plan, error) {
 	return plan, nil
 }
 
-// LoadFromReader reads a plan from an io.Reader.
+// LoadFromReader reads a plan from an io.Reader. Plans written before the
+// metadata header was introduced are accepted as version zero.
 func LoadFromReader(r io.Reader) (*Plan, error) {
 	var plan Plan
 	if err := json.NewDecoder(r).Decode(&plan); err != nil {
 		return nil, fmt.Errorf("decoding plan: %w", err)
 	}
 
+	if plan.Version > FormatVersion {
+		return nil, fmt.Errorf("plan format version %d is newer than the supported version %d",
+			plan.Version, FormatVersion)
+	}
+
 	return &plan, nil
 }
@@ -0,0 +1,72 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDrift is returned when the server no longer matches the state a plan
+// was generated against.
+var ErrDrift = errors.New("server state has drifted")
+
+// PreconditionKind identifies what a precondition asserts.
+type PreconditionKind string
+
+const (
+	// PreconditionAlbumExists asserts that an album exists.
+	PreconditionAlbumExists PreconditionKind = "album.exists"
+	// PreconditionAlbumNamed asserts that an album has a given name.
+	PreconditionAlbumNamed PreconditionKind = "album.named"
+	// PreconditionAlbumMember asserts that a user is a member of an album.
+	PreconditionAlbumMember PreconditionKind = "album.member"
+	// PreconditionAlbumNotMember asserts that a user is not a member of an
+	// album.
+	PreconditionAlbumNotMember PreconditionKind = "album.notMember"
+)
+
+// Precondition describes server state captured when a plan was generated
+// that must still hold for an operation to have its planned effect.
+type Precondition struct {
+	Kind    PreconditionKind `json:"kind"`
+	AlbumID string           `json:"albumId"`
+	UserID  string           `json:"userId,omitempty"`
+	Name    string           `json:"name,omitempty"`
+}
+
+// AlbumExists returns a precondition asserting that the album exists.
+func AlbumExists(albumID string) Precondition {
+	return Precondition{Kind: PreconditionAlbumExists, AlbumID: albumID}
+}
+
+// AlbumNamed returns a precondition asserting that the album is named name.
+func AlbumNamed(albumID, name string) Precondition {
+	return Precondition{Kind: PreconditionAlbumNamed, AlbumID: albumID, Name: name}
+}
+
+// AlbumMember returns a precondition asserting that the user is a member of
+// the album.
+func AlbumMember(albumID, userID string) Precondition {
+	return Precondition{Kind: PreconditionAlbumMember, AlbumID: albumID, UserID: userID}
+}
+
+// AlbumNotMember returns a precondition asserting that the user is not a
+// member of the album.
+func AlbumNotMember(albumID, userID string) Precondition {
+	return Precondition{Kind: PreconditionAlbumNotMember, AlbumID: albumID, UserID: userID}
+}
+
+// String describes the asserted state.
+func (p Precondition) String() string {
+	switch p.Kind {
+	case PreconditionAlbumExists:
+		return fmt.Sprintf("album %s exists", p.AlbumID)
+	case PreconditionAlbumNamed:
+		return fmt.Sprintf("album %s is named '%s'", p.AlbumID, p.Name)
+	case PreconditionAlbumMember:
+		return fmt.Sprintf("user %s is a member of album %s", p.UserID, p.AlbumID)
+	case PreconditionAlbumNotMember:
+		return fmt.Sprintf("user %s is not a member of album %s", p.UserID, p.AlbumID)
+	default:
+		return fmt.Sprintf("unknown precondition %q", p.Kind)
+	}
+}
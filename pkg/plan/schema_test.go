@@ -0,0 +1,42 @@
+package plan
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The example plans in testdata are written against the published schema:
+// those under valid match it and must pass Validate without errors, and
+// those under invalid break it and must be rejected by Validate.
+func TestValidate_ExamplePlans(t *testing.T) {
+	t.Parallel()
+
+	for _, dir := range []string{"valid", "invalid"} {
+		paths, err := filepath.Glob(filepath.Join("testdata", dir, "*.json"))
+		if err != nil || len(paths) == 0 {
+			t.Fatalf("No example plans in testdata/%s: %v", dir, err)
+		}
+
+		for _, path := range paths {
+			t.Run(dir+"/"+strings.TrimSuffix(filepath.Base(path), ".json"), func(t *testing.T) {
+				t.Parallel()
+
+				p, err := Load(path)
+				if err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+
+				issues := Validate(p, parseUsers)
+
+				if dir == "valid" && HasErrors(issues) {
+					t.Errorf("Validate() issues = %v, want no errors", issues)
+				}
+
+				if dir == "invalid" && !HasErrors(issues) {
+					t.Errorf("Expected Validate() to report an error, got %v", issues)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package plan
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseSelection parses a comma-separated list of 1-based operation
+// numbers and ranges, such as "3,5-9", into 0-based operation indices of a
+// plan with total operations.
+func ParseSelection(spec string, total int) ([]int, error) {
+	set := make(map[int]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		first, last := part, part
+		if before, after, ok := strings.Cut(part, "-"); ok {
+			first, last = strings.TrimSpace(before), strings.TrimSpace(after)
+		}
+
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid operation number %q", first)
+		}
+
+		to, err := strconv.Atoi(last)
+		if err != nil {
+			return nil, fmt.Errorf("invalid operation number %q", last)
+		}
+
+		if from < 1 || to > total || from > to {
+			return nil, fmt.Errorf("operation range %q is outside 1-%d", part, total)
+		}
+
+		for n := from; n <= to; n++ {
+			set[n-1] = true
+		}
+	}
+
+	indices := make([]int, 0, len(set))
+	for i := range set {
+		indices = append(indices, i)
+	}
+
+	sort.Ints(indices)
+
+	return indices, nil
+}
+
+// Subset returns a plan holding only the operations at indices, in plan
+// order. Metadata is kept, with generator "select" and the original
+// generator prepended to the arguments, so the subset can be traced back
+// to where it came from. Operations can only be selected together with
+// the operations they depend on.
+func (p *Plan) Subset(indices []int) (*Plan, error) {
+	sorted := append([]int(nil), indices...)
+	sort.Ints(sorted)
+
+	subset := &Plan{
+		Version:    p.Version,
+		Operations: make([]Operation, 0, len(sorted)),
+	}
+
+	for id, name := range p.Names {
+		subset.SetName(id, name)
+	}
+
+	selected := make(map[string]bool)
+
+	for _, i := range sorted {
+		if i < 0 || i >= len(p.Operations) {
+			return nil, fmt.Errorf("operation %d does not exist", i+1)
+		}
+
+		op := p.Operations[i]
+
+		for _, id := range op.Dependencies() {
+			if !selected[id] {
+				return nil, fmt.Errorf("operation %d depends on operation %q, which is not selected", i+1, id)
+			}
+		}
+
+		if op.ID != "" {
+			selected[op.ID] = true
+		}
+
+		subset.Operations = append(subset.Operations, op)
+	}
+
+	if p.Metadata != nil {
+		meta := *p.Metadata
+		meta.Generator = "select"
+		meta.Args = append([]string{p.Metadata.Generator}, p.Metadata.Args...)
+
+		if err := subset.Stamp(meta); err != nil {
+			return nil, err
+		}
+	}
+
+	return subset, nil
+}
+
+// Retry returns a plan of the operations at indices, such as those that
+// failed, that can be applied on its own once the other operations of p
+// have been applied. captured holds the values captured from the applied
+// operations with an ID; placeholders for them are filled in and
+// dependencies on them dropped, and names are recorded for the values
+// filled in. Metadata is kept as Subset keeps it.
+func (p *Plan) Retry(indices []int, captured Captures) (*Plan, error) {
+	selected := make(map[int]bool, len(indices))
+	for _, i := range indices {
+		selected[i] = true
+	}
+
+	filled := *p
+	filled.Operations = make([]Operation, len(p.Operations))
+
+	for i, op := range p.Operations {
+		if !selected[i] {
+			filled.Operations[i] = op
+
+			continue
+		}
+
+		var err error
+		if filled.Operations[i], err = captured.Fill(op); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i+1, err)
+		}
+	}
+
+	retry, err := filled.Subset(indices)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, name := range p.Names {
+		if value, err := captured.replace(id, func(s string) string { return s }, true); err == nil && value != id {
+			retry.SetName(value, name)
+		}
+	}
+
+	return retry, nil
+}
+
+// Matches reports whether op touches an album or user identified by term.
+// term is compared with IDs exactly and with names, such as album names
+// and user emails, case-insensitively as a substring.
+func (op Operation) Matches(term string, names map[string]string, parse RequestParser) bool {
+	matches := func(id string) bool {
+		if id == "" {
+			return false
+		}
+
+		if id == term {
+			return true
+		}
+
+		name, ok := names[id]
+
+		return ok && strings.Contains(strings.ToLower(name), strings.ToLower(term))
+	}
+
+	for _, pc := range op.Preconditions {
+		if matches(pc.AlbumID) || matches(pc.UserID) {
+			return true
+		}
+	}
+
+	applyIntents, _ := op.ApplyIntents(parse)
+	revertIntents, _ := op.RevertIntents(parse)
+
+	for _, intent := range append(append([]Intent(nil), applyIntents...), revertIntents...) {
+		if matches(intent.AlbumID) || matches(intent.UserID) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,127 @@
+package plan
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSelection(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseSelection("3, 5-7,1,6", 8)
+	if err != nil {
+		t.Fatalf("ParseSelection() error = %v", err)
+	}
+
+	if want := []int{0, 2, 4, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSelection() = %v, want %v", got, want)
+	}
+
+	for _, spec := range []string{"0", "9", "4-2", "a", "2-x"} {
+		if _, err := ParseSelection(spec, 8); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestPlan_Subset(t *testing.T) {
+	t.Parallel()
+
+	p := &Plan{
+		Names: map[string]string{albumUUID: "Italy"},
+		Operations: []Operation{
+			addUserOperation(userUUID),
+			addUserOperation(otherUserUUID),
+			removeUserOperation(userUUID),
+		},
+	}
+
+	if err := p.Stamp(Metadata{Generator: "albums add-user"}); err != nil {
+		t.Fatalf("Stamp() error = %v", err)
+	}
+
+	subset, err := p.Subset([]int{2, 0})
+	if err != nil {
+		t.Fatalf("Subset() error = %v", err)
+	}
+
+	want := []Operation{p.Operations[0], p.Operations[2]}
+	if !reflect.DeepEqual(subset.Operations, want) {
+		t.Errorf("Subset() operations = %+v, want %+v", subset.Operations, want)
+	}
+
+	if subset.Metadata.Generator != "select" || subset.Names[albumUUID] != "Italy" {
+		t.Errorf("Unexpected subset metadata %+v and names %v", subset.Metadata, subset.Names)
+	}
+
+	if _, err := p.Subset([]int{3}); err == nil {
+		t.Error("Expected an error for a missing operation")
+	}
+}
+
+func TestOperation_Matches(t *testing.T) {
+	t.Parallel()
+
+	names := map[string]string{albumUUID: "Italy 2023", userUUID: "alice@example.com"}
+	op := addUserOperation(userUUID)
+
+	for _, term := range []string{albumUUID, userUUID, "italy", "ALICE@"} {
+		if !op.Matches(term, names, parseUsers) {
+			t.Errorf("Expected operation to match %q", term)
+		}
+	}
+
+	for _, term := range []string{"spain", otherUserUUID, "0b6e1a52"} {
+		if op.Matches(term, names, parseUsers) {
+			t.Errorf("Expected operation not to match %q", term)
+		}
+	}
+}
+
+func TestPlan_Retry(t *testing.T) {
+	t.Parallel()
+
+	albumID := Placeholder("create_album", "id")
+	addAssets := Operation{
+		DependsOn: []string{"create_album"},
+		Intents:   []Intent{{Kind: IntentAlbumAddAssets, AlbumID: albumID, AssetIDs: []string{"a1"}}},
+	}
+	addUser := Operation{
+		Apply:  []Request{addUserRequest(albumID, userUUID)},
+		Revert: []Request{removeUserRequest(albumID, userUUID)},
+	}
+
+	p := &Plan{
+		Names:      map[string]string{albumID: "New"},
+		Operations: []Operation{createAlbumOperation("create_album"), addAssets, addUser},
+	}
+
+	if _, err := p.Subset([]int{1, 2}); err == nil {
+		t.Fatal("Expected Subset() to reject operations without the operation they depend on")
+	}
+
+	retry, err := p.Retry([]int{1, 2}, Captures{"create_album": {"id": albumUUID}})
+	if err != nil {
+		t.Fatalf("Retry() error = %v", err)
+	}
+
+	want := []Operation{
+		{Intents: []Intent{{Kind: IntentAlbumAddAssets, AlbumID: albumUUID, AssetIDs: []string{"a1"}}}},
+		{
+			Apply:  []Request{addUserRequest(albumUUID, userUUID)},
+			Revert: []Request{removeUserRequest(albumUUID, userUUID)},
+		},
+	}
+	if !reflect.DeepEqual(retry.Operations, want) {
+		t.Errorf("Retry() operations = %+v, want %+v", retry.Operations, want)
+	}
+
+	if retry.Names[albumUUID] != "New" {
+		t.Errorf("Retry() names = %v, want a name for the created album", retry.Names)
+	}
+
+	if _, err := p.Retry([]int{1}, Captures{"create_album": {}}); !errors.Is(err, ErrNotCaptured) {
+		t.Errorf("Retry() without the referenced value error = %v, want ErrNotCaptured", err)
+	}
+}
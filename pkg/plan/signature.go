@@ -0,0 +1,338 @@
+package plan
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Errors returned when checking the signatures of a plan.
+var (
+	// ErrUnsigned is returned for plans without signatures.
+	ErrUnsigned = errors.New("plan is not signed")
+	// ErrUntrustedKey is returned when no signature is from a trusted key.
+	ErrUntrustedKey = errors.New("plan is not signed by a trusted key")
+	// ErrSignatureMismatch is returned when a signature does not match the
+	// plan, which means the plan was modified after it was signed.
+	ErrSignatureMismatch = errors.New("plan was modified after it was signed")
+)
+
+// signatureContext is prepended to the signed content so that signatures
+// of plans cannot be mistaken for signatures of anything else.
+const signatureContext = "immich-manager plan signature v1\n"
+
+// Signature is an ed25519 signature over the plan, excluding all
+// signatures.
+type Signature struct {
+	// Key is the base64 encoded public key that made the signature.
+	Key      string    `json:"key"`
+	SignedAt time.Time `json:"signedAt"`
+	// Value is the base64 encoded signature.
+	Value string `json:"value"`
+}
+
+// KeyID returns a short fingerprint of the signing key.
+func (s Signature) KeyID() string {
+	key, err := base64.StdEncoding.DecodeString(s.Key)
+	if err != nil {
+		return "invalid"
+	}
+
+	return KeyID(key)
+}
+
+// KeyID returns a short fingerprint of a public key.
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// Sign adds a signature by key to the plan, replacing an earlier
+// signature by the same key.
+func (p *Plan) Sign(key ed25519.PrivateKey, now time.Time) error {
+	public, ok := key.Public().(ed25519.PublicKey)
+	if !ok {
+		return errors.New("signing key has no ed25519 public key")
+	}
+
+	signature := Signature{Key: base64.StdEncoding.EncodeToString(public), SignedAt: now.UTC()}
+
+	message, err := p.signedMessage(signature)
+	if err != nil {
+		return err
+	}
+
+	signature.Value = base64.StdEncoding.EncodeToString(ed25519.Sign(key, message))
+
+	signatures := []Signature{signature}
+
+	for _, other := range p.Signatures {
+		if other.Key != signature.Key {
+			signatures = append(signatures, other)
+		}
+	}
+
+	p.Signatures = signatures
+
+	return nil
+}
+
+// SignatureStatus is the result of checking one signature.
+type SignatureStatus struct {
+	Signature Signature
+	// Signer is the name of the trusted key, empty when the key is not
+	// trusted.
+	Signer string
+	// Err is nil when the signature matches the plan.
+	Err error
+}
+
+// CheckSignatures checks every signature of the plan against trusted.
+func (p *Plan) CheckSignatures(trusted TrustedKeys) []SignatureStatus {
+	statuses := make([]SignatureStatus, len(p.Signatures))
+
+	for i, signature := range p.Signatures {
+		statuses[i] = SignatureStatus{
+			Signature: signature,
+			Signer:    trusted.Name(signature.Key),
+			Err:       p.checkSignature(signature),
+		}
+	}
+
+	return statuses
+}
+
+// VerifySignatures returns an error unless the plan is signed by at least
+// one trusted key and every signature matches the plan. It returns the
+// names of the trusted signers.
+func (p *Plan) VerifySignatures(trusted TrustedKeys) ([]string, error) {
+	if len(p.Signatures) == 0 {
+		return nil, ErrUnsigned
+	}
+
+	var signers []string
+
+	for _, status := range p.CheckSignatures(trusted) {
+		if status.Err != nil {
+			return nil, fmt.Errorf("signature by key %s: %w", status.Signature.KeyID(), status.Err)
+		}
+
+		if status.Signer != "" {
+			signers = append(signers, status.Signer)
+		}
+	}
+
+	if len(signers) == 0 {
+		return nil, ErrUntrustedKey
+	}
+
+	return signers, nil
+}
+
+// checkSignature checks that a signature matches the plan.
+func (p *Plan) checkSignature(signature Signature) error {
+	key, err := base64.StdEncoding.DecodeString(signature.Key)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("malformed public key")
+	}
+
+	value, err := base64.StdEncoding.DecodeString(signature.Value)
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+
+	message, err := p.signedMessage(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(key, message, value) {
+		return ErrSignatureMismatch
+	}
+
+	return nil
+}
+
+// signedMessage returns the content covered by a signature: the plan
+// without its signatures, and the key and time of the signature itself.
+// Encoding compacts JSON bodies, so reformatting a plan file does not
+// invalidate its signatures.
+func (p *Plan) signedMessage(signature Signature) ([]byte, error) {
+	unsigned := *p
+	unsigned.Signatures = nil
+	signature.Value = ""
+
+	data, err := json.Marshal(struct {
+		Plan      *Plan     `json:"plan"`
+		Signature Signature `json:"signature"`
+	}{&unsigned, signature})
+	if err != nil {
+		return nil, fmt.Errorf("encoding plan for signing: %w", err)
+	}
+
+	return append([]byte(signatureContext), data...), nil
+}
+
+// GenerateKey creates an ed25519 signing key.
+func GenerateKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+
+	return key, nil
+}
+
+// SavePrivateKey writes key to path as a PEM encoded PKCS #8 private key
+// that only the current user can read. Existing files are not
+// overwritten.
+func SavePrivateKey(path string, key ed25519.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("encoding private key: %w", err)
+	}
+
+	//nolint: gosec
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("creating private key: %w", err)
+	}
+
+	if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		_ = f.Close()
+
+		return fmt.Errorf("writing private key: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing private key: %w", err)
+	}
+
+	return nil
+}
+
+// LoadPrivateKey reads a key written by SavePrivateKey.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	//nolint: gosec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading private key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s is not a PEM encoded private key", path)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 private key", path)
+	}
+
+	return key, nil
+}
+
+// TrustedKey is a public key whose signatures are accepted.
+type TrustedKey struct {
+	Name string
+	Key  ed25519.PublicKey
+}
+
+// String formats the key as a line of a trusted keys file.
+func (k TrustedKey) String() string {
+	return "ed25519 " + base64.StdEncoding.EncodeToString(k.Key) + " " + k.Name
+}
+
+// TrustedKeys are the keys whose signatures are accepted.
+type TrustedKeys []TrustedKey
+
+// Name returns the name of the trusted key with the given base64 encoded
+// public key, or an empty string when it is not trusted.
+func (t TrustedKeys) Name(key string) string {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return ""
+	}
+
+	for _, trusted := range t {
+		if bytes.Equal(trusted.Key, decoded) {
+			return trusted.Name
+		}
+	}
+
+	return ""
+}
+
+// ParseTrustedKeys reads a trusted keys file. Each line holds "ed25519",
+// a base64 encoded public key and the name of its owner. Blank lines and
+// lines starting with # are ignored.
+func ParseTrustedKeys(r io.Reader) (TrustedKeys, error) {
+	var keys TrustedKeys
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 3 || fields[0] != "ed25519" {
+			return nil, fmt.Errorf("line %d: expected \"ed25519 <public-key> <name>\"", line)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("line %d: malformed ed25519 public key", line)
+		}
+
+		keys = append(keys, TrustedKey{Name: strings.Join(fields[2:], " "), Key: key})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading trusted keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// LoadTrustedKeys reads a trusted keys file.
+func LoadTrustedKeys(path string) (TrustedKeys, error) {
+	//nolint: gosec
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening trusted keys: %w", err)
+	}
+
+	keys, err := ParseTrustedKeys(f)
+	if err != nil {
+		_ = f.Close()
+
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("closing trusted keys: %w", err)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,190 @@
+package plan
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlan_Sign(t *testing.T) {
+	t.Parallel()
+
+	reviewer, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stranger, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trusted := TrustedKeys{{Name: "reviewer", Key: reviewer.Public().(ed25519.PublicKey)}}
+	signedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	signed := func(t *testing.T, keys ...ed25519.PrivateKey) *Plan {
+		t.Helper()
+
+		p := &Plan{Operations: []Operation{addUserOperation(userUUID)}}
+		for _, key := range keys {
+			if err := p.Sign(key, signedAt); err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+		}
+
+		// Signatures must survive a round trip through a file
+		var buf bytes.Buffer
+		if err := p.Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := LoadFromReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return loaded
+	}
+
+	tests := []struct {
+		name    string
+		plan    func(t *testing.T) *Plan
+		signers []string
+		wantErr error
+	}{
+		{
+			name:    "signed by a trusted key",
+			plan:    func(t *testing.T) *Plan { return signed(t, reviewer) },
+			signers: []string{"reviewer"},
+		},
+		{
+			name:    "also signed by an unknown key",
+			plan:    func(t *testing.T) *Plan { return signed(t, stranger, reviewer) },
+			signers: []string{"reviewer"},
+		},
+		{
+			name: "signed twice by the same key",
+			plan: func(t *testing.T) *Plan {
+				p := signed(t, reviewer, reviewer)
+				if len(p.Signatures) != 1 {
+					t.Errorf("Expected signing again to replace the signature, got %d", len(p.Signatures))
+				}
+
+				return p
+			},
+			signers: []string{"reviewer"},
+		},
+		{
+			name:    "unsigned",
+			plan:    func(t *testing.T) *Plan { return signed(t) },
+			wantErr: ErrUnsigned,
+		},
+		{
+			name:    "signed by an unknown key",
+			plan:    func(t *testing.T) *Plan { return signed(t, stranger) },
+			wantErr: ErrUntrustedKey,
+		},
+		{
+			name: "operations modified after signing",
+			plan: func(t *testing.T) *Plan {
+				p := signed(t, reviewer)
+				p.Operations = append(p.Operations, removeUserOperation(otherUserUUID))
+
+				return p
+			},
+			wantErr: ErrSignatureMismatch,
+		},
+		{
+			name: "names modified after signing",
+			plan: func(t *testing.T) *Plan {
+				p := signed(t, reviewer)
+				p.SetName(albumUUID, "Renamed")
+
+				return p
+			},
+			wantErr: ErrSignatureMismatch,
+		},
+		{
+			name: "signing time modified",
+			plan: func(t *testing.T) *Plan {
+				p := signed(t, reviewer)
+				p.Signatures[0].SignedAt = signedAt.Add(time.Hour)
+
+				return p
+			},
+			wantErr: ErrSignatureMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			signers, err := tt.plan(t).VerifySignatures(trusted)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifySignatures() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if strings.Join(signers, ",") != strings.Join(tt.signers, ",") {
+				t.Errorf("VerifySignatures() signers = %v, want %v", signers, tt.signers)
+			}
+		})
+	}
+}
+
+func TestPrivateKey_SaveAndLoad(t *testing.T) {
+	t.Parallel()
+
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "signing_key")
+	if err := SavePrivateKey(path, key); err != nil {
+		t.Fatalf("SavePrivateKey() error = %v", err)
+	}
+
+	if err := SavePrivateKey(path, key); err == nil {
+		t.Error("Expected SavePrivateKey() to refuse to overwrite a key")
+	}
+
+	loaded, err := LoadPrivateKey(path)
+	if err != nil {
+		t.Fatalf("LoadPrivateKey() error = %v", err)
+	}
+
+	if !key.Equal(loaded) {
+		t.Error("Loaded key differs from the saved key")
+	}
+}
+
+func TestParseTrustedKeys(t *testing.T) {
+	t.Parallel()
+
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := TrustedKey{Name: "Jane Doe", Key: key.Public().(ed25519.PublicKey)}.String()
+
+	keys, err := ParseTrustedKeys(strings.NewReader("# reviewers\n\n" + line + "\n"))
+	if err != nil {
+		t.Fatalf("ParseTrustedKeys() error = %v", err)
+	}
+
+	if len(keys) != 1 || keys[0].Name != "Jane Doe" || !keys[0].Key.Equal(key.Public()) {
+		t.Errorf("ParseTrustedKeys() = %+v", keys)
+	}
+
+	for _, bad := range []string{"ssh-rsa AAAA name", "ed25519 not-base64 name", "ed25519 " + line[8:52]} {
+		if _, err := ParseTrustedKeys(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseTrustedKeys(%q) succeeded, want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package plan
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Schema is the JSON Schema describing the plan format.
+//
+//go:embed schema.json
+var Schema []byte
+
+// Severity classifies a validation issue.
+type Severity string
+
+const (
+	// SeverityError marks a plan that must not be applied.
+	SeverityError Severity = "error"
+	// SeverityWarning marks something that deserves a second look.
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a problem found while validating a plan.
+type Issue struct {
+	Severity Severity `json:"severity"`
+	// Operation is the index of the affected operation, or -1 for issues
+	// with the plan as a whole.
+	Operation int    `json:"operation"`
+	Message   string `json:"message"`
+}
+
+// String formats the issue with a 1-based operation number.
+func (i Issue) String() string {
+	if i.Operation < 0 {
+		return fmt.Sprintf("%s: plan: %s", i.Severity, i.Message)
+	}
+
+	return fmt.Sprintf("%s: operation %d: %s", i.Severity, i.Operation+1, i.Message)
+}
+
+// RequestParser recognises the intents carried out by a request, reporting
+// false for requests it does not understand.
+type RequestParser func(Request) ([]Intent, bool)
+
+// allowedMethods are the HTTP methods a plan request may use.
+var allowedMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Validate checks a plan for problems before it is applied: malformed
+// requests, IDs that are not UUIDs, operations without a revert, reverts
+// that do not undo their apply and dependencies on operations that do not
+// come first. parse is used to understand requests; requests it does not
+// recognise are only checked structurally.
+func Validate(p *Plan, parse RequestParser) []Issue {
+	var issues []Issue
+
+	if p.Version > FormatVersion {
+		issues = append(issues, Issue{
+			Severity:  SeverityError,
+			Operation: -1,
+			Message:   fmt.Sprintf("format version %d is newer than the supported version %d", p.Version, FormatVersion),
+		})
+	}
+
+	if modified, err := p.Modified(); err == nil && modified {
+		issues = append(issues, Issue{
+			Severity:  SeverityWarning,
+			Operation: -1,
+			Message:   "operations were modified after the plan was generated",
+		})
+	}
+
+	for i, op := range p.Operations {
+		issues = append(issues, validateOperation(i, op, parse)...)
+	}
+
+	return append(issues, validateDependencies(p)...)
+}
+
+// HasErrors reports whether any of the issues is an error.
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateOperation checks a single operation.
+func validateOperation(i int, op Operation, parse RequestParser) []Issue {
+	var issues []Issue
+
+	errorf := func(format string, args ...any) {
+		issues = append(issues, Issue{Severity: SeverityError, Operation: i, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, pc := range op.Preconditions {
+		if !uuidPattern.MatchString(pc.AlbumID) {
+			errorf("precondition %q: album ID %q is not a UUID", pc.Kind, pc.AlbumID)
+		}
+
+		if pc.UserID != "" && !uuidPattern.MatchString(pc.UserID) {
+			errorf("precondition %q: user ID %q is not a UUID", pc.Kind, pc.UserID)
+		}
+	}
+
+	if len(op.Intents) > 0 {
+		if len(op.Apply) > 0 || len(op.Revert) > 0 {
+			errorf("intents cannot be combined with apply or revert requests")
+		}
+
+		for j, intent := range op.Intents {
+			validateIntent(fmt.Sprintf("intent %d", j+1), intent, errorf)
+		}
+
+		return issues
+	}
+
+	if len(op.Apply) == 0 {
+		errorf("no apply requests")
+	}
+
+	if len(op.Revert) == 0 {
+		errorf("no revert requests")
+	}
+
+	applyIntents, applyKnown := validateRequests("apply", op.Apply, parse, errorf)
+	revertIntents, revertKnown := validateRequests("revert", op.Revert, parse, errorf)
+
+	if len(op.Revert) == 0 {
+		return issues
+	}
+
+	if !applyKnown || !revertKnown {
+		if parse != nil {
+			issues = append(issues, Issue{
+				Severity:  SeverityWarning,
+				Operation: i,
+				Message:   "revert could not be checked against apply",
+			})
+		}
+
+		return issues
+	}
+
+	for _, intent := range applyIntents {
+		if !undone(intent, revertIntents) {
+			errorf("revert does not undo %s on album %s", intent.Kind, intent.AlbumID)
+		}
+	}
+
+	return issues
+}
+
+// validateRequests checks each request structurally and returns their
+// intents, reporting whether all of them were recognised.
+func validateRequests(
+	kind string, reqs []Request, parse RequestParser, errorf func(string, ...any),
+) ([]Intent, bool) {
+	var intents []Intent
+
+	known := parse != nil
+
+	for j, req := range reqs {
+		where := fmt.Sprintf("%s request %d", kind, j+1)
+
+		if !allowedMethods[req.Method] {
+			errorf("%s: method %q is not allowed", where, req.Method)
+		}
+
+		if err := validatePath(req.Path); err != nil {
+			errorf("%s: %v", where, err)
+		}
+
+		if len(req.Body) > 0 && !validBody(req.Body) {
+			errorf("%s: body must be a JSON object or array", where)
+		}
+
+		if parse == nil {
+			continue
+		}
+
+		parsed, ok := parse(req)
+		if !ok {
+			known = false
+
+			continue
+		}
+
+		for _, intent := range parsed {
+			for _, id := range intentIDs(intent) {
+				if !validID(id) {
+					errorf("%s: %q is not a UUID", where, id)
+				}
+			}
+		}
+
+		intents = append(intents, parsed...)
+	}
+
+	return intents, known
+}
+
+// albumRoles are the roles a user can have in a shared album.
+var albumRoles = map[string]bool{"viewer": true, "editor": true}
+
+// validateIntent checks that an intent has the fields its kind needs to be
+// compiled to requests and inverted.
+func validateIntent(where string, intent Intent, errorf func(string, ...any)) {
+	for _, id := range intentIDs(intent) {
+		if !validID(id) {
+			errorf("%s: %q is not a UUID", where, id)
+		}
+	}
+
+	switch intent.Kind {
+	case IntentAlbumRename:
+		if intent.Name == "" || intent.From == "" {
+			errorf("%s: %s needs the new name and the name it replaces", where, intent.Kind)
+		}
+	case IntentAlbumAddUser, IntentAlbumRemoveUser:
+		if intent.UserID == "" {
+			errorf("%s: %s needs a user ID", where, intent.Kind)
+		}
+
+		if !albumRoles[intent.Role] {
+			errorf("%s: %s needs a role of viewer or editor, got %q", where, intent.Kind, intent.Role)
+		}
+	case IntentAlbumAddAssets, IntentAlbumRemoveAssets:
+		if len(intent.AssetIDs) == 0 {
+			errorf("%s: %s needs at least one asset ID", where, intent.Kind)
+		}
+	default:
+		errorf("%s: unknown kind %q", where, intent.Kind)
+	}
+}
+
+// validateDependencies checks operation IDs and that every operation only
+// depends on, and references values of, operations that come before it.
+func validateDependencies(p *Plan) []Issue {
+	var issues []Issue
+
+	errorf := func(i int, format string, args ...any) {
+		issues = append(issues, Issue{Severity: SeverityError, Operation: i, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Position of each operation ID, to tell earlier from later operations
+	index := make(map[string]int)
+
+	for i, op := range p.Operations {
+		if op.ID == "" {
+			continue
+		}
+
+		if !operationIDPattern.MatchString(op.ID) {
+			errorf(i, "id %q may only contain letters, digits, '-' and '_'", op.ID)
+		}
+
+		if j, ok := index[op.ID]; ok {
+			errorf(i, "id %q is already used by operation %d", op.ID, j+1)
+
+			continue
+		}
+
+		index[op.ID] = i
+	}
+
+	for i, op := range p.Operations {
+		for _, ref := range (Operation{Intents: op.Intents, Apply: op.Apply}).References() {
+			if op.ID != "" && ref.Operation == op.ID {
+				errorf(i, "%s is only captured once the apply requests have completed", ref)
+			}
+		}
+
+		for _, id := range op.Dependencies() {
+			j, ok := index[id]
+
+			switch {
+			case !ok:
+				errorf(i, "depends on unknown operation %q", id)
+			case j >= i:
+				errorf(i, "depends on operation %q, which does not come before it", id)
+			}
+		}
+	}
+
+	return issues
+}
+
+// validatePath checks that path is a well-formed API path.
+func validatePath(path string) error {
+	if !strings.HasPrefix(path, "/api/") {
+		return fmt.Errorf("path %q does not start with /api/", path)
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return fmt.Errorf("path %q is malformed: %w", path, err)
+	}
+
+	if strings.ContainsAny(u.Path, " \t\r\n") {
+		return fmt.Errorf("path %q contains whitespace", path)
+	}
+
+	for _, segment := range strings.Split(strings.TrimPrefix(u.Path, "/"), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("path %q has an empty or relative segment", path)
+		}
+	}
+
+	return nil
+}
+
+// validBody reports whether body is valid JSON holding an object, an array
+// or null.
+func validBody(body json.RawMessage) bool {
+	if !json.Valid(body) {
+		return false
+	}
+
+	trimmed := strings.TrimSpace(string(body))
+
+	return strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") || trimmed == "null"
+}
+
+// intentIDs returns every ID referenced by an intent.
+func intentIDs(intent Intent) []string {
+	ids := []string{intent.AlbumID}
+	if intent.UserID != "" {
+		ids = append(ids, intent.UserID)
+	}
+
+	return append(ids, intent.AssetIDs...)
+}
+
+// validID reports whether id is a UUID or a placeholder for a value
+// captured from another operation.
+func validID(id string) bool {
+	return uuidPattern.MatchString(id) || isPlaceholder(id)
+}
+
+// undone reports whether one of the reverts undoes intent.
+func undone(intent Intent, reverts []Intent) bool {
+	for _, revert := range reverts {
+		if revert.Undoes(intent) {
+			return true
+		}
+	}
+
+	return false
+}
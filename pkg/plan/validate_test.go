@@ -0,0 +1,186 @@
+package plan
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const (
+	albumUUID = "0b6e1a52-1f9c-4c53-9f0a-6f1f7f0d2c11"
+	userUUID  = "7d1e5b1a-2c3d-4e5f-8a9b-0c1d2e3f4a5b"
+)
+
+// parseUsers understands the two user membership requests, standing in
+// for the Immich request parser.
+func parseUsers(req Request) ([]Intent, bool) {
+	parts := strings.Split(strings.TrimPrefix(req.Path, "/api/albums/"), "/")
+
+	switch {
+	case req.Method == http.MethodPut && len(parts) == 2 && parts[1] == "users":
+		var body struct {
+			AlbumUsers []struct {
+				UserID string `json:"userId"`
+			} `json:"albumUsers"`
+		}
+
+		if json.Unmarshal(req.Body, &body) != nil || len(body.AlbumUsers) != 1 {
+			return nil, false
+		}
+
+		return []Intent{{Kind: IntentAlbumAddUser, AlbumID: parts[0], UserID: body.AlbumUsers[0].UserID}}, true
+	case req.Method == http.MethodDelete && len(parts) == 3 && parts[1] == "user":
+		return []Intent{{Kind: IntentAlbumRemoveUser, AlbumID: parts[0], UserID: parts[2]}}, true
+	default:
+		return nil, false
+	}
+}
+
+func addUserRequest(albumID, userID string) Request {
+	return Request{
+		Path:   "/api/albums/" + albumID + "/users",
+		Method: http.MethodPut,
+		Body:   json.RawMessage(`{"albumUsers":[{"role":"viewer","userId":"` + userID + `"}]}`),
+	}
+}
+
+func removeUserRequest(albumID, userID string) Request {
+	return Request{Path: "/api/albums/" + albumID + "/user/" + userID, Method: http.MethodDelete}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		op   Operation
+		want []string
+	}{
+		{
+			name: "valid",
+			op: Operation{
+				Apply:  []Request{addUserRequest(albumUUID, userUUID)},
+				Revert: []Request{removeUserRequest(albumUUID, userUUID)},
+			},
+		},
+		{
+			name: "missing revert",
+			op:   Operation{Apply: []Request{addUserRequest(albumUUID, userUUID)}},
+			want: []string{"error: operation 1: no revert requests"},
+		},
+		{
+			name: "revert is not the inverse",
+			op: Operation{
+				Apply:  []Request{addUserRequest(albumUUID, userUUID)},
+				Revert: []Request{addUserRequest(albumUUID, userUUID)},
+			},
+			want: []string{"revert does not undo album.addUser"},
+		},
+		{
+			name: "malformed requests",
+			op: Operation{
+				Apply: []Request{{Path: "/albums/x", Method: "FETCH", Body: json.RawMessage(`"text"`)}},
+				Revert: []Request{
+					{Path: "/api/albums/../users", Method: http.MethodDelete},
+				},
+			},
+			want: []string{
+				`method "FETCH" is not allowed`,
+				`path "/albums/x" does not start with /api/`,
+				"body must be a JSON object or array",
+				"empty or relative segment",
+				"warning: operation 1: revert could not be checked",
+			},
+		},
+		{
+			name: "IDs are not UUIDs",
+			op: Operation{
+				Preconditions: []Precondition{AlbumNotMember("album-1", userUUID)},
+				Apply:         []Request{addUserRequest("album-1", userUUID)},
+				Revert:        []Request{removeUserRequest("album-1", userUUID)},
+			},
+			want: []string{
+				`precondition "album.notMember": album ID "album-1" is not a UUID`,
+				`apply request 1: "album-1" is not a UUID`,
+			},
+		},
+		{
+			name: "valid intents",
+			op: Operation{Intents: []Intent{
+				{Kind: IntentAlbumRename, AlbumID: albumUUID, Name: "Italy 2024", From: "Italy"},
+				{Kind: IntentAlbumAddUser, AlbumID: albumUUID, UserID: userUUID, Role: "viewer"},
+			}},
+		},
+		{
+			name: "intents that cannot be compiled or inverted",
+			op: Operation{
+				Intents: []Intent{
+					{Kind: IntentAlbumRename, AlbumID: albumUUID, Name: "Italy 2024"},
+					{Kind: IntentAlbumRemoveUser, AlbumID: albumUUID, UserID: userUUID},
+					{Kind: IntentAlbumAddAssets, AlbumID: "album-1"},
+					{Kind: "album.delete", AlbumID: albumUUID},
+				},
+				Apply: []Request{addUserRequest(albumUUID, userUUID)},
+			},
+			want: []string{
+				"intents cannot be combined with apply or revert requests",
+				"intent 1: album.rename needs the new name and the name it replaces",
+				`intent 2: album.removeUser needs a role of viewer or editor, got ""`,
+				`intent 3: "album-1" is not a UUID`,
+				"intent 3: album.addAssets needs at least one asset ID",
+				`intent 4: unknown kind "album.delete"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			issues := Validate(&Plan{Operations: []Operation{tt.op}}, parseUsers)
+
+			var messages []string
+			for _, issue := range issues {
+				messages = append(messages, issue.String())
+			}
+
+			output := strings.Join(messages, "\n")
+
+			if len(tt.want) == 0 && len(issues) > 0 {
+				t.Errorf("Expected no issues, got:\n%s", output)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("Expected an issue containing %q, got:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestHasErrors(t *testing.T) {
+	t.Parallel()
+
+	if HasErrors([]Issue{{Severity: SeverityWarning}}) {
+		t.Error("Expected warnings not to count as errors")
+	}
+
+	if !HasErrors([]Issue{{Severity: SeverityWarning}, {Severity: SeverityError}}) {
+		t.Error("Expected an error to be found")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	var schema map[string]any
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		t.Fatalf("Schema is not valid JSON: %v", err)
+	}
+
+	if schema["$schema"] == nil || schema["$defs"] == nil {
+		t.Errorf("Schema is missing its header or definitions")
+	}
+}